@@ -1,12 +1,12 @@
 package main
 
 import (
-	"encoding/json"
-	"io/ioutil"
+	"context"
 	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"path/filepath"
 	"strconv"
 	"time"
 
@@ -16,7 +16,9 @@ import (
 	"github.com/Financial-Times/methode-content-placeholder-mapper/handler"
 	"github.com/Financial-Times/methode-content-placeholder-mapper/mapper"
 	"github.com/Financial-Times/methode-content-placeholder-mapper/message"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/outbox"
 	"github.com/Financial-Times/methode-content-placeholder-mapper/resources"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/schema"
 	"github.com/Financial-Times/service-status-go/httphandlers"
 	log "github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
@@ -64,6 +66,12 @@ func main() {
 		Desc:   "The topic to write the messages to.",
 		EnvVar: "Q_WRITE_TOPIC",
 	})
+	deadLetterTopic := app.String(cli.StringOpt{
+		Name:   "dead-letter-topic",
+		Value:  "",
+		Desc:   "The topic to write the messages that failed mapping or publishing to. Dead-lettering is disabled if empty.",
+		EnvVar: "Q_DEAD_LETTER_TOPIC",
+	})
 	authorization := app.String(cli.StringOpt{
 		Name:   "authorization",
 		Value:  "",
@@ -76,12 +84,18 @@ func main() {
 		Desc:   "application port",
 		EnvVar: "PORT",
 	})
-	docStoreAddress := app.String(cli.StringOpt{
+	docStoreAddresses := app.Strings(cli.StringsOpt{
 		Name:   "document-store-api-addresses",
-		Value:  "",
-		Desc:   "Addresses to connect to the consumer queue (URLs).",
+		Value:  nil,
+		Desc:   "Addresses to connect to document-store-api (URLs). Calls are spread over the healthy addresses and retried on another one if they fail.",
 		EnvVar: "DOCUMENT_STORE_API_ADDRESS",
 	})
+	docStoreHealthCheckInterval := app.String(cli.StringOpt{
+		Name:   "docstore-health-check-interval",
+		Value:  "10s",
+		Desc:   "How often the good-to-go of every document-store-api address is checked to eject the failing ones (e.g. 10s). Background checks are disabled if 0.",
+		EnvVar: "DOCSTORE_HEALTH_CHECK_INTERVAL",
+	})
 	apiHost := app.String(cli.StringOpt{
 		Name:   "api-host",
 		Value:  "api.ft.com",
@@ -89,7 +103,245 @@ func main() {
 		EnvVar: "API_HOST",
 	})
 
+	retryMaxAttempts := app.Int(cli.IntOpt{
+		Name:   "retry-max-attempts",
+		Value:  3,
+		Desc:   "Maximum number of attempts to map a placeholder when document-store-api fails transiently.",
+		EnvVar: "RETRY_MAX_ATTEMPTS",
+	})
+	retryInitialBackoff := app.String(cli.StringOpt{
+		Name:   "retry-initial-backoff",
+		Value:  "200ms",
+		Desc:   "Delay before the first retry, doubled with each further attempt (e.g. 200ms).",
+		EnvVar: "RETRY_INITIAL_BACKOFF",
+	})
+	retryMaxBackoff := app.String(cli.StringOpt{
+		Name:   "retry-max-backoff",
+		Value:  "5s",
+		Desc:   "Upper bound of the delay between retries (e.g. 5s).",
+		EnvVar: "RETRY_MAX_BACKOFF",
+	})
+
+	messageTimeout := app.String(cli.StringOpt{
+		Name:   "message-timeout",
+		Value:  "30s",
+		Desc:   "Deadline of the mapping of a consumed message, its retries included (e.g. 30s). There is no deadline if 0.",
+		EnvVar: "MESSAGE_TIMEOUT",
+	})
+	mapRequestTimeout := app.String(cli.StringOpt{
+		Name:   "map-request-timeout",
+		Value:  "10s",
+		Desc:   "Deadline of the mapping of a /map request, or of each placeholder of a /map/batch request (e.g. 10s). There is no deadline if 0.",
+		EnvVar: "MAP_REQUEST_TIMEOUT",
+	})
+
+	workers := app.Int(cli.IntOpt{
+		Name:   "workers",
+		Value:  1,
+		Desc:   "Number of messages processed concurrently. Messages for the same UUID are always processed in arrival order.",
+		EnvVar: "WORKERS",
+	})
+
+	outboxDir := app.String(cli.StringOpt{
+		Name:   "outbox-dir",
+		Value:  "",
+		Desc:   "Directory where the messages that could not be produced are spooled until the producer queue is reachable. The outbox is disabled if empty.",
+		EnvVar: "OUTBOX_DIR",
+	})
+	outboxFlushInterval := app.String(cli.StringOpt{
+		Name:   "outbox-flush-interval",
+		Value:  "5s",
+		Desc:   "How often the outbox is re-sent when not empty (e.g. 5s).",
+		EnvVar: "OUTBOX_FLUSH_INTERVAL",
+	})
+	outboxMaxAttempts := app.Int(cli.IntOpt{
+		Name:   "outbox-max-attempts",
+		Value:  10,
+		Desc:   "How many times a message is re-sent from the outbox while the producer queue is reachable before it is moved to the rejected subdirectory of the outbox. It is re-sent forever if 0.",
+		EnvVar: "OUTBOX_MAX_ATTEMPTS",
+	})
+
+	brandMappingsFile := app.String(cli.StringOpt{
+		Name:   "brand-mappings-file",
+		Value:  "./brandMappings.json",
+		Desc:   "File mapping the WordPress blogs to the authority of their identifiers.",
+		EnvVar: "BRAND_MAPPINGS_FILE",
+	})
+	brandMappingsDefaultFile := app.String(cli.StringOpt{
+		Name:   "brand-mappings-default-file",
+		Value:  "",
+		Desc:   "File copied to the brand mappings file when it doesn't exist, e.g. to initialise brand mappings kept on a shared volume.",
+		EnvVar: "BRAND_MAPPINGS_DEFAULT_FILE",
+	})
+	brandMappingsReloadInterval := app.String(cli.StringOpt{
+		Name:   "brand-mappings-reload-interval",
+		Value:  "30s",
+		Desc:   "How often the brand mappings file is checked for changes (e.g. 30s). Reloading is disabled if 0.",
+		EnvVar: "BRAND_MAPPINGS_RELOAD_INTERVAL",
+	})
+	publishedHashStore := app.String(cli.StringOpt{
+		Name:   "published-hash-store",
+		Value:  "memory",
+		Desc:   "Where the hashes of the published content are kept to skip republishing unchanged content: memory, file or none.",
+		EnvVar: "PUBLISHED_HASH_STORE",
+	})
+	publishedHashFile := app.String(cli.StringOpt{
+		Name:   "published-hash-file",
+		Value:  "./publishedHashes.log",
+		Desc:   "File the hashes of the published content are kept in when published-hash-store is file.",
+		EnvVar: "PUBLISHED_HASH_FILE",
+	})
+	payloadValidation := app.String(cli.StringOpt{
+		Name:   "payload-validation",
+		Value:  "lenient",
+		Desc:   "Validation of the mapped payloads against the schemas in payload-schemas-dir: strict (not published if invalid), lenient (only reported) or off.",
+		EnvVar: "PAYLOAD_VALIDATION",
+	})
+	payloadSchemasDir := app.String(cli.StringOpt{
+		Name:   "payload-schemas-dir",
+		Value:  "./schemas",
+		Desc:   "Directory of the JSON schemas of the mapped payloads.",
+		EnvVar: "PAYLOAD_SCHEMAS_DIR",
+	})
+	messageFormat := app.String(cli.StringOpt{
+		Name:   "message-format",
+		Value:  message.FTMessageFormat,
+		Desc:   "Format of the mapped content messages: ft, cloudevents-structured or cloudevents-binary (CloudEvents 1.0).",
+		EnvVar: "MESSAGE_FORMAT",
+	})
+	passthroughHeaders := app.Strings(cli.StringsOpt{
+		Name:   "passthrough-headers",
+		Value:  nil,
+		Desc:   "Headers of the native messages copied to the mapped content messages (e.g. X-Origin-Env).",
+		EnvVar: "PASSTHROUGH_HEADERS",
+	})
+	messageKeyStrategy := app.String(cli.StringOpt{
+		Name:   "message-key-strategy",
+		Value:  message.UUIDKeyStrategy,
+		Desc:   "Key the mapped content messages are produced with: uuid (the content UUID), transaction-id or none.",
+		EnvVar: "MESSAGE_KEY_STRATEGY",
+	})
+	publishedTimestampStore := app.String(cli.StringOpt{
+		Name:   "published-timestamp-store",
+		Value:  "memory",
+		Desc:   "Where the timestamp of the latest message published to each UUID is kept to drop older messages: memory, or file to survive restarts and be shared by the replicas mounting published-timestamp-file.",
+		EnvVar: "PUBLISHED_TIMESTAMP_STORE",
+	})
+	publishedTimestampFile := app.String(cli.StringOpt{
+		Name:   "published-timestamp-file",
+		Value:  "./publishedTimestamps.log",
+		Desc:   "File the timestamps of the latest published messages are kept in when published-timestamp-store is file, on a volume shared by the replicas.",
+		EnvVar: "PUBLISHED_TIMESTAMP_FILE",
+	})
+	publishedTimestampCapacity := app.Int(cli.IntOpt{
+		Name:   "published-timestamp-capacity",
+		Value:  100000,
+		Desc:   "Number of UUIDs whose latest published timestamp is kept. Beyond it, older messages of the forgotten UUIDs are published again.",
+		EnvVar: "PUBLISHED_TIMESTAMP_CAPACITY",
+	})
+	messageDedupWindow := app.String(cli.StringOpt{
+		Name:   "message-dedup-window",
+		Value:  "10m",
+		Desc:   "How long the Message-Id of a processed native message is remembered to ignore its duplicates (e.g. 10m). De-duplication is disabled if 0.",
+		EnvVar: "MESSAGE_DEDUP_WINDOW",
+	})
+	messageDedupStore := app.String(cli.StringOpt{
+		Name:   "message-dedup-store",
+		Value:  "memory",
+		Desc:   "Where the Message-Ids of the processed native messages are kept: memory, or file to survive restarts and be shared by the replicas mounting message-dedup-file.",
+		EnvVar: "MESSAGE_DEDUP_STORE",
+	})
+	messageDedupFile := app.String(cli.StringOpt{
+		Name:   "message-dedup-file",
+		Value:  "./processedMessages.log",
+		Desc:   "File the Message-Ids of the processed native messages are kept in when message-dedup-store is file, on a volume shared by the replicas.",
+		EnvVar: "MESSAGE_DEDUP_FILE",
+	})
+	batchWorkers := app.Int(cli.IntOpt{
+		Name:   "batch-workers",
+		Value:  8,
+		Desc:   "Number of placeholders of a /map/batch request mapped concurrently.",
+		EnvVar: "BATCH_WORKERS",
+	})
+	topicRoutingFile := app.String(cli.StringOpt{
+		Name:   "topic-routing-file",
+		Value:  "",
+		Desc:   "JSON file routing the messages of a collection (e.g. complementarycontent) to another topic and optionally queue address. Every message goes to write-topic if empty.",
+		EnvVar: "TOPIC_ROUTING_FILE",
+	})
+	docStoreCacheSize := app.Int(cli.IntOpt{
+		Name:   "docstore-cache-size",
+		Value:  10000,
+		Desc:   "Maximum number of document-store-api responses cached. The cache is disabled if 0.",
+		EnvVar: "DOCSTORE_CACHE_SIZE",
+	})
+	docStoreCacheContentQueryTTL := app.String(cli.StringOpt{
+		Name:   "docstore-cache-content-query-ttl",
+		Value:  "10m",
+		Desc:   "How long the UUID found for a blog post identifier is cached (e.g. 10m).",
+		EnvVar: "DOCSTORE_CACHE_CONTENT_QUERY_TTL",
+	})
+	docStoreCacheContentTTL := app.String(cli.StringOpt{
+		Name:   "docstore-cache-content-ttl",
+		Value:  "1m",
+		Desc:   "How long the content read for its brands is cached (e.g. 1m).",
+		EnvVar: "DOCSTORE_CACHE_CONTENT_TTL",
+	})
+	docStoreCacheExistsTTL := app.String(cli.StringOpt{
+		Name:   "docstore-cache-exists-ttl",
+		Value:  "10m",
+		Desc:   "How long the content found to exist is cached (e.g. 10m).",
+		EnvVar: "DOCSTORE_CACHE_EXISTS_TTL",
+	})
+	docStoreCacheDocumentTTL := app.String(cli.StringOpt{
+		Name:   "docstore-cache-document-ttl",
+		Value:  "0s",
+		Desc:   "How long the documents compared by /map?diff=true are cached (e.g. 1m). They are always read afresh if 0.",
+		EnvVar: "DOCSTORE_CACHE_DOCUMENT_TTL",
+	})
+	docStoreCacheNotFoundTTL := app.String(cli.StringOpt{
+		Name:   "docstore-cache-not-found-ttl",
+		Value:  "5s",
+		Desc:   "How long the identifiers not resolving to any content in document-store-api are cached (e.g. 5s). They are always looked up afresh if 0.",
+		EnvVar: "DOCSTORE_CACHE_NOT_FOUND_TTL",
+	})
+	docStoreBreakerFailureThreshold := app.Int(cli.IntOpt{
+		Name:   "docstore-breaker-failure-threshold",
+		Value:  5,
+		Desc:   "Number of consecutive failed calls to document-store-api opening the circuit breaker. The circuit breaker is disabled if 0.",
+		EnvVar: "DOCSTORE_BREAKER_FAILURE_THRESHOLD",
+	})
+	docStoreBreakerSuccessThreshold := app.Int(cli.IntOpt{
+		Name:   "docstore-breaker-success-threshold",
+		Value:  1,
+		Desc:   "Number of consecutive successful trial calls to document-store-api closing the circuit breaker again.",
+		EnvVar: "DOCSTORE_BREAKER_SUCCESS_THRESHOLD",
+	})
+	docStoreBreakerOpenTimeout := app.String(cli.StringOpt{
+		Name:   "docstore-breaker-open-timeout",
+		Value:  "30s",
+		Desc:   "How long the circuit breaker stays open, pausing the consumption, before trying document-store-api again (e.g. 30s).",
+		EnvVar: "DOCSTORE_BREAKER_OPEN_TIMEOUT",
+	})
+	categoryRoutingFile := app.String(cli.StringOpt{
+		Name:   "category-routing-file",
+		Value:  "./categoryRouting.json",
+		Desc:   "File mapping the placeholder categories to their resolution strategy (blog, generic, external or ignore).",
+		EnvVar: "CATEGORY_ROUTING_FILE",
+	})
+	adminAPIKey := app.String(cli.StringOpt{
+		Name:   "admin-api-key",
+		Value:  "",
+		Desc:   "Key expected in the X-Api-Key header of the /__admin endpoints. The admin endpoints are disabled if empty.",
+		EnvVar: "ADMIN_API_KEY",
+	})
+
 	app.Action = func() {
+		if *outboxDir == "" && *deadLetterTopic == "" {
+			// a placeholder whose content was sent but not its complementary content could neither be re-sent nor re-driven
+			log.Errorf("Either outbox-dir or dead-letter-topic should be set, so that partially published placeholders aren't lost\n")
+			os.Exit(1)
+		}
 		httpClient := setupHTTPClient()
 
 		consumerConfig := consumer.QueueConfig{
@@ -110,29 +362,160 @@ func main() {
 		}
 
 		cphValidator := mapper.NewDefaultCPHValidator()
-		docStoreClient := mapper.NewHttpDocStoreClient(httpClient, *docStoreAddress)
-		iResolver := mapper.NewHttpIResolver(docStoreClient, readBrandMappings())
+		httpDocStoreClient := mapper.NewHttpDocStoreClient(httpClient, *docStoreAddresses...)
+		if interval := parseDuration("docstore-health-check-interval", *docStoreHealthCheckInterval); interval > 0 {
+			httpDocStoreClient.StartHealthChecks(interval)
+			defer httpDocStoreClient.StopHealthChecks()
+		}
+		var docStoreClient mapper.DocStoreClient = httpDocStoreClient
+		var docStoreBreaker *mapper.CircuitBreakerDocStoreClient
+		if *docStoreBreakerFailureThreshold > 0 {
+			docStoreBreaker = mapper.NewCircuitBreakerDocStoreClient(docStoreClient, mapper.DocStoreBreakerConfig{
+				FailureThreshold: *docStoreBreakerFailureThreshold,
+				SuccessThreshold: *docStoreBreakerSuccessThreshold,
+				OpenTimeout:      parseDuration("docstore-breaker-open-timeout", *docStoreBreakerOpenTimeout),
+			})
+			docStoreClient = docStoreBreaker
+		}
+		var docStoreCacheStatsHandler *resources.DocStoreCacheStatsHandler
+		if *docStoreCacheSize > 0 {
+			docStoreCache := mapper.NewCachingDocStoreClient(docStoreClient, mapper.DocStoreCacheConfig{
+				MaxEntries:      *docStoreCacheSize,
+				ContentQueryTTL: parseDuration("docstore-cache-content-query-ttl", *docStoreCacheContentQueryTTL),
+				ContentTTL:      parseDuration("docstore-cache-content-ttl", *docStoreCacheContentTTL),
+				ExistsTTL:       parseDuration("docstore-cache-exists-ttl", *docStoreCacheExistsTTL),
+				DocumentTTL:     parseDuration("docstore-cache-document-ttl", *docStoreCacheDocumentTTL),
+				NotFoundTTL:     parseDuration("docstore-cache-not-found-ttl", *docStoreCacheNotFoundTTL),
+			})
+			docStoreClient = docStoreCache
+			docStoreCacheStatsHandler = resources.NewDocStoreCacheStatsHandler(docStoreCache)
+		}
+		brandMappings := readBrandMappings(*brandMappingsFile, *brandMappingsDefaultFile)
+		if interval := parseDuration("brand-mappings-reload-interval", *brandMappingsReloadInterval); interval > 0 {
+			brandMappings.Watch(interval)
+			defer brandMappings.StopWatching()
+		}
+		iResolver := mapper.NewHttpIResolver(docStoreClient, brandMappings)
 		contentCphMapper := &mapper.ContentCPHMapper{}
 		complementaryContentCPHMapper := mapper.NewComplementaryContentCPHMapper(*apiHost, docStoreClient)
-		aggregateMapper := mapper.NewAggregateCPHMapper(iResolver, cphValidator, []mapper.CPHMapper{contentCphMapper, complementaryContentCPHMapper})
+		aggregateMapper := mapper.NewAggregateCPHMapper(iResolver, cphValidator, readCategoryRouting(*categoryRoutingFile), []mapper.CPHMapper{contentCphMapper, complementaryContentCPHMapper})
 		nativeMapper := mapper.DefaultMessageMapper{}
-		messageCreator := message.NewDefaultCPHMessageCreator()
+		messageCreator, err := message.NewMessageCreator(*messageFormat, *passthroughHeaders)
+		if err != nil {
+			log.Errorf("Invalid message-format: %v\n", err)
+			os.Exit(1)
+		}
 		messageProducer := producer.NewMessageProducerWithHTTPClient(producerConfig, httpClient)
+		healthChecks := []fthealth.Check{}
+		var outboxProducers []*outbox.Producer
+		if *outboxDir != "" {
+			outboxProducer := newOutboxProducer(messageProducer, *outboxDir, parseDuration("outbox-flush-interval", *outboxFlushInterval), *outboxMaxAttempts)
+			outboxProducers = append(outboxProducers, outboxProducer)
+			messageProducer = outboxProducer
+			healthChecks = append(healthChecks, outboxProducer.BacklogCheck())
+		}
+		var producerRouter *message.ProducerRouter
+		if *topicRoutingFile != "" {
+			routes, err := message.ReadTopicRoutes(*topicRoutingFile)
+			if err != nil {
+				log.Errorf("Couldn't load topic routing configuration: %v\n", err)
+				os.Exit(1)
+			}
+			routedProducers := make(map[string]producer.MessageProducer)
+			for _, route := range routes {
+				routeConfig := producerConfig
+				routeConfig.Topic = route.Topic
+				if route.Address != "" {
+					routeConfig.Addr = route.Address
+				}
+				var routedProducer producer.MessageProducer = producer.NewMessageProducerWithHTTPClient(routeConfig, httpClient)
+				if *outboxDir != "" {
+					outboxProducer := newOutboxProducer(routedProducer, filepath.Join(*outboxDir, route.Topic), parseDuration("outbox-flush-interval", *outboxFlushInterval), *outboxMaxAttempts)
+					outboxProducers = append(outboxProducers, outboxProducer)
+					routedProducer = outboxProducer
+					backlogCheck := outboxProducer.BacklogCheck()
+					backlogCheck.Name += "-" + route.Topic
+					healthChecks = append(healthChecks, backlogCheck)
+				}
+				routedProducers[route.Collection] = routedProducer
+			}
+			producerRouter = message.NewProducerRouter(messageProducer, routedProducers)
+		}
 		h := handler.NewCPHMessageHandler(nil, messageProducer, aggregateMapper, nativeMapper, messageCreator)
-		messageConsumer := consumer.NewConsumer(consumerConfig, h.HandleMessage, httpClient)
+		h.BrandMappings = brandMappings
+		handleMessage := h.HandleMessage
+		if *workers > 1 {
+			h.WorkerPool = handler.NewOrderedWorkerPool(*workers, h.OrderingKeys, h.HandleParsedMessage)
+			handleMessage = h.WorkerPool.Submit
+		}
+		messageConsumer := consumer.NewConsumer(consumerConfig, handleMessage, httpClient)
 		h.MessageConsumer = messageConsumer
+		h.PublicationLog = handler.NewInMemoryPublicationLog(10000)
+		h.PublishedHashes = newPublishedHashStore(*publishedHashStore, *publishedHashFile)
+		h.PublishedTimestamps = newPublishedTimestampStore(*publishedTimestampStore, *publishedTimestampFile, *publishedTimestampCapacity)
+		keyStrategy, err := message.ParseKeyStrategy(*messageKeyStrategy)
+		if err != nil {
+			log.Errorf("Invalid message-key-strategy: %v\n", err)
+			os.Exit(1)
+		}
+		h.KeyStrategy = keyStrategy
+		h.Producers = producerRouter
+		h.PayloadSchemas, h.StrictPayloadValidation = readPayloadSchemas(*payloadValidation, *payloadSchemasDir)
+		if window := parseDuration("message-dedup-window", *messageDedupWindow); window > 0 {
+			h.ProcessedMessages = newProcessedMessageStore(*messageDedupStore, *messageDedupFile, window)
+		}
+		if docStoreBreaker != nil {
+			h.DocStoreGate = docStoreBreaker
+		}
+		h.MessageTimeout = parseDuration("message-timeout", *messageTimeout)
+		h.RetryPolicy = handler.RetryPolicy{
+			MaxAttempts:    *retryMaxAttempts,
+			InitialBackoff: parseDuration("retry-initial-backoff", *retryInitialBackoff),
+			MaxBackoff:     parseDuration("retry-max-backoff", *retryMaxBackoff),
+		}
+		if *deadLetterTopic != "" {
+			deadLetterProducerConfig := producer.MessageProducerConfig{
+				Addr:          *writeAddress,
+				Topic:         *deadLetterTopic,
+				Queue:         "kafka",
+				Authorization: *authorization,
+			}
+			h.DeadLetterQueue = handler.NewProducerDeadLetterQueue(producer.NewMessageProducerWithHTTPClient(deadLetterProducerConfig, httpClient))
+		}
 		endpointHandler := resources.NewMapEndpointHandler(aggregateMapper, messageCreator, nativeMapper)
+		endpointHandler.BatchWorkers = *batchWorkers
+		endpointHandler.DocStore = docStoreClient
+		endpointHandler.RequestTimeout = parseDuration("map-request-timeout", *mapRequestTimeout)
+
+		publicationStatusHandler := resources.NewPublicationStatusHandler(h.PublicationLog)
+		var brandMappingsAdminHandler *resources.BrandMappingsAdminHandler
+		if *adminAPIKey != "" {
+			brandMappingsAdminHandler = resources.NewBrandMappingsAdminHandler(brandMappings, *adminAPIKey)
+		}
 
-		go serve(*port, resources.NewMapperHealthcheck(messageConsumer, messageProducer, docStoreClient), endpointHandler)
+		var producerCheck resources.ConnectivityChecker = messageProducer
+		if producerRouter != nil {
+			producerCheck = producerRouter
+		}
+		hc := resources.NewMapperHealthcheck(messageConsumer, producerCheck, docStoreClient)
+		healthChecks = append([]fthealth.Check{hc.ConsumerConnectivityCheck(), hc.ProducerConnectivityCheck(), hc.DocumentStoreConnectivityCheck()}, healthChecks...)
+		if len(httpDocStoreClient.Addresses()) > 1 {
+			healthChecks = append(healthChecks, hc.DocumentStoreAddressChecks(httpDocStoreClient)...)
+		}
+
+		go serve(h.Context(), *port, hc, healthChecks, endpointHandler, publicationStatusHandler, brandMappingsAdminHandler, docStoreCacheStatsHandler)
 
 		h.StartHandlingMessages()
+		for _, outboxProducer := range outboxProducers {
+			outboxProducer.Stop()
+		}
 	}
 	if err := app.Run(os.Args); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func serve(port int, hc *resources.MapperHealthcheck, meh *resources.MapEndpointHandler) {
+func serve(ctx context.Context, port int, hc *resources.MapperHealthcheck, checks []fthealth.Check, meh *resources.MapEndpointHandler, psh *resources.PublicationStatusHandler, bmah *resources.BrandMappingsAdminHandler, dcsh *resources.DocStoreCacheStatsHandler) {
 	r := mux.NewRouter()
 
 	timedHec := fthealth.TimedHealthCheck{
@@ -140,11 +523,20 @@ func serve(port int, hc *resources.MapperHealthcheck, meh *resources.MapEndpoint
 			SystemCode:  "up-mcpm",
 			Name:        "Dependent services healthcheck",
 			Description: "Checks if all the dependent services are reachable and healthy.",
-			Checks:      []fthealth.Check{hc.ConsumerConnectivityCheck(), hc.ProducerConnectivityCheck(), hc.DocumentStoreConnectivityCheck()},
+			Checks:      checks,
 		},
 		Timeout: 10 * time.Second,
 	}
 	r.HandleFunc("/map", meh.ServeMapEndpoint).Methods("POST")
+	r.HandleFunc("/map/batch", meh.ServeBatchMapEndpoint).Methods("POST")
+	r.HandleFunc("/__publications/{uuid}", psh.ServePublicationStatus).Methods("GET")
+	if bmah != nil {
+		r.HandleFunc("/__admin/brand-mappings", bmah.ServeBrandMappings).Methods("GET")
+		r.HandleFunc("/__admin/brand-mappings", bmah.UpdateBrandMappings).Methods("PUT")
+	}
+	if dcsh != nil {
+		r.HandleFunc("/__docstore-cache", dcsh.ServeDocStoreCacheStats).Methods("GET")
+	}
 	r.HandleFunc("/__health", fthealth.Handler(timedHec))
 	r.HandleFunc(httphandlers.GTGPath, httphandlers.NewGoodToGoHandler(hc.GTG)).Methods("GET")
 	r.HandleFunc(httphandlers.BuildInfoPath, httphandlers.BuildInfoHandler).Methods("GET")
@@ -152,23 +544,134 @@ func serve(port int, hc *resources.MapperHealthcheck, meh *resources.MapEndpoint
 
 	http.Handle("/", r)
 
-	err := http.ListenAndServe(":"+strconv.Itoa(port), nil)
+	// the requests in progress are canceled on shutdown
+	server := &http.Server{
+		Addr:        ":" + strconv.Itoa(port),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	err := server.ListenAndServe()
 	log.Fatal(err)
 }
 
-func readBrandMappings() map[string]string {
-	brandMappingsFile, err := ioutil.ReadFile("./brandMappings.json")
+func readBrandMappings(path, defaultPath string) *mapper.BrandMappingRegistry {
+	if defaultPath != "" {
+		if err := mapper.CopyBrandMappingsIfMissing(path, defaultPath); err != nil {
+			log.Errorf("Couldn't initialise brand mapping configuration: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	brandMappings, err := mapper.NewBrandMappingRegistry(path)
+	if err != nil {
+		log.Errorf("Couldn't load brand mapping configuration: %v\n", err)
+		os.Exit(1)
+	}
+	return brandMappings
+}
+
+func newPublishedHashStore(kind, path string) handler.PublishedHashStore {
+	switch kind {
+	case "memory":
+		return handler.NewInMemoryPublishedHashStore(100000)
+	case "file":
+		store, err := handler.NewFilePublishedHashStore(path)
+		if err != nil {
+			log.Errorf("Couldn't open published hash store: %v\n", err)
+			os.Exit(1)
+		}
+		return store
+	case "none":
+		return nil
+	}
+	log.Errorf("Invalid published-hash-store %v, should be memory, file or none\n", kind)
+	os.Exit(1)
+	return nil
+}
+
+func newPublishedTimestampStore(kind, path string, capacity int) handler.PublishedTimestampStore {
+	switch kind {
+	case "memory":
+		return handler.NewInMemoryPublishedTimestampStore(capacity)
+	case "file":
+		store, err := handler.NewFilePublishedTimestampStore(path, capacity)
+		if err != nil {
+			log.Errorf("Couldn't open published timestamp store: %v\n", err)
+			os.Exit(1)
+		}
+		return store
+	}
+	log.Errorf("Invalid published-timestamp-store %v, should be memory or file\n", kind)
+	os.Exit(1)
+	return nil
+}
+
+func newProcessedMessageStore(kind, path string, window time.Duration) handler.ProcessedMessageStore {
+	switch kind {
+	case "memory":
+		return handler.NewInMemoryProcessedMessageStore(window)
+	case "file":
+		store, err := handler.NewFileProcessedMessageStore(path, window)
+		if err != nil {
+			log.Errorf("Couldn't open processed message store: %v\n", err)
+			os.Exit(1)
+		}
+		return store
+	}
+	log.Errorf("Invalid message-dedup-store %v, should be memory or file\n", kind)
+	os.Exit(1)
+	return nil
+}
+
+func readPayloadSchemas(mode, dir string) (*schema.PayloadValidator, bool) {
+	switch mode {
+	case "off":
+		return nil, false
+	case "lenient", "strict":
+		payloadSchemas, err := schema.ReadPayloadSchemas(dir)
+		if err != nil {
+			log.Errorf("Couldn't load payload schemas: %v\n", err)
+			os.Exit(1)
+		}
+		return payloadSchemas, mode == "strict"
+	}
+	log.Errorf("Invalid payload-validation %v, should be strict, lenient or off\n", mode)
+	os.Exit(1)
+	return nil, false
+}
+
+func newOutboxProducer(p producer.MessageProducer, dir string, flushInterval time.Duration, maxAttempts int) *outbox.Producer {
+	outboxStore, err := outbox.NewFileStore(dir)
 	if err != nil {
-		log.Errorf("Couldn't read brand mapping configuration: %v\n", err)
+		log.Errorf("Couldn't open outbox: %v\n", err)
 		os.Exit(1)
 	}
-	var brandMappings map[string]string
-	err = json.Unmarshal(brandMappingsFile, &brandMappings)
+	rejectedStore, err := outbox.NewFileStore(filepath.Join(dir, "rejected"))
 	if err != nil {
-		log.Errorf("Couldn't unmarshal brand mapping configuration: %v\n", err)
+		log.Errorf("Couldn't open rejected outbox: %v\n", err)
 		os.Exit(1)
 	}
-	return brandMappings
+	outboxProducer := outbox.NewProducer(p, outboxStore, flushInterval)
+	outboxProducer.MaxAttempts = maxAttempts
+	outboxProducer.Rejected = rejectedStore
+	outboxProducer.Start()
+	return outboxProducer
+}
+
+func readCategoryRouting(path string) *mapper.CategoryRoutingTable {
+	categoryRouting, err := mapper.ReadCategoryRouting(path)
+	if err != nil {
+		log.Errorf("Couldn't load category routing configuration: %v\n", err)
+		os.Exit(1)
+	}
+	return categoryRouting
+}
+
+func parseDuration(option, value string) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Errorf("Invalid duration for %v: %v\n", option, err)
+		os.Exit(1)
+	}
+	return d
 }
 
 func setupHTTPClient() *http.Client {
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+)
+
+// ForceRepublishHeader, set to true on a native message, publishes its content even when it didn't change
+const ForceRepublishHeader = "X-Force-Republish"
+
+// PublishedHashStore keeps the hash of the content last published for each content URI
+type PublishedHashStore interface {
+	Get(contentURI string) (string, bool)
+	Put(contentURI, hash string) error
+}
+
+// contentHash is a stable hash of the mapped content, ignoring the fields which change with every publication
+func contentHash(content model.UppContent) (string, error) {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "", err
+	}
+	delete(fields, "publishReference")
+	delete(fields, "lastModified")
+	fields["markedDeleted"] = content.GetUppCoreContent().IsMarkedDeleted
+
+	// maps are marshalled with sorted keys, so equal contents give equal documents
+	canonical, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// InMemoryPublishedHashStore is a PublishedHashStore keeping the hashes of the most recently published content URIs
+type InMemoryPublishedHashStore struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	recency  *list.List
+}
+
+type publishedHash struct {
+	ContentURI string `json:"contentUri"`
+	Hash       string `json:"hash"`
+}
+
+func NewInMemoryPublishedHashStore(capacity int) *InMemoryPublishedHashStore {
+	return &InMemoryPublishedHashStore{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		recency:  list.New(),
+	}
+}
+
+func (s *InMemoryPublishedHashStore) Get(contentURI string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, found := s.entries[contentURI]
+	if !found {
+		return "", false
+	}
+	s.recency.MoveToFront(e)
+	return e.Value.(publishedHash).Hash, true
+}
+
+func (s *InMemoryPublishedHashStore) Put(contentURI, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, found := s.entries[contentURI]; found {
+		e.Value = publishedHash{ContentURI: contentURI, Hash: hash}
+		s.recency.MoveToFront(e)
+		return nil
+	}
+	s.entries[contentURI] = s.recency.PushFront(publishedHash{ContentURI: contentURI, Hash: hash})
+	if s.recency.Len() > s.capacity {
+		oldest := s.recency.Back()
+		s.recency.Remove(oldest)
+		delete(s.entries, oldest.Value.(publishedHash).ContentURI)
+	}
+	return nil
+}
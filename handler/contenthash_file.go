@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+const minHashLogLinesToCompact = 1000
+
+// FilePublishedHashStore is a PublishedHashStore surviving restarts.
+// Hashes are appended as JSON lines to a log file, which is rewritten with only the latest hash
+// of each content URI once it holds twice as many lines as content URIs.
+type FilePublishedHashStore struct {
+	mu     sync.Mutex
+	path   string
+	log    *os.File
+	hashes map[string]string
+	lines  int
+}
+
+// NewFilePublishedHashStore opens the hash log at path, creating it if needed, and loads the hashes it holds
+func NewFilePublishedHashStore(path string) (*FilePublishedHashStore, error) {
+	s := &FilePublishedHashStore{path: path, hashes: make(map[string]string)}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	logFile, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open published hash log: %v", err)
+	}
+	s.log = logFile
+	return s, nil
+}
+
+func (s *FilePublishedHashStore) load() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't open published hash log: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry publishedHash
+		// a line left incomplete by a crash is skipped, the content is then republished once
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		s.hashes[entry.ContentURI] = entry.Hash
+		s.lines++
+	}
+	return scanner.Err()
+}
+
+func (s *FilePublishedHashStore) Get(contentURI string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hash, found := s.hashes[contentURI]
+	return hash, found
+}
+
+func (s *FilePublishedHashStore) Put(contentURI, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	line, err := json.Marshal(publishedHash{ContentURI: contentURI, Hash: hash})
+	if err != nil {
+		return err
+	}
+	if _, err := s.log.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("couldn't write published hash log: %v", err)
+	}
+	s.hashes[contentURI] = hash
+	s.lines++
+	if s.lines >= minHashLogLinesToCompact && s.lines > 2*len(s.hashes) {
+		return s.compact()
+	}
+	return nil
+}
+
+// compact rewrites the log with one line per content URI and atomically replaces the old one
+func (s *FilePublishedHashStore) compact() error {
+	tmp, err := os.Create(s.path + ".tmp")
+	if err != nil {
+		return fmt.Errorf("couldn't compact published hash log: %v", err)
+	}
+	w := bufio.NewWriter(tmp)
+	for contentURI, hash := range s.hashes {
+		line, _ := json.Marshal(publishedHash{ContentURI: contentURI, Hash: hash})
+		w.Write(append(line, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("couldn't compact published hash log: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("couldn't compact published hash log: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("couldn't compact published hash log: %v", err)
+	}
+
+	s.log.Close()
+	logFile, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("couldn't reopen published hash log: %v", err)
+	}
+	s.log = logFile
+	s.lines = len(s.hashes)
+	return nil
+}
+
+func (s *FilePublishedHashStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.log.Close()
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/Financial-Times/message-queue-gonsumer/consumer"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestContentHash_IgnoresPublicationFields(t *testing.T) {
+	first := &model.UppContentPlaceholder{
+		UppCoreContent: model.UppCoreContent{UUID: "512c1f3d-e48c-4618-863c-94bc9d913b9b", PublishReference: "tid_1", LastModified: "2017-05-15T15:54:32.166Z"},
+		Title:          "Some title",
+	}
+	republished := &model.UppContentPlaceholder{
+		UppCoreContent: model.UppCoreContent{UUID: "512c1f3d-e48c-4618-863c-94bc9d913b9b", PublishReference: "tid_2", LastModified: "2017-05-16T10:00:00.000Z"},
+		Title:          "Some title",
+	}
+	edited := &model.UppContentPlaceholder{
+		UppCoreContent: model.UppCoreContent{UUID: "512c1f3d-e48c-4618-863c-94bc9d913b9b", PublishReference: "tid_3", LastModified: "2017-05-16T10:00:00.000Z"},
+		Title:          "Another title",
+	}
+	deleted := &model.UppContentPlaceholder{
+		UppCoreContent: model.UppCoreContent{UUID: "512c1f3d-e48c-4618-863c-94bc9d913b9b", PublishReference: "tid_4", LastModified: "2017-05-16T10:00:00.000Z", IsMarkedDeleted: true},
+		Title:          "Some title",
+	}
+
+	firstHash, err := contentHash(first)
+	assert.NoError(t, err)
+	republishedHash, _ := contentHash(republished)
+	editedHash, _ := contentHash(edited)
+	deletedHash, _ := contentHash(deleted)
+
+	assert.Equal(t, firstHash, republishedHash)
+	assert.NotEqual(t, firstHash, editedHash)
+	assert.NotEqual(t, firstHash, deletedHash)
+}
+
+func TestInMemoryPublishedHashStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	store := NewInMemoryPublishedHashStore(2)
+	store.Put("http://content/1", "hash1")
+	store.Put("http://content/2", "hash2")
+	store.Get("http://content/1")
+	store.Put("http://content/3", "hash3")
+
+	_, found := store.Get("http://content/2")
+	assert.False(t, found)
+	hash, found := store.Get("http://content/1")
+	assert.True(t, found)
+	assert.Equal(t, "hash1", hash)
+}
+
+func TestFilePublishedHashStore_SurvivesRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "publishedHashes")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "publishedHashes.log")
+
+	store, err := NewFilePublishedHashStore(path)
+	assert.NoError(t, err)
+	assert.NoError(t, store.Put("http://content/1", "hash1"))
+	assert.NoError(t, store.Put("http://content/1", "hash2"))
+	assert.NoError(t, store.Close())
+
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	f.WriteString(`{"contentUri": "http://content/2", "ha`)
+	f.Close()
+
+	reopened, err := NewFilePublishedHashStore(path)
+	assert.NoError(t, err)
+	defer reopened.Close()
+	hash, found := reopened.Get("http://content/1")
+	assert.True(t, found)
+	assert.Equal(t, "hash2", hash)
+	_, found = reopened.Get("http://content/2")
+	assert.False(t, found)
+}
+
+func TestFilePublishedHashStore_Compacts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "publishedHashes")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "publishedHashes.log")
+
+	store, err := NewFilePublishedHashStore(path)
+	assert.NoError(t, err)
+	for i := 1; i < minHashLogLinesToCompact; i++ {
+		assert.NoError(t, store.Put("http://content/1", "hash"))
+	}
+	assert.NoError(t, store.Put("http://content/1", "latest"))
+	assert.NoError(t, store.Close())
+
+	data, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "{\"contentUri\":\"http://content/1\",\"hash\":\"latest\"}\n", string(data))
+}
+
+func TestOnMessageUnchangedContent_NotRepublished(t *testing.T) {
+	sourceMsg := consumer.Message{
+		Headers: map[string]string{
+			"X-Request-Id":      "tid_test123",
+			"Origin-System-Id":  methodeSystemOrigin,
+			"Message-Timestamp": "2017-05-15T15:54:32.166Z",
+		},
+		Body: "",
+	}
+	uppContents := []model.UppContent{
+		&model.UppContentPlaceholder{UppCoreContent: model.UppCoreContent{UUID: "512c1f3d-e48c-4618-863c-94bc9d913b9b", ContentURI: "http://content/"}, Title: "Some title"},
+		&model.UppComplementaryContent{UppCoreContent: model.UppCoreContent{UUID: "512c1f3d-e48c-4618-863c-94bc9d913b9b", ContentURI: "http://complementarycontent/"}},
+	}
+
+	nativeMapper := new(model.MockNativeMapper)
+	nativeMapper.On("Map", mock.Anything).Return(&model.MethodeContentPlaceholder{}, nil)
+	mockedAggregateCPHMapper := new(model.MockCPHAggregateMapper)
+	mockedAggregateCPHMapper.On("MapContentPlaceholder", mock.Anything, "tid_test123", "2017-05-15T15:54:32.166Z").Return(uppContents, nil)
+	mockedMessageCreator := new(model.MockMessageCreator)
+	mockedMessageCreator.On("ToPublicationEventMessage", mock.Anything, mock.Anything, mock.Anything).Return(&producer.Message{Body: "{}"}, nil)
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("SendMessage", mock.Anything, mock.Anything).Return(nil)
+
+	q := NewCPHMessageHandler(nil, mockedProducer, mockedAggregateCPHMapper, nativeMapper, mockedMessageCreator)
+	q.PublishedHashes = NewInMemoryPublishedHashStore(10)
+
+	q.HandleMessage(sourceMsg)
+	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 2)
+
+	q.HandleMessage(sourceMsg)
+	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 2)
+
+	sourceMsg.Headers[ForceRepublishHeader] = "true"
+	q.HandleMessage(sourceMsg)
+	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 4)
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/Financial-Times/message-queue-gonsumer/consumer"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/message"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+)
+
+// Stages at which the processing of a native message can fail
+const (
+	StageNativeMapping      = "native-mapping"
+	StageContentMapping     = "content-mapping"
+	StageMessageCreation    = "message-creation"
+	StagePayloadValidation  = "payload-validation"
+	StageSending            = "sending"
+	StagePartialPublication = "partial-publication"
+	// StageDocStoreUnavailable reports a message given up, unmapped, while waiting for document-store-api on shutdown
+	StageDocStoreUnavailable = "docstore-unavailable"
+	// StageStale reports a message dropped because a later one was already published to its UUID, for auditing
+	StageStale = "stale"
+)
+
+// DeadLetterQueue receives the native messages that could not be mapped or published
+type DeadLetterQueue interface {
+	Send(msg consumer.Message, stage string, cause error, tid string) error
+}
+
+type ProducerDeadLetterQueue struct {
+	producer producer.MessageProducer
+}
+
+func NewProducerDeadLetterQueue(p producer.MessageProducer) *ProducerDeadLetterQueue {
+	return &ProducerDeadLetterQueue{producer: p}
+}
+
+func (q *ProducerDeadLetterQueue) Send(msg consumer.Message, stage string, cause error, tid string) error {
+	event := &model.DeadLetterEvent{
+		Stage:         stage,
+		Error:         cause.Error(),
+		TransactionID: tid,
+		FailedAt:      time.Now().Format(model.UPPDateFormat),
+		OriginalMessage: model.NativeMessage{
+			Headers: msg.Headers,
+			Body:    msg.Body,
+		},
+	}
+	if validationErr, ok := model.AsValidationError(cause); ok {
+		event.Validation = &validationErr.Report
+	}
+	deadLetterMessage, err := message.ToDeadLetterMessage(event)
+	if err != nil {
+		return err
+	}
+	return q.producer.SendMessage("", *deadLetterMessage)
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/Financial-Times/message-queue-gonsumer/consumer"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestProducerDeadLetterQueueSend_Ok(t *testing.T) {
+	sourceMsg := consumer.Message{
+		Headers: map[string]string{
+			"X-Request-Id":     "tid_test123",
+			"Origin-System-Id": methodeSystemOrigin,
+		},
+		Body: "{\"uuid\":\"512c1f3d-e48c-4618-863c-94bc9d913b9b\"}",
+	}
+
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("SendMessage", "", mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(nil)
+
+	q := NewProducerDeadLetterQueue(mockedProducer)
+	err := q.Send(sourceMsg, StageContentMapping, errors.New("Methode Content headline does not contain text"), "tid_test123")
+
+	assert.NoError(t, err)
+	mockedProducer.AssertCalled(t, "SendMessage", "", mock.MatchedBy(func(msg producer.Message) bool {
+		var event model.DeadLetterEvent
+		if err := json.Unmarshal([]byte(msg.Body), &event); err != nil {
+			return false
+		}
+		return msg.Headers["X-Request-Id"] == "tid_test123" &&
+			event.Stage == StageContentMapping &&
+			event.Error == "Methode Content headline does not contain text" &&
+			event.TransactionID == "tid_test123" &&
+			event.OriginalMessage.Body == sourceMsg.Body &&
+			event.OriginalMessage.Headers["Origin-System-Id"] == methodeSystemOrigin
+	}))
+}
+
+func TestProducerDeadLetterQueueSend_ProducerError(t *testing.T) {
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("SendMessage", "", mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(errors.New("Write queue unavailable"))
+
+	q := NewProducerDeadLetterQueue(mockedProducer)
+	err := q.Send(consumer.Message{}, StageSending, errors.New("Write queue unavailable"), "tid_test123")
+
+	assert.Error(t, err)
+}
+
+func TestProducerDeadLetterQueueSend_IncludesValidationReport(t *testing.T) {
+	report := model.ValidationReport{}
+	report.AddError("body.leadHeadline.text", model.CodeMissingHeadlineText, "Methode Content headline does not contain text")
+
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("SendMessage", "", mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(nil)
+
+	q := NewProducerDeadLetterQueue(mockedProducer)
+	err := q.Send(consumer.Message{}, StageContentMapping, report.Err(), "tid_test123")
+
+	assert.NoError(t, err)
+	mockedProducer.AssertCalled(t, "SendMessage", "", mock.MatchedBy(func(msg producer.Message) bool {
+		var event model.DeadLetterEvent
+		if err := json.Unmarshal([]byte(msg.Body), &event); err != nil {
+			return false
+		}
+		return event.Validation != nil && event.Validation.Issues[0].Code == model.CodeMissingHeadlineText
+	}))
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// ProcessedMessageStore remembers the Message-Ids of the native messages processed recently
+type ProcessedMessageStore interface {
+	Seen(messageID string) bool
+	MarkProcessed(messageID string)
+}
+
+// InMemoryProcessedMessageStore is a ProcessedMessageStore forgetting the Message-Ids processed longer than the window ago
+type InMemoryProcessedMessageStore struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[string]*list.Element
+	// processed is ordered by processing time, the oldest at the back
+	processed *list.List
+	now       func() time.Time
+}
+
+type processedMessage struct {
+	messageID   string
+	processedAt time.Time
+}
+
+func NewInMemoryProcessedMessageStore(window time.Duration) *InMemoryProcessedMessageStore {
+	return &InMemoryProcessedMessageStore{
+		window:    window,
+		entries:   make(map[string]*list.Element),
+		processed: list.New(),
+		now:       time.Now,
+	}
+}
+
+func (s *InMemoryProcessedMessageStore) Seen(messageID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire()
+	_, found := s.entries[messageID]
+	return found
+}
+
+func (s *InMemoryProcessedMessageStore) MarkProcessed(messageID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, found := s.entries[messageID]; found {
+		s.processed.Remove(e)
+	}
+	s.entries[messageID] = s.processed.PushFront(processedMessage{messageID: messageID, processedAt: s.now()})
+	s.expire()
+}
+
+func (s *InMemoryProcessedMessageStore) expire() {
+	oldest := s.now().Add(-s.window)
+	for e := s.processed.Back(); e != nil && !e.Value.(processedMessage).processedAt.After(oldest); e = s.processed.Back() {
+		s.processed.Remove(e)
+		delete(s.entries, e.Value.(processedMessage).messageID)
+	}
+}
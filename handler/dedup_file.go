@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// minProcessedLogLinesToCompact is how many lines are appended between two checks of whether the log should be compacted
+const minProcessedLogLinesToCompact = 1000
+
+// FileProcessedMessageStore is a ProcessedMessageStore surviving restarts and shared by the replicas of the service,
+// so that a duplicate is recognised whichever replica consumes it. The Message-Ids are appended to a log file on a volume
+// mounted by every replica, which is compacted to the Message-Ids still in the window once it holds mostly expired ones.
+type FileProcessedMessageStore struct {
+	mu        sync.Mutex
+	window    time.Duration
+	log       *sharedLog
+	processed map[string]time.Time
+	// appended counts the lines appended since the last compaction check
+	appended int
+	now      func() time.Time
+}
+
+type processedMessageRecord struct {
+	MessageID   string    `json:"messageId"`
+	ProcessedAt time.Time `json:"processedAt"`
+}
+
+// NewFileProcessedMessageStore opens the log at path, creating it if needed, and loads the Message-Ids it holds
+func NewFileProcessedMessageStore(path string, window time.Duration) (*FileProcessedMessageStore, error) {
+	sharedLog, err := openSharedLog(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &FileProcessedMessageStore{window: window, log: sharedLog, processed: make(map[string]time.Time), now: time.Now}
+	if err := s.log.follow(s.apply); err != nil {
+		sharedLog.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileProcessedMessageStore) apply(line []byte) {
+	var record processedMessageRecord
+	if err := json.Unmarshal(line, &record); err != nil || record.MessageID == "" {
+		return
+	}
+	if record.ProcessedAt.After(s.processed[record.MessageID]) {
+		s.processed[record.MessageID] = record.ProcessedAt
+	}
+}
+
+// Seen first reads the Message-Ids processed by the other replicas since the last call
+func (s *FileProcessedMessageStore) Seen(messageID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.log.follow(s.apply); err != nil {
+		log.WithField("message_id", messageID).WithError(err).Warn("Couldn't read the Message-Ids processed by the other replicas")
+	}
+	processedAt, found := s.processed[messageID]
+	return found && processedAt.After(s.now().Add(-s.window))
+}
+
+func (s *FileProcessedMessageStore) MarkProcessed(messageID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record := processedMessageRecord{MessageID: messageID, ProcessedAt: s.now()}
+	if err := s.log.append(record); err != nil {
+		log.WithField("message_id", messageID).WithError(err).Warn("Couldn't record the processed Message-Id, its duplicates on other replicas or after a restart won't be ignored")
+	}
+	s.processed[messageID] = record.ProcessedAt
+	s.appended++
+	if s.appended < minProcessedLogLinesToCompact {
+		return
+	}
+	s.appended = 0
+	if err := s.log.follow(s.apply); err != nil {
+		log.WithError(err).Warn("Couldn't read the Message-Ids processed by the other replicas")
+		return
+	}
+	s.expire()
+	if s.log.lines > 2*len(s.processed) {
+		if err := s.log.compact(s.apply, s.live); err != nil {
+			log.WithError(err).Warn("Couldn't compact the processed Message-Id log")
+		}
+	}
+}
+
+func (s *FileProcessedMessageStore) expire() {
+	oldest := s.now().Add(-s.window)
+	for messageID, processedAt := range s.processed {
+		if !processedAt.After(oldest) {
+			delete(s.processed, messageID)
+		}
+	}
+}
+
+func (s *FileProcessedMessageStore) live() []interface{} {
+	s.expire()
+	records := make([]interface{}, 0, len(s.processed))
+	for messageID, processedAt := range s.processed {
+		records = append(records, processedMessageRecord{MessageID: messageID, ProcessedAt: processedAt})
+	}
+	return records
+}
+
+func (s *FileProcessedMessageStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.log.close()
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestInMemoryProcessedMessageStore_ForgetsAfterWindow(t *testing.T) {
+	now := time.Date(2017, 5, 15, 15, 54, 32, 0, time.UTC)
+	store := NewInMemoryProcessedMessageStore(time.Minute)
+	store.now = func() time.Time { return now }
+
+	store.MarkProcessed("message1")
+	assert.True(t, store.Seen("message1"))
+	assert.False(t, store.Seen("message2"))
+
+	now = now.Add(59 * time.Second)
+	assert.True(t, store.Seen("message1"))
+
+	now = now.Add(time.Second)
+	assert.False(t, store.Seen("message1"))
+	assert.Empty(t, store.entries)
+}
+
+func TestFileProcessedMessageStore_SurvivesRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "processedMessages")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "processedMessages.log")
+
+	store, err := NewFileProcessedMessageStore(path, time.Minute)
+	assert.NoError(t, err)
+	store.MarkProcessed("message1")
+	assert.NoError(t, store.Close())
+
+	reopened, err := NewFileProcessedMessageStore(path, time.Minute)
+	assert.NoError(t, err)
+	defer reopened.Close()
+	assert.True(t, reopened.Seen("message1"))
+	assert.False(t, reopened.Seen("message2"))
+
+	reopened.now = func() time.Time { return time.Now().Add(time.Minute) }
+	assert.False(t, reopened.Seen("message1"))
+}
+
+func TestFileProcessedMessageStore_SharedByReplicas(t *testing.T) {
+	dir, err := ioutil.TempDir("", "processedMessages")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "processedMessages.log")
+	replica1, err := NewFileProcessedMessageStore(path, time.Minute)
+	assert.NoError(t, err)
+	defer replica1.Close()
+	replica2, err := NewFileProcessedMessageStore(path, time.Minute)
+	assert.NoError(t, err)
+	defer replica2.Close()
+
+	replica1.MarkProcessed("message1")
+	assert.True(t, replica2.Seen("message1"))
+
+	for i := 0; i < minProcessedLogLinesToCompact; i++ {
+		replica2.MarkProcessed("message2")
+	}
+	replica1.MarkProcessed("message3")
+
+	assert.True(t, replica1.Seen("message2"))
+	assert.True(t, replica2.Seen("message3"))
+	assert.True(t, replica2.Seen("message1"))
+	data, _ := ioutil.ReadFile(path)
+	assert.Equal(t, 3, strings.Count(string(data), "\n"), "the log should have been compacted to one line per Message-Id")
+}
+
+func TestOnMessageDuplicateMessageId_ProcessedOnce(t *testing.T) {
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(nil)
+
+	q := newPairPublicationHandler(mockedProducer)
+	q.ProcessedMessages = NewInMemoryProcessedMessageStore(time.Minute)
+	msg := pairSourceMessage()
+	msg.Headers["Message-Id"] = "7c4b9e60-3a8c-4b8f-9cbb-0e4a2f1c1a11"
+
+	q.HandleMessage(msg)
+	q.HandleMessage(msg)
+
+	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 2)
+}
+
+func TestOnMessageDuplicateOfFailedMessage_ProcessedAgain(t *testing.T) {
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(errors.New("Write queue unavailable"))
+
+	q := newPairPublicationHandler(mockedProducer)
+	q.ProcessedMessages = NewInMemoryProcessedMessageStore(time.Minute)
+	msg := pairSourceMessage()
+	msg.Headers["Message-Id"] = "7c4b9e60-3a8c-4b8f-9cbb-0e4a2f1c1a11"
+
+	q.HandleMessage(msg)
+	q.HandleMessage(msg)
+
+	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 2)
+	assert.False(t, q.ProcessedMessages.Seen("7c4b9e60-3a8c-4b8f-9cbb-0e4a2f1c1a11"))
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newInvalidPayloadHandler(t *testing.T, mockedProducer *model.MockProducer, strict bool) *CPHMessageHandler {
+	uppContents := []model.UppContent{
+		&model.UppContentPlaceholder{
+			UppCoreContent: model.UppCoreContent{
+				UUID:             "512c1f3d-e48c-4618-863c-94bc9d913b9b",
+				PublishReference: "tid_test123",
+				LastModified:     "2017-05-15T15:54:32.166Z",
+				ContentURI:       "http://methode-content-placeholder-mapper-iw-uk-p.svc.ft.com/content/",
+			},
+			Title: "Some title",
+		},
+	}
+
+	nativeMapper := new(model.MockNativeMapper)
+	nativeMapper.On("Map", mock.Anything).Return(&model.MethodeContentPlaceholder{UUID: "512c1f3d-e48c-4618-863c-94bc9d913b9b"}, nil)
+	mockedAggregateCPHMapper := new(model.MockCPHAggregateMapper)
+	mockedAggregateCPHMapper.On("MapContentPlaceholder", mock.Anything, "tid_test123", "2017-05-15T15:54:32.166Z").Return(uppContents, nil)
+	mockedMessageCreator := new(model.MockMessageCreator)
+	mockedMessageCreator.On("ToPublicationEventMessage", mock.Anything, mock.Anything, mock.Anything).Return(&producer.Message{Body: "{}"}, nil)
+
+	payloadSchemas, err := schema.ReadPayloadSchemas("../schemas")
+	assert.NoError(t, err)
+	q := NewCPHMessageHandler(nil, mockedProducer, mockedAggregateCPHMapper, nativeMapper, mockedMessageCreator)
+	q.PayloadSchemas = payloadSchemas
+	q.StrictPayloadValidation = strict
+	return q
+}
+
+func TestOnMessageInvalidPayloadStrict_NotSentAndDeadLettered(t *testing.T) {
+	sourceMsg := pairSourceMessage()
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("SendMessage", mock.Anything, mock.Anything).Return(nil)
+	deadLetterQueue := new(model.MockDeadLetterQueue)
+	deadLetterQueue.On("Send", sourceMsg, StagePayloadValidation, mock.MatchedBy(func(err error) bool {
+		validationErr, ok := model.AsValidationError(err)
+		return ok && validationErr.Report.HasErrors() && validationErr.Report.Issues[0].Code == model.CodeSchemaViolation
+	}), "tid_test123").Return(nil)
+
+	q := newInvalidPayloadHandler(t, mockedProducer, true)
+	q.DeadLetterQueue = deadLetterQueue
+	q.HandleMessage(sourceMsg)
+
+	mockedProducer.AssertNotCalled(t, "SendMessage", mock.Anything, mock.Anything)
+	deadLetterQueue.AssertNumberOfCalls(t, "Send", 1)
+}
+
+func TestOnMessageInvalidPayloadLenient_SentAnyway(t *testing.T) {
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("SendMessage", mock.Anything, mock.Anything).Return(nil)
+	deadLetterQueue := new(model.MockDeadLetterQueue)
+
+	q := newInvalidPayloadHandler(t, mockedProducer, false)
+	q.DeadLetterQueue = deadLetterQueue
+	q.HandleMessage(pairSourceMessage())
+
+	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 1)
+	deadLetterQueue.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	log "github.com/Sirupsen/logrus"
+)
+
+// PublicationLog keeps the outcome of the latest publication for each UUID
+type PublicationLog interface {
+	Record(outcome model.PublicationOutcome)
+	Get(uuid string) (model.PublicationOutcome, bool)
+}
+
+type publicationMessage struct {
+	uuid       string
+	contentURI string
+	hash       string
+	timestamp  time.Time
+	key        string
+	producer   producer.MessageProducer
+	message    *producer.Message
+}
+
+// publish sends the messages mapped from one placeholder as a unit, retrying every message on failure,
+// and returns how many were sent. When only part of them could be sent the placeholder is dead-lettered
+// by the caller, so that re-driving it republishes the content and complementary content together.
+func (kqh *CPHMessageHandler) publish(ctx context.Context, tid string, messages []publicationMessage) (int, error) {
+	sent := 0
+	var err error
+	for _, m := range messages {
+		err = kqh.RetryPolicy.Do(ctx, tid, func() error {
+			if sendErr := m.producer.SendMessage(m.key, *m.message); sendErr != nil {
+				return model.NewTransientError(sendErr.Error())
+			}
+			return nil
+		})
+		if err != nil {
+			break
+		}
+		sent++
+		log.WithField("transaction_id", tid).WithField("uuid", m.uuid).Info("Content mapped and sent to the queue")
+		kqh.advancePublishedTimestamp(m.uuid, m.timestamp)
+		if kqh.PublishedHashes != nil && m.hash != "" {
+			if hashErr := kqh.PublishedHashes.Put(m.contentURI, m.hash); hashErr != nil {
+				log.WithField("transaction_id", tid).WithField("content_uri", m.contentURI).WithError(hashErr).Warn("Couldn't record the hash of the published content")
+			}
+		}
+	}
+
+	kqh.recordPublication(tid, messages, sent, err)
+
+	if err != nil && sent > 0 {
+		return sent, fmt.Errorf("only %d of %d messages were sent: %w", sent, len(messages), err)
+	}
+	return sent, err
+}
+
+func (kqh *CPHMessageHandler) recordPublication(tid string, messages []publicationMessage, sent int, err error) {
+	status := model.PublicationComplete
+	if err != nil {
+		status = model.PublicationFailed
+		if sent > 0 {
+			status = model.PublicationIncomplete
+		}
+	}
+
+	outcomes := make(map[string]*model.PublicationOutcome)
+	var uuids []string
+	for i, m := range messages {
+		outcome, found := outcomes[m.uuid]
+		if !found {
+			outcome = &model.PublicationOutcome{
+				UUID:          m.uuid,
+				TransactionID: tid,
+				Status:        status,
+				Published:     []string{},
+				Unpublished:   []string{},
+				RecordedAt:    time.Now().Format(model.UPPDateFormat),
+			}
+			if err != nil {
+				outcome.Error = err.Error()
+			}
+			outcomes[m.uuid] = outcome
+			uuids = append(uuids, m.uuid)
+		}
+		if i < sent {
+			outcome.Published = append(outcome.Published, m.contentURI)
+		} else {
+			outcome.Unpublished = append(outcome.Unpublished, m.contentURI)
+		}
+	}
+
+	for _, uuid := range uuids {
+		outcome := outcomes[uuid]
+		if status != model.PublicationComplete {
+			log.WithField("transaction_id", tid).WithField("uuid", uuid).WithField("status", status).WithField("unpublished", outcome.Unpublished).Warn("Publication of mapped content did not complete")
+		}
+		if kqh.PublicationLog != nil {
+			kqh.PublicationLog.Record(*outcome)
+		}
+	}
+}
+
+// staleContents collects, per UUID, the content URIs that were not sent because the message is older than the last one published
+type staleContents struct {
+	uuids       []string
+	contentURIs map[string][]string
+	latest      map[string]time.Time
+}
+
+func newStaleContents() *staleContents {
+	return &staleContents{contentURIs: make(map[string][]string), latest: make(map[string]time.Time)}
+}
+
+func (s *staleContents) add(uuid, contentURI string, latest time.Time) {
+	if _, found := s.contentURIs[uuid]; !found {
+		s.uuids = append(s.uuids, uuid)
+	}
+	s.contentURIs[uuid] = append(s.contentURIs[uuid], contentURI)
+	s.latest[uuid] = latest
+}
+
+// err describes the contents that were not sent
+func (s *staleContents) err() error {
+	var reasons []string
+	for _, uuid := range s.uuids {
+		reasons = append(reasons, fmt.Sprintf("uuid=%v is older than the message published at %v", uuid, s.latest[uuid].Format(model.UPPDateFormat)))
+	}
+	return fmt.Errorf("message not sent for %v", strings.Join(reasons, ", "))
+}
+
+func (kqh *CPHMessageHandler) recordStale(tid string, stale *staleContents) {
+	if kqh.PublicationLog == nil {
+		return
+	}
+	for _, uuid := range stale.uuids {
+		kqh.PublicationLog.Record(model.PublicationOutcome{
+			UUID:          uuid,
+			TransactionID: tid,
+			Status:        model.PublicationStale,
+			Published:     []string{},
+			Unpublished:   stale.contentURIs[uuid],
+			Error:         "message is older than the one published at " + stale.latest[uuid].Format(model.UPPDateFormat),
+			RecordedAt:    time.Now().Format(model.UPPDateFormat),
+		})
+	}
+}
+
+// InMemoryPublicationLog is a PublicationLog keeping the outcomes of the most recently published UUIDs
+type InMemoryPublicationLog struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	recency  *list.List
+}
+
+func NewInMemoryPublicationLog(capacity int) *InMemoryPublicationLog {
+	return &InMemoryPublicationLog{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		recency:  list.New(),
+	}
+}
+
+func (l *InMemoryPublicationLog) Record(outcome model.PublicationOutcome) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, found := l.entries[outcome.UUID]; found {
+		e.Value = outcome
+		l.recency.MoveToFront(e)
+		return
+	}
+	l.entries[outcome.UUID] = l.recency.PushFront(outcome)
+	if l.recency.Len() > l.capacity {
+		oldest := l.recency.Back()
+		l.recency.Remove(oldest)
+		delete(l.entries, oldest.Value.(model.PublicationOutcome).UUID)
+	}
+}
+
+func (l *InMemoryPublicationLog) Get(uuid string) (model.PublicationOutcome, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, found := l.entries[uuid]
+	if !found {
+		return model.PublicationOutcome{}, false
+	}
+	return e.Value.(model.PublicationOutcome), true
+}
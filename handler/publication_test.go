@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/Financial-Times/message-queue-gonsumer/consumer"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/message"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newPairPublicationHandler(mockedProducer *model.MockProducer) *CPHMessageHandler {
+	uppContents := []model.UppContent{
+		&model.UppCoreContent{
+			UUID:       "512c1f3d-e48c-4618-863c-94bc9d913b9b",
+			ContentURI: "http://methode-content-placeholder-mapper-iw-uk-p.svc.ft.com/content/",
+		},
+		&model.UppCoreContent{
+			UUID:       "512c1f3d-e48c-4618-863c-94bc9d913b9b",
+			ContentURI: "http://methode-content-placeholder-mapper-iw-uk-p.svc.ft.com/complementarycontent/",
+		},
+	}
+
+	nativeMapper := new(model.MockNativeMapper)
+	nativeMapper.On("Map", mock.MatchedBy(func(messageBody []byte) bool { return true })).Return(&model.MethodeContentPlaceholder{UUID: "512c1f3d-e48c-4618-863c-94bc9d913b9b"}, nil)
+
+	mockedAggregateCPHMapper := new(model.MockCPHAggregateMapper)
+	mockedAggregateCPHMapper.On("MapContentPlaceholder", mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true }), "tid_test123", "2017-05-15T15:54:32.166Z").Return(uppContents, nil)
+
+	mockedMessageCreator := new(model.MockMessageCreator)
+	mockedMessageCreator.On("ToPublicationEventMessage", mock.MatchedBy(func(c *model.UppCoreContent) bool { return true }), mock.MatchedBy(func(p interface{}) bool { return true }), mock.Anything).
+		Return(&producer.Message{Body: "{}", Headers: map[string]string{"X-Request-Id": "tid_test123"}}, nil)
+
+	q := NewCPHMessageHandler(nil, mockedProducer, mockedAggregateCPHMapper, nativeMapper, mockedMessageCreator)
+	q.PublicationLog = NewInMemoryPublicationLog(10)
+	return q
+}
+
+func pairSourceMessage() consumer.Message {
+	return consumer.Message{
+		Headers: map[string]string{
+			"X-Request-Id":      "tid_test123",
+			"Origin-System-Id":  methodeSystemOrigin,
+			"Message-Timestamp": "2017-05-15T15:54:32.166Z",
+		},
+	}
+}
+
+func TestPublishPair_BothSentAndRecorded(t *testing.T) {
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(nil)
+
+	q := newPairPublicationHandler(mockedProducer)
+	q.HandleMessage(pairSourceMessage())
+
+	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 2)
+	outcome, found := q.PublicationLog.Get("512c1f3d-e48c-4618-863c-94bc9d913b9b")
+	assert.True(t, found)
+	assert.Equal(t, model.PublicationComplete, outcome.Status)
+	assert.Equal(t, "tid_test123", outcome.TransactionID)
+	assert.Len(t, outcome.Published, 2)
+	assert.Empty(t, outcome.Unpublished)
+}
+
+func TestPublishPair_SecondMessageRetried(t *testing.T) {
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(nil).Once()
+	mockedProducer.On("SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(errors.New("Write queue unavailable")).Once()
+	mockedProducer.On("SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(nil).Once()
+
+	deadLetterQueue := new(model.MockDeadLetterQueue)
+
+	q := newPairPublicationHandler(mockedProducer)
+	q.RetryPolicy = RetryPolicy{MaxAttempts: 3}
+	q.DeadLetterQueue = deadLetterQueue
+	q.HandleMessage(pairSourceMessage())
+
+	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 3)
+	deadLetterQueue.AssertNumberOfCalls(t, "Send", 0)
+	outcome, _ := q.PublicationLog.Get("512c1f3d-e48c-4618-863c-94bc9d913b9b")
+	assert.Equal(t, model.PublicationComplete, outcome.Status)
+}
+
+func TestPublishPair_SecondMessageFailsIsRecordedAndDeadLettered(t *testing.T) {
+	sourceMsg := pairSourceMessage()
+
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(nil).Once()
+	mockedProducer.On("SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(errors.New("Write queue unavailable"))
+
+	deadLetterQueue := new(model.MockDeadLetterQueue)
+	deadLetterQueue.On("Send", sourceMsg, StagePartialPublication, mock.MatchedBy(func(err error) bool { return true }), "tid_test123").Return(nil)
+
+	q := newPairPublicationHandler(mockedProducer)
+	q.RetryPolicy = RetryPolicy{MaxAttempts: 2}
+	q.DeadLetterQueue = deadLetterQueue
+	q.HandleMessage(sourceMsg)
+
+	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 3)
+	deadLetterQueue.AssertNumberOfCalls(t, "Send", 1)
+	outcome, _ := q.PublicationLog.Get("512c1f3d-e48c-4618-863c-94bc9d913b9b")
+	assert.Equal(t, model.PublicationIncomplete, outcome.Status)
+	assert.Equal(t, []string{"http://methode-content-placeholder-mapper-iw-uk-p.svc.ft.com/content/512c1f3d-e48c-4618-863c-94bc9d913b9b"}, outcome.Published)
+	assert.Equal(t, []string{"http://methode-content-placeholder-mapper-iw-uk-p.svc.ft.com/complementarycontent/512c1f3d-e48c-4618-863c-94bc9d913b9b"}, outcome.Unpublished)
+	assert.Contains(t, outcome.Error, "Write queue unavailable")
+}
+
+func TestPublishPair_FirstMessageFailsNothingSent(t *testing.T) {
+	sourceMsg := pairSourceMessage()
+
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(errors.New("Write queue unavailable"))
+
+	deadLetterQueue := new(model.MockDeadLetterQueue)
+	deadLetterQueue.On("Send", sourceMsg, StageSending, mock.MatchedBy(func(err error) bool { return true }), "tid_test123").Return(nil)
+
+	q := newPairPublicationHandler(mockedProducer)
+	q.DeadLetterQueue = deadLetterQueue
+	q.HandleMessage(sourceMsg)
+
+	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 1)
+	deadLetterQueue.AssertNumberOfCalls(t, "Send", 1)
+	outcome, _ := q.PublicationLog.Get("512c1f3d-e48c-4618-863c-94bc9d913b9b")
+	assert.Equal(t, model.PublicationFailed, outcome.Status)
+	assert.Empty(t, outcome.Published)
+	assert.Len(t, outcome.Unpublished, 2)
+}
+
+func TestInMemoryPublicationLog_EvictsLeastRecentlyRecorded(t *testing.T) {
+	publications := NewInMemoryPublicationLog(2)
+	publications.Record(model.PublicationOutcome{UUID: "uuid-1", Status: model.PublicationComplete})
+	publications.Record(model.PublicationOutcome{UUID: "uuid-2", Status: model.PublicationComplete})
+	publications.Record(model.PublicationOutcome{UUID: "uuid-1", Status: model.PublicationFailed})
+	publications.Record(model.PublicationOutcome{UUID: "uuid-3", Status: model.PublicationComplete})
+
+	_, found := publications.Get("uuid-2")
+	assert.False(t, found)
+	outcome, found := publications.Get("uuid-1")
+	assert.True(t, found)
+	assert.Equal(t, model.PublicationFailed, outcome.Status)
+	_, found = publications.Get("uuid-3")
+	assert.True(t, found)
+}
+
+func TestPublishPair_SharesThePartitionKey(t *testing.T) {
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(nil)
+
+	q := newPairPublicationHandler(mockedProducer)
+	q.HandleMessage(pairSourceMessage())
+
+	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 2)
+	for _, call := range mockedProducer.Calls {
+		assert.Equal(t, "512c1f3d-e48c-4618-863c-94bc9d913b9b", call.Arguments.String(0))
+	}
+}
+
+func TestPublishPair_KeyedByConfiguredStrategy(t *testing.T) {
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(nil)
+
+	q := newPairPublicationHandler(mockedProducer)
+	q.KeyStrategy = message.NoKey
+	q.HandleMessage(pairSourceMessage())
+
+	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 2)
+	mockedProducer.AssertCalled(t, "SendMessage", "", mock.Anything)
+}
+
+func TestPublishPair_ComplementaryContentRoutedToItsTopic(t *testing.T) {
+	defaultProducer := new(model.MockProducer)
+	defaultProducer.On("SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(nil)
+	complementaryProducer := new(model.MockProducer)
+	complementaryProducer.On("SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(nil)
+
+	q := newPairPublicationHandler(defaultProducer)
+	q.Producers = message.NewProducerRouter(defaultProducer, map[string]producer.MessageProducer{"complementarycontent": complementaryProducer})
+	q.HandleMessage(pairSourceMessage())
+
+	defaultProducer.AssertNumberOfCalls(t, "SendMessage", 1)
+	complementaryProducer.AssertNumberOfCalls(t, "SendMessage", 1)
+}
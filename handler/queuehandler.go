@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"context"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -12,6 +14,7 @@ import (
 	"github.com/Financial-Times/methode-content-placeholder-mapper/mapper"
 	"github.com/Financial-Times/methode-content-placeholder-mapper/message"
 	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/schema"
 	log "github.com/Sirupsen/logrus"
 )
 
@@ -20,8 +23,47 @@ type MessageHandler interface {
 	StartHandlingMessages()
 }
 
+// DependencyGate blocks until a dependency is available again, e.g. until a circuit breaker lets calls through,
+// or until the context is done
+type DependencyGate interface {
+	WaitUntilAvailable(ctx context.Context) error
+}
+
 type CPHMessageHandler struct {
 	MessageConsumer consumer.MessageConsumer
+	// DeadLetterQueue, when set, receives every message that fails mapping or publishing
+	DeadLetterQueue DeadLetterQueue
+	// RetryPolicy is applied when mapping fails because of a transient document-store-api error and when sending fails
+	RetryPolicy RetryPolicy
+	// PublicationLog, when set, records the outcome of every publication per UUID
+	PublicationLog PublicationLog
+	// WorkerPool, when set, is the pool the consumed messages are submitted to and is drained on shutdown
+	WorkerPool *OrderedWorkerPool
+	// PublishedHashes, when set, suppresses the messages whose content didn't change since it was last published
+	PublishedHashes PublishedHashStore
+	// PublishedTimestamps, when set, drops the messages older than the one last published to the same UUID
+	PublishedTimestamps PublishedTimestampStore
+	// Producers, when set, chooses the producer of each message from the collection it publishes to instead of the default producer
+	Producers *message.ProducerRouter
+	// PayloadSchemas, when set, validates the payload of every mapped content against its schema before it is published
+	PayloadSchemas *schema.PayloadValidator
+	// StrictPayloadValidation refuses to publish a placeholder whose payloads violate their schemas, instead of only reporting it
+	StrictPayloadValidation bool
+	// KeyStrategy chooses the key the messages are produced with, the UUID of the content by default
+	KeyStrategy message.KeyStrategy
+	// ProcessedMessages, when set, ignores the native messages whose Message-Id was already processed
+	ProcessedMessages ProcessedMessageStore
+	// DocStoreGate, when set, is waited on before processing each message, pausing the consumption while document-store-api is unavailable
+	DocStoreGate DependencyGate
+	// MessageTimeout, when set, is the deadline of the mapping of each message, its retries included
+	MessageTimeout time.Duration
+	// BrandMappings, when set, normalises the blog identifiers OrderingKeys orders blog placeholders on
+	BrandMappings mapper.BrandMappingMatcher
+
+	// ctx is canceled on shutdown, abandoning the document-store-api lookups in progress
+	ctx    context.Context
+	cancel context.CancelFunc
+
 	messageProducer producer.MessageProducer
 	nativeMapper    mapper.MessageToContentPlaceholderMapper
 	cphMapper       mapper.CPHAggregateMapper
@@ -34,7 +76,10 @@ func NewCPHMessageHandler(c consumer.MessageConsumer,
 	nativeMapper mapper.MessageToContentPlaceholderMapper,
 	messageCreator message.MessageCreator) *CPHMessageHandler {
 
+	ctx, cancel := context.WithCancel(context.Background())
 	return &CPHMessageHandler{
+		ctx:             ctx,
+		cancel:          cancel,
 		MessageConsumer: c,
 		messageProducer: p,
 		nativeMapper:    nativeMapper,
@@ -44,48 +89,278 @@ func NewCPHMessageHandler(c consumer.MessageConsumer,
 }
 
 func (kqh *CPHMessageHandler) HandleMessage(msg consumer.Message) {
+	kqh.handleMessage(msg, nil)
+}
+
+// HandleParsedMessage processes a message with what OrderingKeys parsed of it
+func (kqh *CPHMessageHandler) HandleParsedMessage(msg consumer.Message, parsed interface{}) {
+	native, _ := parsed.(*nativeMessage)
+	kqh.handleMessage(msg, native)
+}
+
+// nativeMessage is the outcome of mapping the body of a native message
+type nativeMessage struct {
+	placeholder *model.MethodeContentPlaceholder
+	err         error
+}
+
+func (kqh *CPHMessageHandler) mapNativeMessage(msg consumer.Message) *nativeMessage {
+	placeholder, err := kqh.nativeMapper.Map([]byte(msg.Body))
+	return &nativeMessage{placeholder: placeholder, err: err}
+}
+
+// handleMessage processes a message, mapping its body unless native already holds the outcome
+func (kqh *CPHMessageHandler) handleMessage(msg consumer.Message, native *nativeMessage) {
 	tid := msg.Headers["X-Request-Id"]
 	if msg.Headers["Origin-System-Id"] != model.MethodeSystemID {
 		log.WithField("transaction_id", tid).WithField("Origin-System-Id", msg.Headers["Origin-System-Id"]).Info("Ignoring message with different Origin-System-Id")
 		return
 	}
 
+	messageID := msg.Headers["Message-Id"]
+	if kqh.ProcessedMessages != nil && messageID != "" && kqh.ProcessedMessages.Seen(messageID) {
+		log.WithField("transaction_id", tid).WithField("message_id", messageID).Info("Ignoring message already processed")
+		return
+	}
+	if kqh.DocStoreGate != nil {
+		if err := kqh.DocStoreGate.WaitUntilAvailable(kqh.ctx); err != nil {
+			// the message isn't mapped nor remembered as processed, so that it is processed when re-driven
+			log.WithField("transaction_id", tid).WithError(err).Warn("Stopped waiting for document-store-api, shutting down")
+			kqh.deadLetter(msg, StageDocStoreUnavailable, err, tid)
+			return
+		}
+	}
+	// failed messages are not remembered, so that they are processed again when re-driven from the dead-letter topic
+	if kqh.processMessage(kqh.ctx, msg, native, tid) && kqh.ProcessedMessages != nil && messageID != "" {
+		kqh.ProcessedMessages.MarkProcessed(messageID)
+	}
+}
+
+// processMessage maps and publishes a native message and returns false when it failed and was dead-lettered
+func (kqh *CPHMessageHandler) processMessage(ctx context.Context, msg consumer.Message, native *nativeMessage, tid string) bool {
 	lmd, ok := msg.Headers["Message-Timestamp"]
 	if !ok {
 		lmd = time.Now().Format(model.UPPDateFormat)
 	}
-	methodePlaceholder, err := kqh.nativeMapper.Map([]byte(msg.Body))
+	timestamp, hasTimestamp := parseMessageTimestamp(lmd)
+	if !hasTimestamp {
+		log.WithField("transaction_id", tid).WithField("message_timestamp", lmd).Warn("Unparseable Message-Timestamp, the message is published whether it is stale or not")
+	}
+	if native == nil {
+		native = kqh.mapNativeMessage(msg)
+	}
+	methodePlaceholder, err := native.placeholder, native.err
 
 	if err != nil {
 		if _, ok := err.(*model.InvalidMethodeCPH); ok {
 			log.WithField("transaction_id", tid).WithError(err).Infof(err.Error())
 		} else {
-			log.WithField("transaction_id", tid).WithError(err).Error("Error creating methode model from queue message")
+			logWithValidationIssues(log.WithField("transaction_id", tid), err).WithError(err).Error("Error creating methode model from queue message")
+			kqh.deadLetter(msg, StageNativeMapping, err, tid)
+			return false
 		}
-		return
+		return true
 	}
 
-	transformedContents, err := kqh.cphMapper.MapContentPlaceholder(methodePlaceholder, tid, lmd)
+	mapCtx := ctx
+	if kqh.MessageTimeout > 0 {
+		var cancel context.CancelFunc
+		mapCtx, cancel = context.WithTimeout(ctx, kqh.MessageTimeout)
+		defer cancel()
+	}
+	var transformedContents []model.UppContent
+	err = kqh.RetryPolicy.Do(mapCtx, tid, func() error {
+		var mapErr error
+		transformedContents, mapErr = kqh.cphMapper.MapContentPlaceholder(mapCtx, methodePlaceholder, tid, lmd)
+		return mapErr
+	})
+	if _, ok := err.(*model.InvalidMethodeCPH); ok {
+		log.WithField("transaction_id", tid).WithField("uuid", methodePlaceholder.UUID).Info(err.Error())
+		return true
+	}
 	if err != nil {
-		log.WithField("transaction_id", tid).WithError(err).Error("Error transforming content")
-		return
+		logWithValidationIssues(log.WithField("transaction_id", tid).WithField("uuid", methodePlaceholder.UUID), err).WithError(err).Error("Error transforming content")
+		kqh.deadLetter(msg, StageContentMapping, err, tid)
+		return false
 	}
 
+	if err := kqh.validatePayloads(tid, transformedContents); err != nil {
+		entry := logWithValidationIssues(log.WithField("transaction_id", tid).WithField("uuid", methodePlaceholder.UUID), err).WithError(err)
+		if kqh.StrictPayloadValidation {
+			entry.Error("Mapped content doesn't conform to the payload schemas, not sent")
+			kqh.deadLetter(msg, StagePayloadValidation, err, tid)
+			return false
+		}
+		entry.Warn("Mapped content doesn't conform to the payload schemas")
+	}
+
+	// all the messages are created before any is sent, so that a placeholder is either published as a whole or not at all
+	force := strings.EqualFold(msg.Headers[ForceRepublishHeader], "true")
+	var messages []publicationMessage
+	stale := newStaleContents()
 	for _, transformedContent := range transformedContents {
-		eventMessage, err := kqh.messageCreator.ToPublicationEventMessage(transformedContent.GetUppCoreContent(), transformedContent)
+		contentURI := transformedContent.GetUppCoreContent().ContentURI + transformedContent.GetUUID()
+		if hasTimestamp {
+			if latest, isStale := kqh.checkStale(transformedContent.GetUUID(), timestamp); isStale {
+				log.WithField("transaction_id", tid).WithField("uuid", transformedContent.GetUUID()).WithField("content_uri", contentURI).
+					WithField("message_timestamp", lmd).WithField("last_published_timestamp", latest.Format(model.UPPDateFormat)).
+					Warn("Message older than the last one published, not sent")
+				stale.add(transformedContent.GetUUID(), contentURI, latest)
+				continue
+			}
+		}
+		hash, unchanged := kqh.checkPublishedHash(tid, contentURI, transformedContent)
+		if unchanged && !force {
+			log.WithField("transaction_id", tid).WithField("uuid", transformedContent.GetUUID()).WithField("content_uri", contentURI).Info("Content unchanged since last published, not sent")
+			if hasTimestamp {
+				kqh.advancePublishedTimestamp(transformedContent.GetUUID(), timestamp)
+			}
+			continue
+		}
+		eventMessage, err := kqh.messageCreator.ToPublicationEventMessage(transformedContent.GetUppCoreContent(), transformedContent, msg.Headers)
 		if err != nil {
 			log.WithField("transaction_id", tid).WithField("uuid", transformedContent.GetUUID()).WithError(err).Warn("Error creating transformed content message to queue")
-			return
+			kqh.deadLetter(msg, StageMessageCreation, err, tid)
+			return false
 		}
+		messages = append(messages, publicationMessage{
+			uuid:       transformedContent.GetUUID(),
+			contentURI: contentURI,
+			hash:       hash,
+			timestamp:  timestamp,
+			key:        kqh.messageKey(transformedContent.GetUppCoreContent()),
+			producer:   kqh.producerFor(transformedContent.GetUppCoreContent()),
+			message:    eventMessage,
+		})
+	}
+	kqh.recordStale(tid, stale)
+	if len(stale.uuids) > 0 {
+		kqh.deadLetter(msg, StageStale, stale.err(), tid)
+	}
 
-		rawErr := kqh.messageProducer.SendMessage("", *eventMessage)
-		if rawErr != nil {
-			log.WithField("transaction_id", tid).WithField("uuid", transformedContent.GetUUID()).WithError(err).Warn("Error sending transformed content message to queue")
-			return
+	sent, err := kqh.publish(ctx, tid, messages)
+	if err != nil {
+		log.WithField("transaction_id", tid).WithField("uuid", methodePlaceholder.UUID).WithError(err).Warn("Error sending transformed content message to queue")
+		stage := StageSending
+		if sent > 0 {
+			stage = StagePartialPublication
 		}
+		kqh.deadLetter(msg, stage, err, tid)
+		return false
+	}
+	return true
+}
+
+// Context returns the context of the handler, canceled when it shuts down
+func (kqh *CPHMessageHandler) Context() context.Context {
+	return kqh.ctx
+}
 
-		log.WithField("transaction_id", tid).WithField("uuid", transformedContent.GetUUID()).Info("Content mapped and sent to the queue")
+// OrderingKeys returns the UUIDs a message publishes to, as far as they can be known without calling document-store-api:
+// the Methode UUID, the OriginalUUID of generic placeholders and the blog identifier of blog placeholders,
+// with the mapped body to pass on to HandleParsedMessage.
+// Blog placeholders are ordered on their serviceid and ref_field as written, not on the UUID they resolve to,
+// so two placeholders spelling the blog URL differently are not ordered with each other although they publish to the same UUID.
+func (kqh *CPHMessageHandler) OrderingKeys(msg consumer.Message) ([]string, interface{}) {
+	native := kqh.mapNativeMessage(msg)
+	if native.err != nil {
+		return nil, native
+	}
+	methodePlaceholder := native.placeholder
+	keys := []string{strings.ToLower(methodePlaceholder.UUID)}
+	attributes := methodePlaceholder.Attributes
+	if attributes.OriginalUUID != "" {
+		keys = append(keys, strings.ToLower(attributes.OriginalUUID))
+	}
+	if attributes.ServiceId != "" && attributes.RefField != "" {
+		keys = append(keys, kqh.blogOrderingKey(attributes.ServiceId, attributes.RefField))
 	}
+	return keys, native
+}
+
+// blogOrderingKey is the identifier a blog placeholder is resolved with, or its serviceId and refField as written if it has no brand mapping
+func (kqh *CPHMessageHandler) blogOrderingKey(serviceID, refField string) string {
+	if kqh.BrandMappings != nil {
+		if _, authority, identifier, err := mapper.BlogIdentifier(kqh.BrandMappings, serviceID, refField); err == nil {
+			return authority + "|" + identifier
+		}
+	}
+	return serviceID + "#" + refField
+}
+
+// checkPublishedHash returns the hash of the content and whether it is the hash of the content last published to contentURI
+func (kqh *CPHMessageHandler) checkPublishedHash(tid, contentURI string, content model.UppContent) (string, bool) {
+	if kqh.PublishedHashes == nil {
+		return "", false
+	}
+	hash, err := contentHash(content)
+	if err != nil {
+		log.WithField("transaction_id", tid).WithField("content_uri", contentURI).WithError(err).Warn("Couldn't hash content, it is published whether it changed or not")
+		return "", false
+	}
+	published, found := kqh.PublishedHashes.Get(contentURI)
+	return hash, found && published == hash
+}
+
+func (kqh *CPHMessageHandler) producerFor(coreAttributes *model.UppCoreContent) producer.MessageProducer {
+	if kqh.Producers == nil {
+		return kqh.messageProducer
+	}
+	return kqh.Producers.ProducerFor(coreAttributes)
+}
+
+func (kqh *CPHMessageHandler) messageKey(coreAttributes *model.UppCoreContent) string {
+	if kqh.KeyStrategy == nil {
+		return message.UUIDKey(coreAttributes)
+	}
+	return kqh.KeyStrategy(coreAttributes)
+}
+
+// validatePayloads returns a ValidationError reporting the schema violations of all the mapped contents
+func (kqh *CPHMessageHandler) validatePayloads(tid string, contents []model.UppContent) error {
+	if kqh.PayloadSchemas == nil {
+		return nil
+	}
+	var report model.ValidationReport
+	for _, content := range contents {
+		contentReport, err := kqh.PayloadSchemas.Validate(content)
+		if err != nil {
+			log.WithField("transaction_id", tid).WithField("uuid", content.GetUUID()).WithError(err).Warn("Couldn't validate the payload of the mapped content")
+			continue
+		}
+		report.Merge(contentReport)
+	}
+	return report.Err()
+}
+
+// checkStale returns the timestamp of the message last published to the UUID and whether it is later than the given one
+func (kqh *CPHMessageHandler) checkStale(uuid string, timestamp time.Time) (time.Time, bool) {
+	if kqh.PublishedTimestamps == nil {
+		return time.Time{}, false
+	}
+	latest, found := kqh.PublishedTimestamps.Latest(uuid)
+	return latest, found && timestamp.Before(latest)
+}
+
+func (kqh *CPHMessageHandler) advancePublishedTimestamp(uuid string, timestamp time.Time) {
+	if kqh.PublishedTimestamps == nil || timestamp.IsZero() {
+		return
+	}
+	kqh.PublishedTimestamps.Advance(uuid, timestamp)
+}
+
+func (kqh *CPHMessageHandler) deadLetter(msg consumer.Message, stage string, cause error, tid string) {
+	if kqh.DeadLetterQueue == nil {
+		if stage == StagePartialPublication {
+			log.WithField("transaction_id", tid).WithError(cause).Error("Placeholder partially published and no dead-letter topic to re-drive it from, its unsent content is lost")
+		}
+		return
+	}
+	if err := kqh.DeadLetterQueue.Send(msg, stage, cause, tid); err != nil {
+		log.WithField("transaction_id", tid).WithField("stage", stage).WithError(err).Error("Error sending failed message to the dead-letter topic")
+		return
+	}
+	log.WithField("transaction_id", tid).WithField("stage", stage).Info("Failed message sent to the dead-letter topic")
 }
 
 func (kqh *CPHMessageHandler) StartHandlingMessages() {
@@ -96,9 +371,27 @@ func (kqh *CPHMessageHandler) StartHandlingMessages() {
 		kqh.MessageConsumer.Start()
 		consumerWaitGroup.Done()
 	}()
-	ch := make(chan os.Signal)
+	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
 	<-ch
+	// the messages in progress fail fast and are dead-lettered, instead of holding up the shutdown
+	kqh.cancel()
 	kqh.MessageConsumer.Stop()
 	consumerWaitGroup.Wait()
+	if kqh.WorkerPool != nil {
+		kqh.WorkerPool.Stop()
+	}
+}
+
+// logWithValidationIssues adds the field paths and codes of the problems found in an invalid placeholder to the log entry
+func logWithValidationIssues(entry *log.Entry, err error) *log.Entry {
+	validationErr, ok := model.AsValidationError(err)
+	if !ok {
+		return entry
+	}
+	var issues []string
+	for _, issue := range validationErr.Report.Issues {
+		issues = append(issues, issue.Field+":"+issue.Code)
+	}
+	return entry.WithField("validation_issues", strings.Join(issues, ","))
 }
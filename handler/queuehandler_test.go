@@ -1,13 +1,18 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Financial-Times/message-queue-go-producer/producer"
 	"github.com/Financial-Times/message-queue-gonsumer/consumer"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/mapper"
 	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
 	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -46,14 +51,14 @@ func TestOnMessage_Ok(t *testing.T) {
 	mockedAggregateCPHMapper.On("MapContentPlaceholder", mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true }), "tid_test123", "2017-05-15T15:54:32.166Z").Return(uppContents, nil)
 
 	mockedMessageCreator := new(model.MockMessageCreator)
-	mockedMessageCreator.On("ToPublicationEventMessage", mock.MatchedBy(func(c *model.UppCoreContent) bool { return c.UUID == "512c1f3d-e48c-4618-863c-94bc9d913b9b" }), mock.MatchedBy(func(p interface{}) bool { return true })).
+	mockedMessageCreator.On("ToPublicationEventMessage", mock.MatchedBy(func(c *model.UppCoreContent) bool { return c.UUID == "512c1f3d-e48c-4618-863c-94bc9d913b9b" }), mock.MatchedBy(func(p interface{}) bool { return true }), mock.Anything).
 		Return(&producer.Message{
 			Body: "{\"uuid\":\"512c1f3d-e48c-4618-863c-94bc9d913b9b}\",\"lastModifiedDate\":\"2017-05-15T15:54:32.166Z\"}",
 			Headers: map[string]string{
 				"X-Request-Id": "tid_test123",
 			},
 		}, nil)
-	mockedMessageCreator.On("ToPublicationEventMessage", mock.MatchedBy(func(c *model.UppCoreContent) bool { return c.UUID == "43dc1ff3-6d6c-41f3-9196-56dcaa554905" }), mock.MatchedBy(func(p interface{}) bool { return true })).
+	mockedMessageCreator.On("ToPublicationEventMessage", mock.MatchedBy(func(c *model.UppCoreContent) bool { return c.UUID == "43dc1ff3-6d6c-41f3-9196-56dcaa554905" }), mock.MatchedBy(func(p interface{}) bool { return true }), mock.Anything).
 		Return(&producer.Message{
 			Body: "{\"uuid\":\"43dc1ff3-6d6c-41f3-9196-56dcaa554905}\",\"lastModifiedDate\":\"2017-05-15T15:54:32.166Z\"}",
 			Headers: map[string]string{
@@ -62,16 +67,16 @@ func TestOnMessage_Ok(t *testing.T) {
 		}, nil)
 
 	mockedProducer := new(model.MockProducer)
-	mockedProducer.On("SendMessage", "", mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(nil)
+	mockedProducer.On("SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(nil)
 
 	q := NewCPHMessageHandler(nil, mockedProducer, mockedAggregateCPHMapper, nativeMapper, mockedMessageCreator)
 	q.HandleMessage(sourceMsg)
 
-	mockedProducer.AssertCalled(t, "SendMessage", "",
+	mockedProducer.AssertCalled(t, "SendMessage", "512c1f3d-e48c-4618-863c-94bc9d913b9b",
 		mock.MatchedBy(func(msg producer.Message) bool {
 			return strings.Contains(msg.Body, "512c1f3d-e48c-4618-863c-94bc9d913b9b") && strings.Contains(msg.Body, "2017-05-15T15:54:32.166Z")
 		}))
-	mockedProducer.AssertCalled(t, "SendMessage", "",
+	mockedProducer.AssertCalled(t, "SendMessage", "43dc1ff3-6d6c-41f3-9196-56dcaa554905",
 		mock.MatchedBy(func(msg producer.Message) bool {
 			return strings.Contains(msg.Body, "43dc1ff3-6d6c-41f3-9196-56dcaa554905") && strings.Contains(msg.Body, "2017-05-15T15:54:32.166Z")
 		}))
@@ -99,7 +104,7 @@ func TestOnMessageNativeMapError_MessagesNotSent(t *testing.T) {
 	q := NewCPHMessageHandler(nil, mockedProducer, mockedAggregateCPHMapper, nativeMapper, mockedMessageCreator)
 	q.HandleMessage(sourceMsg)
 
-	mockedProducer.AssertNotCalled(t, "SendMessage", "", mock.MatchedBy(func(msg producer.Message) bool { return true }))
+	mockedProducer.AssertNotCalled(t, "SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true }))
 	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 0)
 }
 
@@ -141,7 +146,7 @@ func TestOnMessageCPHMapError_MessagesNotSent(t *testing.T) {
 	q := NewCPHMessageHandler(nil, mockedProducer, mockedAggregateCPHMapper, nativeMapper, mockedMessageCreator)
 	q.HandleMessage(sourceMsg)
 
-	mockedProducer.AssertNotCalled(t, "SendMessage", "", mock.MatchedBy(func(msg producer.Message) bool { return true }))
+	mockedProducer.AssertNotCalled(t, "SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true }))
 	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 0)
 }
 
@@ -178,14 +183,14 @@ func TestOnMessagePublicationEventError_MessagesNotSent(t *testing.T) {
 	mockedAggregateCPHMapper.On("MapContentPlaceholder", mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true }), "tid_test123", "2017-05-15T15:54:32.166Z").Return(uppContents, nil)
 
 	mockedMessageCreator := new(model.MockMessageCreator)
-	mockedMessageCreator.On("ToPublicationEventMessage", mock.MatchedBy(func(c *model.UppCoreContent) bool { return c.UUID == "512c1f3d-e48c-4618-863c-94bc9d913b9b" }), mock.MatchedBy(func(p interface{}) bool { return true })).
+	mockedMessageCreator.On("ToPublicationEventMessage", mock.MatchedBy(func(c *model.UppCoreContent) bool { return c.UUID == "512c1f3d-e48c-4618-863c-94bc9d913b9b" }), mock.MatchedBy(func(p interface{}) bool { return true }), mock.Anything).
 		Return(&producer.Message{
 			Body: "{\"uuid\":\"512c1f3d-e48c-4618-863c-94bc9d913b9b}\",\"lastModifiedDate\":\"2017-05-15T15:54:32.166Z\"}",
 			Headers: map[string]string{
 				"X-Request-Id": "tid_test123",
 			},
 		}, errors.New("Error creating publication event messages."))
-	mockedMessageCreator.On("ToPublicationEventMessage", mock.MatchedBy(func(c *model.UppCoreContent) bool { return c.UUID == "43dc1ff3-6d6c-41f3-9196-56dcaa554905" }), mock.MatchedBy(func(p interface{}) bool { return true })).
+	mockedMessageCreator.On("ToPublicationEventMessage", mock.MatchedBy(func(c *model.UppCoreContent) bool { return c.UUID == "43dc1ff3-6d6c-41f3-9196-56dcaa554905" }), mock.MatchedBy(func(p interface{}) bool { return true }), mock.Anything).
 		Return(&producer.Message{
 			Body: "{\"uuid\":\"43dc1ff3-6d6c-41f3-9196-56dcaa554905}\",\"lastModifiedDate\":\"2017-05-15T15:54:32.166Z\"}",
 			Headers: map[string]string{
@@ -194,11 +199,415 @@ func TestOnMessagePublicationEventError_MessagesNotSent(t *testing.T) {
 		}, errors.New("Error creating publication event messages."))
 
 	mockedProducer := new(model.MockProducer)
-	mockedProducer.On("SendMessage", "", mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(nil)
+	mockedProducer.On("SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(nil)
 
 	q := NewCPHMessageHandler(nil, mockedProducer, mockedAggregateCPHMapper, nativeMapper, mockedMessageCreator)
 	q.HandleMessage(sourceMsg)
 
-	mockedProducer.AssertNotCalled(t, "SendMessage", "", mock.MatchedBy(func(msg producer.Message) bool { return true }))
+	mockedProducer.AssertNotCalled(t, "SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true }))
 	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 0)
 }
+
+func TestOnMessageNativeMapError_MessageDeadLettered(t *testing.T) {
+	sourceMsg := consumer.Message{
+		Headers: map[string]string{
+			"X-Request-Id":      "tid_test123",
+			"Origin-System-Id":  methodeSystemOrigin,
+			"Message-Timestamp": "2017-05-15T15:54:32.166Z",
+		},
+		Body: "",
+	}
+
+	nativeMapper := new(model.MockNativeMapper)
+	nativeMapper.On("Map", mock.MatchedBy(func(messageBody []byte) bool { return true })).Return(&model.MethodeContentPlaceholder{}, errors.New("Some native mapping error"))
+
+	deadLetterQueue := new(model.MockDeadLetterQueue)
+	deadLetterQueue.On("Send", sourceMsg, StageNativeMapping, mock.MatchedBy(func(err error) bool { return err.Error() == "Some native mapping error" }), "tid_test123").Return(nil)
+
+	mockedProducer := new(model.MockProducer)
+
+	q := NewCPHMessageHandler(nil, mockedProducer, new(model.MockCPHAggregateMapper), nativeMapper, new(model.MockMessageCreator))
+	q.DeadLetterQueue = deadLetterQueue
+	q.HandleMessage(sourceMsg)
+
+	deadLetterQueue.AssertNumberOfCalls(t, "Send", 1)
+	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 0)
+}
+
+func TestOnMessageNotAPlaceholder_MessageNotDeadLettered(t *testing.T) {
+	sourceMsg := consumer.Message{
+		Headers: map[string]string{
+			"X-Request-Id":      "tid_test123",
+			"Origin-System-Id":  methodeSystemOrigin,
+			"Message-Timestamp": "2017-05-15T15:54:32.166Z",
+		},
+		Body: "",
+	}
+
+	nativeMapper := new(model.MockNativeMapper)
+	nativeMapper.On("Map", mock.MatchedBy(func(messageBody []byte) bool { return true })).Return(&model.MethodeContentPlaceholder{}, model.NewInvalidMethodeCPH("Methode content is not a content placeholder"))
+
+	deadLetterQueue := new(model.MockDeadLetterQueue)
+
+	q := NewCPHMessageHandler(nil, new(model.MockProducer), new(model.MockCPHAggregateMapper), nativeMapper, new(model.MockMessageCreator))
+	q.DeadLetterQueue = deadLetterQueue
+	q.HandleMessage(sourceMsg)
+
+	deadLetterQueue.AssertNumberOfCalls(t, "Send", 0)
+}
+
+func TestOnMessageCPHMapError_MessageDeadLettered(t *testing.T) {
+	sourceMsg := consumer.Message{
+		Headers: map[string]string{
+			"X-Request-Id":      "tid_test123",
+			"Origin-System-Id":  methodeSystemOrigin,
+			"Message-Timestamp": "2017-05-15T15:54:32.166Z",
+		},
+		Body: "",
+	}
+
+	nativeMapper := new(model.MockNativeMapper)
+	nativeMapper.On("Map", mock.MatchedBy(func(messageBody []byte) bool { return true })).Return(&model.MethodeContentPlaceholder{}, nil)
+
+	mockedAggregateCPHMapper := new(model.MockCPHAggregateMapper)
+	mockedAggregateCPHMapper.On("MapContentPlaceholder", mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true }), "tid_test123", "2017-05-15T15:54:32.166Z").Return([]model.UppContent{}, errors.New("Some cph mapping error"))
+
+	deadLetterQueue := new(model.MockDeadLetterQueue)
+	deadLetterQueue.On("Send", sourceMsg, StageContentMapping, mock.MatchedBy(func(err error) bool { return err.Error() == "Some cph mapping error" }), "tid_test123").Return(nil)
+
+	q := NewCPHMessageHandler(nil, new(model.MockProducer), mockedAggregateCPHMapper, nativeMapper, new(model.MockMessageCreator))
+	q.DeadLetterQueue = deadLetterQueue
+	q.HandleMessage(sourceMsg)
+
+	deadLetterQueue.AssertNumberOfCalls(t, "Send", 1)
+}
+
+func TestOnMessageIgnoredCategory_MessageNotDeadLettered(t *testing.T) {
+	sourceMsg := consumer.Message{
+		Headers: map[string]string{
+			"X-Request-Id":      "tid_test123",
+			"Origin-System-Id":  methodeSystemOrigin,
+			"Message-Timestamp": "2017-05-15T15:54:32.166Z",
+		},
+		Body: "",
+	}
+
+	nativeMapper := new(model.MockNativeMapper)
+	nativeMapper.On("Map", mock.MatchedBy(func(messageBody []byte) bool { return true })).Return(&model.MethodeContentPlaceholder{}, nil)
+
+	mockedAggregateCPHMapper := new(model.MockCPHAggregateMapper)
+	mockedAggregateCPHMapper.On("MapContentPlaceholder", mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true }), "tid_test123", "2017-05-15T15:54:32.166Z").Return([]model.UppContent{}, model.NewInvalidMethodeCPH("Ignoring content placeholder of category=\"podcast\""))
+
+	deadLetterQueue := new(model.MockDeadLetterQueue)
+	mockedProducer := new(model.MockProducer)
+
+	q := NewCPHMessageHandler(nil, mockedProducer, mockedAggregateCPHMapper, nativeMapper, new(model.MockMessageCreator))
+	q.DeadLetterQueue = deadLetterQueue
+	q.HandleMessage(sourceMsg)
+
+	deadLetterQueue.AssertNumberOfCalls(t, "Send", 0)
+	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 0)
+}
+
+func TestOnMessageSendError_MessageDeadLettered(t *testing.T) {
+	sourceMsg := consumer.Message{
+		Headers: map[string]string{
+			"X-Request-Id":      "tid_test123",
+			"Origin-System-Id":  methodeSystemOrigin,
+			"Message-Timestamp": "2017-05-15T15:54:32.166Z",
+		},
+		Body: "",
+	}
+	uppContents := []model.UppContent{
+		&model.UppCoreContent{
+			UUID:             "512c1f3d-e48c-4618-863c-94bc9d913b9b",
+			PublishReference: "tid_test123",
+			LastModified:     "2017-05-15T15:54:32.166Z",
+		},
+	}
+
+	nativeMapper := new(model.MockNativeMapper)
+	nativeMapper.On("Map", mock.MatchedBy(func(messageBody []byte) bool { return true })).Return(&model.MethodeContentPlaceholder{}, nil)
+
+	mockedAggregateCPHMapper := new(model.MockCPHAggregateMapper)
+	mockedAggregateCPHMapper.On("MapContentPlaceholder", mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true }), "tid_test123", "2017-05-15T15:54:32.166Z").Return(uppContents, nil)
+
+	mockedMessageCreator := new(model.MockMessageCreator)
+	mockedMessageCreator.On("ToPublicationEventMessage", mock.MatchedBy(func(c *model.UppCoreContent) bool { return true }), mock.MatchedBy(func(p interface{}) bool { return true }), mock.Anything).
+		Return(&producer.Message{Body: "{}", Headers: map[string]string{"X-Request-Id": "tid_test123"}}, nil)
+
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(errors.New("Write queue unavailable"))
+
+	deadLetterQueue := new(model.MockDeadLetterQueue)
+	deadLetterQueue.On("Send", sourceMsg, StageSending, mock.MatchedBy(func(err error) bool { return err.Error() == "Write queue unavailable" }), "tid_test123").Return(nil)
+
+	q := NewCPHMessageHandler(nil, mockedProducer, mockedAggregateCPHMapper, nativeMapper, mockedMessageCreator)
+	q.DeadLetterQueue = deadLetterQueue
+	q.HandleMessage(sourceMsg)
+
+	deadLetterQueue.AssertNumberOfCalls(t, "Send", 1)
+}
+
+func TestOnMessageTransientCPHMapError_Retried(t *testing.T) {
+	sourceMsg := consumer.Message{
+		Headers: map[string]string{
+			"X-Request-Id":      "tid_test123",
+			"Origin-System-Id":  methodeSystemOrigin,
+			"Message-Timestamp": "2017-05-15T15:54:32.166Z",
+		},
+		Body: "",
+	}
+	uppContents := []model.UppContent{
+		&model.UppCoreContent{
+			UUID:             "512c1f3d-e48c-4618-863c-94bc9d913b9b",
+			PublishReference: "tid_test123",
+			LastModified:     "2017-05-15T15:54:32.166Z",
+		},
+	}
+
+	nativeMapper := new(model.MockNativeMapper)
+	nativeMapper.On("Map", mock.MatchedBy(func(messageBody []byte) bool { return true })).Return(&model.MethodeContentPlaceholder{}, nil)
+
+	mockedAggregateCPHMapper := new(model.MockCPHAggregateMapper)
+	mockedAggregateCPHMapper.On("MapContentPlaceholder", mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true }), "tid_test123", "2017-05-15T15:54:32.166Z").Return([]model.UppContent{}, model.NewTransientError("document-store-api unavailable")).Once()
+	mockedAggregateCPHMapper.On("MapContentPlaceholder", mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true }), "tid_test123", "2017-05-15T15:54:32.166Z").Return(uppContents, nil).Once()
+
+	mockedMessageCreator := new(model.MockMessageCreator)
+	mockedMessageCreator.On("ToPublicationEventMessage", mock.MatchedBy(func(c *model.UppCoreContent) bool { return true }), mock.MatchedBy(func(p interface{}) bool { return true }), mock.Anything).
+		Return(&producer.Message{Body: "{}", Headers: map[string]string{"X-Request-Id": "tid_test123"}}, nil)
+
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(nil)
+
+	q := NewCPHMessageHandler(nil, mockedProducer, mockedAggregateCPHMapper, nativeMapper, mockedMessageCreator)
+	q.RetryPolicy = RetryPolicy{MaxAttempts: 3}
+	q.HandleMessage(sourceMsg)
+
+	mockedAggregateCPHMapper.AssertNumberOfCalls(t, "MapContentPlaceholder", 2)
+	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 1)
+}
+
+func TestOnMessagePermanentCPHMapError_NotRetried(t *testing.T) {
+	sourceMsg := consumer.Message{
+		Headers: map[string]string{
+			"X-Request-Id":      "tid_test123",
+			"Origin-System-Id":  methodeSystemOrigin,
+			"Message-Timestamp": "2017-05-15T15:54:32.166Z",
+		},
+		Body: "",
+	}
+
+	nativeMapper := new(model.MockNativeMapper)
+	nativeMapper.On("Map", mock.MatchedBy(func(messageBody []byte) bool { return true })).Return(&model.MethodeContentPlaceholder{}, nil)
+
+	mockedAggregateCPHMapper := new(model.MockCPHAggregateMapper)
+	mockedAggregateCPHMapper.On("MapContentPlaceholder", mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true }), "tid_test123", "2017-05-15T15:54:32.166Z").Return([]model.UppContent{}, errors.New("Some cph mapping error"))
+
+	mockedProducer := new(model.MockProducer)
+
+	q := NewCPHMessageHandler(nil, mockedProducer, mockedAggregateCPHMapper, nativeMapper, new(model.MockMessageCreator))
+	q.RetryPolicy = RetryPolicy{MaxAttempts: 3}
+	q.HandleMessage(sourceMsg)
+
+	mockedAggregateCPHMapper.AssertNumberOfCalls(t, "MapContentPlaceholder", 1)
+	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 0)
+}
+
+func TestOrderingKeys_IncludeResolvableUUIDs(t *testing.T) {
+	methodePlaceholder := &model.MethodeContentPlaceholder{
+		UUID: "512C1F3D-E48C-4618-863C-94BC9D913B9B",
+		Attributes: model.Attributes{
+			OriginalUUID: "43dc1ff3-6d6c-41f3-9196-56dcaa554905",
+			ServiceId:    "http://ftalphaville.ft.com/?p=2193913",
+			RefField:     "2193913",
+		},
+	}
+	nativeMapper := new(model.MockNativeMapper)
+	nativeMapper.On("Map", mock.MatchedBy(func(messageBody []byte) bool { return true })).Return(methodePlaceholder, nil)
+
+	q := NewCPHMessageHandler(nil, new(model.MockProducer), new(model.MockCPHAggregateMapper), nativeMapper, new(model.MockMessageCreator))
+	keys, _ := q.OrderingKeys(consumer.Message{})
+
+	assert.Equal(t, []string{"512c1f3d-e48c-4618-863c-94bc9d913b9b", "43dc1ff3-6d6c-41f3-9196-56dcaa554905", "http://ftalphaville.ft.com/?p=2193913#2193913"}, keys)
+}
+
+func TestOrderingKeys_BlogIdentifierNormalisedByBrandMappings(t *testing.T) {
+	brandMappings, err := mapper.NewBrandMappingTable(map[string]string{"ftalphaville.ft.com": "FT-LABS-WP-1-24"})
+	assert.NoError(t, err)
+	var keys [][]string
+	for _, serviceID := range []string{"http://ftalphaville.ft.com/?p=2193913", "http://FTAlphaville.ft.com/2017/05/15/2193913/?p=2193913"} {
+		nativeMapper := new(model.MockNativeMapper)
+		nativeMapper.On("Map", mock.MatchedBy(func(messageBody []byte) bool { return true })).Return(&model.MethodeContentPlaceholder{
+			UUID:       "512c1f3d-e48c-4618-863c-94bc9d913b9b",
+			Attributes: model.Attributes{ServiceId: serviceID, RefField: "2193913"},
+		}, nil)
+
+		q := NewCPHMessageHandler(nil, new(model.MockProducer), new(model.MockCPHAggregateMapper), nativeMapper, new(model.MockMessageCreator))
+		q.BrandMappings = brandMappings
+		k, _ := q.OrderingKeys(consumer.Message{})
+		keys = append(keys, k)
+	}
+
+	assert.Equal(t, []string{"512c1f3d-e48c-4618-863c-94bc9d913b9b", "http://api.ft.com/system/FT-LABS-WP-1-24|http://ftalphaville.ft.com/?p=2193913"}, keys[0])
+	assert.Equal(t, keys[0], keys[1])
+}
+
+func TestOrderingKeys_BlogIdentifierWithoutBrandMappingAsWritten(t *testing.T) {
+	brandMappings, err := mapper.NewBrandMappingTable(map[string]string{"blogs.ft.com/brusselsblog": "FT-LABS-WP-1-91"})
+	assert.NoError(t, err)
+	nativeMapper := new(model.MockNativeMapper)
+	nativeMapper.On("Map", mock.MatchedBy(func(messageBody []byte) bool { return true })).Return(&model.MethodeContentPlaceholder{
+		UUID:       "512c1f3d-e48c-4618-863c-94bc9d913b9b",
+		Attributes: model.Attributes{ServiceId: "http://ftalphaville.ft.com/?p=2193913", RefField: "2193913"},
+	}, nil)
+
+	q := NewCPHMessageHandler(nil, new(model.MockProducer), new(model.MockCPHAggregateMapper), nativeMapper, new(model.MockMessageCreator))
+	q.BrandMappings = brandMappings
+	keys, _ := q.OrderingKeys(consumer.Message{})
+
+	assert.Equal(t, []string{"512c1f3d-e48c-4618-863c-94bc9d913b9b", "http://ftalphaville.ft.com/?p=2193913#2193913"}, keys)
+}
+
+func TestOrderingKeys_NativeMapError(t *testing.T) {
+	nativeMapper := new(model.MockNativeMapper)
+	nativeMapper.On("Map", mock.MatchedBy(func(messageBody []byte) bool { return true })).Return(&model.MethodeContentPlaceholder{}, errors.New("Some native mapping error"))
+
+	q := NewCPHMessageHandler(nil, new(model.MockProducer), new(model.MockCPHAggregateMapper), nativeMapper, new(model.MockMessageCreator))
+
+	keys, _ := q.OrderingKeys(consumer.Message{})
+	assert.Empty(t, keys)
+}
+
+func TestHandleParsedMessage_BodyMappedOnce(t *testing.T) {
+	nativeMapper := new(model.MockNativeMapper)
+	nativeMapper.On("Map", mock.MatchedBy(func(messageBody []byte) bool { return true })).Return(&model.MethodeContentPlaceholder{}, errors.New("Some native mapping error"))
+
+	q := NewCPHMessageHandler(nil, new(model.MockProducer), new(model.MockCPHAggregateMapper), nativeMapper, new(model.MockMessageCreator))
+	msg := consumer.Message{Headers: map[string]string{"Origin-System-Id": model.MethodeSystemID}}
+	_, parsed := q.OrderingKeys(msg)
+	q.HandleParsedMessage(msg, parsed)
+
+	nativeMapper.AssertNumberOfCalls(t, "Map", 1)
+}
+
+// recordingGate records the calls to WaitUntilAvailable
+type recordingGate struct {
+	waits int
+}
+
+func (g *recordingGate) WaitUntilAvailable(ctx context.Context) error {
+	g.waits++
+	return nil
+}
+
+// closedGate is a DependencyGate which stopped waiting, as on shutdown
+type closedGate struct{}
+
+func (closedGate) WaitUntilAvailable(ctx context.Context) error {
+	return context.Canceled
+}
+
+func TestOnMessage_DocStoreGateClosed_MessageNotProcessed(t *testing.T) {
+	mockedProducer := new(model.MockProducer)
+	msg := pairSourceMessage()
+	msg.Headers["Message-Id"] = "a7b8c9d0-0000-4000-8000-000000000001"
+	deadLetterQueue := new(model.MockDeadLetterQueue)
+	deadLetterQueue.On("Send", msg, StageDocStoreUnavailable, context.Canceled, mock.Anything).Return(nil)
+	processed := NewInMemoryProcessedMessageStore(time.Hour)
+
+	q := newPairPublicationHandler(mockedProducer)
+	q.DocStoreGate = closedGate{}
+	q.DeadLetterQueue = deadLetterQueue
+	q.ProcessedMessages = processed
+	q.HandleMessage(msg)
+
+	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 0)
+	assert.False(t, processed.Seen("a7b8c9d0-0000-4000-8000-000000000001"))
+	deadLetterQueue.AssertNumberOfCalls(t, "Send", 1)
+	q.cphMapper.(*model.MockCPHAggregateMapper).AssertNumberOfCalls(t, "MapContentPlaceholder", 0)
+}
+
+func TestOnMessage_DocStoreGateClosed_DeadLetteredAsDocStoreUnavailable(t *testing.T) {
+	deadLetterProducer := new(model.MockProducer)
+	deadLetterProducer.On("SendMessage", "", mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(nil)
+
+	q := newPairPublicationHandler(new(model.MockProducer))
+	q.DocStoreGate = closedGate{}
+	q.DeadLetterQueue = NewProducerDeadLetterQueue(deadLetterProducer)
+	q.HandleMessage(pairSourceMessage())
+
+	deadLetterProducer.AssertCalled(t, "SendMessage", "", mock.MatchedBy(func(msg producer.Message) bool {
+		var event model.DeadLetterEvent
+		if err := json.Unmarshal([]byte(msg.Body), &event); err != nil {
+			return false
+		}
+		return event.Stage == StageDocStoreUnavailable && event.Error == context.Canceled.Error()
+	}))
+}
+
+func TestOnMessage_WaitsForDocStoreGate(t *testing.T) {
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(nil)
+	gate := new(recordingGate)
+
+	q := newPairPublicationHandler(mockedProducer)
+	q.DocStoreGate = gate
+	q.HandleMessage(pairSourceMessage())
+
+	assert.Equal(t, 1, gate.waits)
+	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 2)
+}
+
+func TestOnMessageDifferentOrigin_DoesNotWaitForDocStoreGate(t *testing.T) {
+	gate := new(recordingGate)
+	msg := pairSourceMessage()
+	msg.Headers["Origin-System-Id"] = "http://cmdb.ft.com/systems/wordpress"
+
+	q := newPairPublicationHandler(new(model.MockProducer))
+	q.DocStoreGate = gate
+	q.HandleMessage(msg)
+
+	assert.Equal(t, 0, gate.waits)
+}
+
+// contextRecordingMapper records the context it is called with and fails once it is done
+type contextRecordingMapper struct {
+	ctx context.Context
+}
+
+func (m *contextRecordingMapper) MapContentPlaceholder(ctx context.Context, mpc *model.MethodeContentPlaceholder, tid, lmd string) ([]model.UppContent, error) {
+	m.ctx = ctx
+	return nil, ctx.Err()
+}
+
+func TestOnMessage_MappingHasMessageDeadline(t *testing.T) {
+	nativeMapper := new(model.MockNativeMapper)
+	nativeMapper.On("Map", mock.MatchedBy(func(messageBody []byte) bool { return true })).Return(&model.MethodeContentPlaceholder{UUID: "512c1f3d-e48c-4618-863c-94bc9d913b9b"}, nil)
+	cphMapper := new(contextRecordingMapper)
+
+	q := NewCPHMessageHandler(nil, new(model.MockProducer), cphMapper, nativeMapper, new(model.MockMessageCreator))
+	q.MessageTimeout = time.Minute
+	q.HandleMessage(pairSourceMessage())
+
+	deadline, hasDeadline := cphMapper.ctx.Deadline()
+	assert.True(t, hasDeadline)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, 5*time.Second)
+}
+
+func TestOnMessage_MappingCanceledOnShutdown(t *testing.T) {
+	nativeMapper := new(model.MockNativeMapper)
+	nativeMapper.On("Map", mock.MatchedBy(func(messageBody []byte) bool { return true })).Return(&model.MethodeContentPlaceholder{UUID: "512c1f3d-e48c-4618-863c-94bc9d913b9b"}, nil)
+	cphMapper := new(contextRecordingMapper)
+	sourceMsg := pairSourceMessage()
+	deadLetterQueue := new(model.MockDeadLetterQueue)
+	deadLetterQueue.On("Send", sourceMsg, StageContentMapping, context.Canceled, "tid_test123").Return(nil)
+
+	q := NewCPHMessageHandler(nil, new(model.MockProducer), cphMapper, nativeMapper, new(model.MockMessageCreator))
+	q.DeadLetterQueue = deadLetterQueue
+	q.cancel()
+	q.HandleMessage(sourceMsg)
+
+	assert.Equal(t, context.Canceled, cphMapper.ctx.Err())
+	deadLetterQueue.AssertNumberOfCalls(t, "Send", 1)
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	log "github.com/Sirupsen/logrus"
+)
+
+// RetryPolicy defines how many times and how often an operation failing with a transient error is retried.
+// The backoff doubles with each attempt up to MaxBackoff, and a random jitter of up to half of it is applied.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// Do runs op until it succeeds, fails with a permanent error, the attempts are exhausted
+// or the context is done, returning the last error
+func (p RetryPolicy) Do(ctx context.Context, tid string, op func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = op()
+		if err == nil || !model.IsTransient(err) || attempt >= p.MaxAttempts {
+			return err
+		}
+		backoff := p.Backoff(attempt)
+		log.WithField("transaction_id", tid).WithField("attempt", attempt).WithError(err).Warnf("Transient error, retrying in %v", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// Backoff returns the jittered delay to wait after the given failed attempt
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(backoff-half)+1))
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicyDo_RetriesTransientErrors(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3}
+	calls := 0
+
+	err := policy.Do(context.Background(), "tid_test123", func() error {
+		calls++
+		if calls < 3 {
+			return model.NewTransientError("document-store-api unavailable")
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetryPolicyDo_GivesUpAfterMaxAttempts(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3}
+	calls := 0
+
+	err := policy.Do(context.Background(), "tid_test123", func() error {
+		calls++
+		return model.NewTransientError("document-store-api unavailable")
+	})
+
+	assert.True(t, model.IsTransient(err))
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetryPolicyDo_DoesNotRetryPermanentErrors(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3}
+	calls := 0
+
+	err := policy.Do(context.Background(), "tid_test123", func() error {
+		calls++
+		return errors.New("Methode Content headline does not contain text")
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryPolicyDo_ZeroValueRunsOnce(t *testing.T) {
+	calls := 0
+
+	RetryPolicy{}.Do(context.Background(), "tid_test123", func() error {
+		calls++
+		return model.NewTransientError("document-store-api unavailable")
+	})
+
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryPolicyDo_StopsWhenContextDone(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Minute, MaxBackoff: time.Minute}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	calls := 0
+
+	err := policy.Do(ctx, "tid_test123", func() error {
+		calls++
+		return model.NewTransientError("document-store-api unavailable")
+	})
+
+	assert.True(t, model.IsTransient(err))
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryPolicyBackoff_IsBoundedAndJittered(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	for attempt := 1; attempt <= 10; attempt++ {
+		expected := 100 * time.Millisecond << uint(attempt-1)
+		if expected > time.Second {
+			expected = time.Second
+		}
+		backoff := policy.Backoff(attempt)
+		assert.True(t, backoff >= expected/2, "backoff %v for attempt %d should be at least %v", backoff, attempt, expected/2)
+		assert.True(t, backoff <= expected, "backoff %v for attempt %d should be at most %v", backoff, attempt, expected)
+	}
+}
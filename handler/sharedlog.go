@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+// sharedLog is a log of JSON lines shared by the replicas of the service, e.g. on a ReadWriteMany volume.
+// Each replica appends to it under an exclusive lock and follows the lines appended by the others.
+// It is compacted by rewriting it to a new file that atomically replaces the old one,
+// which the other replicas notice and read again from the beginning.
+type sharedLog struct {
+	path string
+	// lock is a separate file, as the log itself is replaced when compacted
+	lock *os.File
+	file *os.File
+	// offset is how much of file was read, up to the last complete line
+	offset int64
+	// lines is the number of lines of file, complete or not
+	lines int
+}
+
+func openSharedLog(path string) (*sharedLog, error) {
+	lock, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open lock of %v: %v", path, err)
+	}
+	l := &sharedLog{path: path, lock: lock}
+	if err := l.reopen(); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *sharedLog) reopen() error {
+	file, err := os.OpenFile(l.path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("couldn't open %v: %v", l.path, err)
+	}
+	if l.file != nil {
+		l.file.Close()
+	}
+	l.file, l.offset, l.lines = file, 0, 0
+	return nil
+}
+
+// follow applies the lines appended since it was last called, by this replica or another.
+// After a compaction by another replica the whole log is read again, so apply must be idempotent.
+// Lines which aren't valid JSON, e.g. left incomplete by a crash, are skipped.
+func (l *sharedLog) follow(apply func(line []byte)) error {
+	replaced, err := l.replaced()
+	if err != nil {
+		return err
+	}
+	if replaced {
+		if err := l.reopen(); err != nil {
+			return err
+		}
+	}
+	reader := bufio.NewReader(io.NewSectionReader(l.file, l.offset, 1<<62))
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// an incomplete last line is read again once it is complete
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't read %v: %v", l.path, err)
+		}
+		l.offset += int64(len(line))
+		l.lines++
+		if json.Valid(line) {
+			apply(bytes.TrimSpace(line))
+		}
+	}
+}
+
+// replaced reports whether the log was replaced by a compaction since it was opened
+func (l *sharedLog) replaced() (bool, error) {
+	current, err := os.Stat(l.path)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("couldn't stat %v: %v", l.path, err)
+	}
+	opened, err := l.file.Stat()
+	if err != nil {
+		return false, fmt.Errorf("couldn't stat %v: %v", l.path, err)
+	}
+	return !os.SameFile(current, opened), nil
+}
+
+// append writes a record as a line at the end of the log
+func (l *sharedLog) append(record interface{}) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return l.locked(func() error {
+		if replaced, err := l.replaced(); err != nil {
+			return err
+		} else if replaced {
+			if err := l.reopen(); err != nil {
+				return err
+			}
+		}
+		if _, err := l.file.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("couldn't write %v: %v", l.path, err)
+		}
+		return nil
+	})
+}
+
+// compact replaces the log with the records returned by live, once the lines appended by every replica were applied
+func (l *sharedLog) compact(apply func(line []byte), live func() []interface{}) error {
+	return l.locked(func() error {
+		if err := l.follow(apply); err != nil {
+			return err
+		}
+		tmp, err := os.Create(l.path + ".tmp")
+		if err != nil {
+			return fmt.Errorf("couldn't compact %v: %v", l.path, err)
+		}
+		w := bufio.NewWriter(tmp)
+		records := live()
+		for _, record := range records {
+			line, _ := json.Marshal(record)
+			w.Write(append(line, '\n'))
+		}
+		if err := w.Flush(); err != nil {
+			tmp.Close()
+			return fmt.Errorf("couldn't compact %v: %v", l.path, err)
+		}
+		if err := tmp.Close(); err != nil {
+			return fmt.Errorf("couldn't compact %v: %v", l.path, err)
+		}
+		if err := os.Rename(tmp.Name(), l.path); err != nil {
+			return fmt.Errorf("couldn't compact %v: %v", l.path, err)
+		}
+		if err := l.reopen(); err != nil {
+			return err
+		}
+		return l.follow(func([]byte) {})
+	})
+}
+
+func (l *sharedLog) locked(f func() error) error {
+	if err := syscall.Flock(int(l.lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("couldn't lock %v: %v", l.path, err)
+	}
+	defer syscall.Flock(int(l.lock.Fd()), syscall.LOCK_UN)
+	return f()
+}
+
+func (l *sharedLog) close() error {
+	l.lock.Close()
+	return l.file.Close()
+}
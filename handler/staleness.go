@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+)
+
+// PublishedTimestampStore keeps, for each UUID, the Message-Timestamp of the latest message published to it
+type PublishedTimestampStore interface {
+	Latest(uuid string) (time.Time, bool)
+	// Advance records the timestamp unless a later one is already recorded
+	Advance(uuid string, timestamp time.Time)
+}
+
+// parseMessageTimestamp reads the Message-Timestamp header, which is written in the UPP date format
+func parseMessageTimestamp(value string) (time.Time, bool) {
+	t, err := time.Parse(model.UPPDateFormat, value)
+	if err != nil {
+		t, err = time.Parse(time.RFC3339Nano, value)
+	}
+	return t, err == nil
+}
+
+// InMemoryPublishedTimestampStore is a PublishedTimestampStore keeping the timestamps of the most recently published UUIDs
+type InMemoryPublishedTimestampStore struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	recency  *list.List
+}
+
+type publishedTimestamp struct {
+	uuid      string
+	timestamp time.Time
+}
+
+func NewInMemoryPublishedTimestampStore(capacity int) *InMemoryPublishedTimestampStore {
+	return &InMemoryPublishedTimestampStore{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		recency:  list.New(),
+	}
+}
+
+func (s *InMemoryPublishedTimestampStore) Latest(uuid string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, found := s.entries[uuid]
+	if !found {
+		return time.Time{}, false
+	}
+	s.recency.MoveToFront(e)
+	return e.Value.(publishedTimestamp).timestamp, true
+}
+
+func (s *InMemoryPublishedTimestampStore) Advance(uuid string, timestamp time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, found := s.entries[uuid]; found {
+		if timestamp.After(e.Value.(publishedTimestamp).timestamp) {
+			e.Value = publishedTimestamp{uuid: uuid, timestamp: timestamp}
+		}
+		s.recency.MoveToFront(e)
+		return
+	}
+	s.entries[uuid] = s.recency.PushFront(publishedTimestamp{uuid: uuid, timestamp: timestamp})
+	if s.recency.Len() > s.capacity {
+		oldest := s.recency.Back()
+		s.recency.Remove(oldest)
+		delete(s.entries, oldest.Value.(publishedTimestamp).uuid)
+	}
+}
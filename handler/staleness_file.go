@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// minTimestampLogLinesToCompact is how many lines are appended between two checks of whether the log should be compacted
+const minTimestampLogLinesToCompact = 1000
+
+// FilePublishedTimestampStore is a PublishedTimestampStore surviving restarts and shared by the replicas of the service,
+// so that a message replayed after a restart or consumed by another replica after a rebalance is still recognised as stale.
+// Timestamps are appended to a log file on a volume mounted by every replica, which is compacted to the latest timestamp
+// of each UUID once it holds mostly superseded ones. Beyond capacity, the UUIDs with the oldest timestamps are forgotten.
+type FilePublishedTimestampStore struct {
+	mu         sync.Mutex
+	capacity   int
+	log        *sharedLog
+	timestamps map[string]time.Time
+	// appended counts the lines appended since the last compaction check
+	appended int
+}
+
+type publishedTimestampRecord struct {
+	UUID      string    `json:"uuid"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// NewFilePublishedTimestampStore opens the log at path, creating it if needed, and loads the timestamps it holds
+func NewFilePublishedTimestampStore(path string, capacity int) (*FilePublishedTimestampStore, error) {
+	sharedLog, err := openSharedLog(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &FilePublishedTimestampStore{capacity: capacity, log: sharedLog, timestamps: make(map[string]time.Time)}
+	if err := s.log.follow(s.apply); err != nil {
+		sharedLog.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FilePublishedTimestampStore) apply(line []byte) {
+	var record publishedTimestampRecord
+	if err := json.Unmarshal(line, &record); err != nil || record.UUID == "" {
+		return
+	}
+	if record.Timestamp.After(s.timestamps[record.UUID]) {
+		s.timestamps[record.UUID] = record.Timestamp
+	}
+}
+
+// Latest first reads the timestamps published by the other replicas since the last call
+func (s *FilePublishedTimestampStore) Latest(uuid string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.log.follow(s.apply); err != nil {
+		log.WithField("uuid", uuid).WithError(err).Warn("Couldn't read the timestamps published by the other replicas")
+	}
+	timestamp, found := s.timestamps[uuid]
+	return timestamp, found
+}
+
+func (s *FilePublishedTimestampStore) Advance(uuid string, timestamp time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !timestamp.After(s.timestamps[uuid]) {
+		return
+	}
+	if err := s.log.append(publishedTimestampRecord{UUID: uuid, Timestamp: timestamp}); err != nil {
+		log.WithField("uuid", uuid).WithError(err).Warn("Couldn't record the published timestamp, older messages replayed on other replicas or after a restart won't be dropped")
+	}
+	s.timestamps[uuid] = timestamp
+	s.appended++
+	if s.appended < minTimestampLogLinesToCompact {
+		return
+	}
+	s.appended = 0
+	if err := s.log.follow(s.apply); err != nil {
+		log.WithError(err).Warn("Couldn't read the timestamps published by the other replicas")
+		return
+	}
+	s.evict()
+	if s.log.lines > 2*len(s.timestamps) {
+		if err := s.log.compact(s.apply, s.live); err != nil {
+			log.WithError(err).Warn("Couldn't compact the published timestamp log")
+		}
+	}
+}
+
+// evict forgets the UUIDs with the oldest timestamps beyond capacity
+func (s *FilePublishedTimestampStore) evict() {
+	if s.capacity <= 0 || len(s.timestamps) <= s.capacity {
+		return
+	}
+	records := make([]publishedTimestampRecord, 0, len(s.timestamps))
+	for uuid, timestamp := range s.timestamps {
+		records = append(records, publishedTimestampRecord{UUID: uuid, Timestamp: timestamp})
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Timestamp.Before(records[j].Timestamp) })
+	for _, record := range records[:len(records)-s.capacity] {
+		delete(s.timestamps, record.UUID)
+	}
+}
+
+func (s *FilePublishedTimestampStore) live() []interface{} {
+	s.evict()
+	records := make([]interface{}, 0, len(s.timestamps))
+	for uuid, timestamp := range s.timestamps {
+		records = append(records, publishedTimestampRecord{UUID: uuid, Timestamp: timestamp})
+	}
+	return records
+}
+
+func (s *FilePublishedTimestampStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.log.close()
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestInMemoryPublishedTimestampStore_NeverGoesBack(t *testing.T) {
+	store := NewInMemoryPublishedTimestampStore(10)
+	later := time.Date(2017, 5, 15, 15, 54, 32, 0, time.UTC)
+	store.Advance("512c1f3d-e48c-4618-863c-94bc9d913b9b", later)
+	store.Advance("512c1f3d-e48c-4618-863c-94bc9d913b9b", later.Add(-time.Minute))
+
+	latest, found := store.Latest("512c1f3d-e48c-4618-863c-94bc9d913b9b")
+	assert.True(t, found)
+	assert.Equal(t, later, latest)
+}
+
+func TestInMemoryPublishedTimestampStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	store := NewInMemoryPublishedTimestampStore(1)
+	store.Advance("uuid1", time.Now())
+	store.Advance("uuid2", time.Now())
+
+	_, found := store.Latest("uuid1")
+	assert.False(t, found)
+	_, found = store.Latest("uuid2")
+	assert.True(t, found)
+}
+
+func TestParseMessageTimestamp(t *testing.T) {
+	parsed, ok := parseMessageTimestamp("2017-05-15T15:54:32.166Z")
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2017, 5, 15, 15, 54, 32, 166000000, time.UTC), parsed.UTC())
+
+	_, ok = parseMessageTimestamp("yesterday")
+	assert.False(t, ok)
+}
+
+func TestOnMessageOlderThanLastPublished_NotSent(t *testing.T) {
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(nil)
+
+	q := newPairPublicationHandler(mockedProducer)
+	q.PublishedTimestamps = NewInMemoryPublishedTimestampStore(10)
+	latest := time.Date(2017, 5, 15, 16, 0, 0, 0, time.UTC)
+	q.PublishedTimestamps.Advance("512c1f3d-e48c-4618-863c-94bc9d913b9b", latest)
+
+	q.HandleMessage(pairSourceMessage())
+
+	mockedProducer.AssertNotCalled(t, "SendMessage", mock.Anything, mock.Anything)
+	outcome, found := q.PublicationLog.Get("512c1f3d-e48c-4618-863c-94bc9d913b9b")
+	assert.True(t, found)
+	assert.Equal(t, model.PublicationStale, outcome.Status)
+	assert.Len(t, outcome.Unpublished, 2)
+	stillLatest, _ := q.PublishedTimestamps.Latest("512c1f3d-e48c-4618-863c-94bc9d913b9b")
+	assert.Equal(t, latest, stillLatest)
+}
+
+func TestOnMessageOlderThanLastPublished_DeadLettered(t *testing.T) {
+	mockedProducer := new(model.MockProducer)
+	deadLetterQueue := new(model.MockDeadLetterQueue)
+	deadLetterQueue.On("Send", mock.Anything, StageStale, mock.Anything, "tid_test123").Return(nil)
+
+	q := newPairPublicationHandler(mockedProducer)
+	q.DeadLetterQueue = deadLetterQueue
+	q.PublishedTimestamps = NewInMemoryPublishedTimestampStore(10)
+	q.PublishedTimestamps.Advance("512c1f3d-e48c-4618-863c-94bc9d913b9b", time.Date(2017, 5, 15, 16, 0, 0, 0, time.UTC))
+
+	q.HandleMessage(pairSourceMessage())
+
+	deadLetterQueue.AssertNumberOfCalls(t, "Send", 1)
+	cause := deadLetterQueue.Calls[0].Arguments.Get(2).(error)
+	assert.Equal(t, "message not sent for uuid=512c1f3d-e48c-4618-863c-94bc9d913b9b is older than the message published at 2017-05-15T16:00:00.000Z", cause.Error())
+}
+
+func TestFilePublishedTimestampStore_SharedByReplicasAndSurvivesRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "publishedTimestamps")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "publishedTimestamps.log")
+	later := time.Date(2017, 5, 15, 15, 54, 32, 0, time.UTC)
+
+	replica1, err := NewFilePublishedTimestampStore(path, 10)
+	assert.NoError(t, err)
+	replica2, err := NewFilePublishedTimestampStore(path, 10)
+	assert.NoError(t, err)
+	replica1.Advance("512c1f3d-e48c-4618-863c-94bc9d913b9b", later)
+	replica2.Advance("512c1f3d-e48c-4618-863c-94bc9d913b9b", later.Add(-time.Minute))
+	latest, found := replica2.Latest("512c1f3d-e48c-4618-863c-94bc9d913b9b")
+	assert.True(t, found)
+	assert.True(t, later.Equal(latest))
+	assert.NoError(t, replica1.Close())
+	assert.NoError(t, replica2.Close())
+
+	reopened, err := NewFilePublishedTimestampStore(path, 10)
+	assert.NoError(t, err)
+	defer reopened.Close()
+	latest, found = reopened.Latest("512c1f3d-e48c-4618-863c-94bc9d913b9b")
+	assert.True(t, found)
+	assert.True(t, later.Equal(latest))
+}
+
+func TestFilePublishedTimestampStore_CompactsToCapacity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "publishedTimestamps")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "publishedTimestamps.log")
+	store, err := NewFilePublishedTimestampStore(path, 2)
+	assert.NoError(t, err)
+	defer store.Close()
+	start := time.Date(2017, 5, 15, 15, 54, 32, 0, time.UTC)
+
+	for i := 0; i < minTimestampLogLinesToCompact; i++ {
+		store.Advance(fmt.Sprintf("uuid%d", i%3), start.Add(time.Duration(i)*time.Second))
+	}
+
+	_, found := store.Latest("uuid1")
+	assert.False(t, found, "the UUID with the oldest timestamp should have been forgotten")
+	_, found = store.Latest("uuid0")
+	assert.True(t, found)
+	data, _ := ioutil.ReadFile(path)
+	assert.Equal(t, 2, strings.Count(string(data), "\n"))
+}
+
+func TestOnMessageNewerThanLastPublished_SentAndRecorded(t *testing.T) {
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("SendMessage", mock.Anything, mock.MatchedBy(func(msg producer.Message) bool { return true })).Return(nil)
+
+	q := newPairPublicationHandler(mockedProducer)
+	q.PublishedTimestamps = NewInMemoryPublishedTimestampStore(10)
+	q.PublishedTimestamps.Advance("512c1f3d-e48c-4618-863c-94bc9d913b9b", time.Date(2017, 5, 15, 15, 0, 0, 0, time.UTC))
+
+	q.HandleMessage(pairSourceMessage())
+
+	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 2)
+	latest, _ := q.PublishedTimestamps.Latest("512c1f3d-e48c-4618-863c-94bc9d913b9b")
+	assert.Equal(t, time.Date(2017, 5, 15, 15, 54, 32, 166000000, time.UTC), latest.UTC())
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"sync"
+
+	"github.com/Financial-Times/message-queue-gonsumer/consumer"
+)
+
+// OrderedWorkerPool processes messages concurrently on a fixed number of workers,
+// while messages sharing any ordering key are processed strictly in the order they were submitted.
+type OrderedWorkerPool struct {
+	process func(msg consumer.Message, parsed interface{})
+	keys    func(msg consumer.Message) ([]string, interface{})
+	queue   chan *orderedTask
+	mu      sync.Mutex
+	last    map[string]*orderedTask
+	wg      sync.WaitGroup
+}
+
+type orderedTask struct {
+	msg    consumer.Message
+	parsed interface{}
+	keys   []string
+	deps   []*orderedTask
+	done   chan struct{}
+}
+
+// NewOrderedWorkerPool starts size workers calling process for every submitted message.
+// keys returns the ordering keys of a message, e.g. the Methode UUID and the UUID it resolves to,
+// with what was parsed of the message to find them, which is passed on to process so the message isn't parsed twice.
+func NewOrderedWorkerPool(size int, keys func(msg consumer.Message) ([]string, interface{}), process func(msg consumer.Message, parsed interface{})) *OrderedWorkerPool {
+	if size < 1 {
+		size = 1
+	}
+	p := &OrderedWorkerPool{
+		process: process,
+		keys:    keys,
+		queue:   make(chan *orderedTask, size),
+		last:    make(map[string]*orderedTask),
+	}
+	p.wg.Add(size)
+	for i := 0; i < size; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Submit queues the message for processing, blocking while all the workers are busy
+func (p *OrderedWorkerPool) Submit(msg consumer.Message) {
+	keys, parsed := p.keys(msg)
+	t := &orderedTask{msg: msg, parsed: parsed, keys: dedupKeys(keys), done: make(chan struct{})}
+
+	p.mu.Lock()
+	for _, key := range t.keys {
+		if previous, found := p.last[key]; found {
+			t.deps = append(t.deps, previous)
+		}
+		p.last[key] = t
+	}
+	p.mu.Unlock()
+
+	p.queue <- t
+}
+
+// Stop waits for all the submitted messages to be processed and stops the workers.
+// Submit must not be called after Stop.
+func (p *OrderedWorkerPool) Stop() {
+	close(p.queue)
+	p.wg.Wait()
+}
+
+func (p *OrderedWorkerPool) work() {
+	defer p.wg.Done()
+	for t := range p.queue {
+		// tasks are dequeued in submission order, so every dependency is already taken by a worker
+		for _, dep := range t.deps {
+			<-dep.done
+		}
+		p.process(t.msg, t.parsed)
+		p.complete(t)
+	}
+}
+
+func (p *OrderedWorkerPool) complete(t *orderedTask) {
+	p.mu.Lock()
+	for _, key := range t.keys {
+		if p.last[key] == t {
+			delete(p.last, key)
+		}
+	}
+	p.mu.Unlock()
+	t.deps, t.parsed = nil, nil
+	close(t.done)
+}
+
+func dedupKeys(keys []string) []string {
+	var unique []string
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, key)
+	}
+	return unique
+}
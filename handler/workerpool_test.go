@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/message-queue-gonsumer/consumer"
+	"github.com/stretchr/testify/assert"
+)
+
+func keysFromHeader(msg consumer.Message) ([]string, interface{}) {
+	return strings.Split(msg.Headers["keys"], ","), msg.Headers["keys"]
+}
+
+func TestOrderedWorkerPool_SameKeyProcessedInOrder(t *testing.T) {
+	var mu sync.Mutex
+	processed := make(map[string][]int)
+
+	pool := NewOrderedWorkerPool(8, keysFromHeader, func(msg consumer.Message, parsed interface{}) {
+		assert.Equal(t, msg.Headers["keys"], parsed, "what keys parsed should be passed on")
+		time.Sleep(time.Duration(rand.Intn(500)) * time.Microsecond)
+		seq, _ := strconv.Atoi(msg.Body)
+		mu.Lock()
+		processed[msg.Headers["keys"]] = append(processed[msg.Headers["keys"]], seq)
+		mu.Unlock()
+	})
+
+	for i := 0; i < 500; i++ {
+		key := "uuid-" + strconv.Itoa(i%7)
+		pool.Submit(consumer.Message{Headers: map[string]string{"keys": key}, Body: strconv.Itoa(i)})
+	}
+	pool.Stop()
+
+	total := 0
+	for key, seqs := range processed {
+		total += len(seqs)
+		for i := 1; i < len(seqs); i++ {
+			assert.True(t, seqs[i-1] < seqs[i], "messages for %v processed out of order: %v", key, seqs)
+		}
+	}
+	assert.Equal(t, 500, total)
+}
+
+func TestOrderedWorkerPool_DifferentKeysProcessedConcurrently(t *testing.T) {
+	var started sync.WaitGroup
+	started.Add(4)
+	allStarted := make(chan struct{})
+	go func() {
+		started.Wait()
+		close(allStarted)
+	}()
+
+	pool := NewOrderedWorkerPool(4, keysFromHeader, func(msg consumer.Message, parsed interface{}) {
+		started.Done()
+		<-allStarted
+	})
+
+	for i := 0; i < 4; i++ {
+		pool.Submit(consumer.Message{Headers: map[string]string{"keys": "uuid-" + strconv.Itoa(i)}})
+	}
+
+	select {
+	case <-allStarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("messages with different keys should be processed concurrently")
+	}
+	pool.Stop()
+}
+
+func TestOrderedWorkerPool_SharedSecondaryKeyWaitsForPredecessor(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	firstRunning := make(chan struct{})
+	releaseFirst := make(chan struct{})
+
+	pool := NewOrderedWorkerPool(4, keysFromHeader, func(msg consumer.Message, parsed interface{}) {
+		if msg.Body == "first" {
+			close(firstRunning)
+			<-releaseFirst
+		}
+		mu.Lock()
+		order = append(order, msg.Body)
+		mu.Unlock()
+	})
+
+	// two different Methode placeholders resolving to the same internal UUID
+	pool.Submit(consumer.Message{Headers: map[string]string{"keys": "methode-1,internal-1"}, Body: "first"})
+	<-firstRunning
+	pool.Submit(consumer.Message{Headers: map[string]string{"keys": "methode-2,internal-1"}, Body: "second"})
+	pool.Submit(consumer.Message{Headers: map[string]string{"keys": "methode-3"}, Body: "unrelated"})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		processedCount := len(order)
+		mu.Unlock()
+		if processedCount == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the unrelated message should not wait")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(releaseFirst)
+	pool.Stop()
+
+	assert.Equal(t, []string{"unrelated", "first", "second"}, order)
+}
+
+func TestOrderedWorkerPool_StopDrainsSubmittedMessages(t *testing.T) {
+	var mu sync.Mutex
+	count := 0
+
+	pool := NewOrderedWorkerPool(2, keysFromHeader, func(msg consumer.Message, parsed interface{}) {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		count++
+		mu.Unlock()
+	})
+	for i := 0; i < 20; i++ {
+		pool.Submit(consumer.Message{Headers: map[string]string{"keys": "uuid-1"}})
+	}
+	pool.Stop()
+
+	assert.Equal(t, 20, count)
+	assert.Empty(t, pool.last, "ordering keys of processed messages should be released")
+}
@@ -1,61 +1,84 @@
 package mapper
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
 	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	log "github.com/Sirupsen/logrus"
 	gouuid "github.com/satori/go.uuid"
 )
 
-var blogCategories = []string{"blog", "webchat-live-blogs", "webchat-live-qa", "webchat-markets-live", "fastft"}
-
 type CPHAggregateMapper interface {
-	MapContentPlaceholder(mpc *model.MethodeContentPlaceholder, tid, lmd string) ([]model.UppContent, error)
+	MapContentPlaceholder(ctx context.Context, mpc *model.MethodeContentPlaceholder, tid, lmd string) ([]model.UppContent, error)
 }
 
 type CPHMapper interface {
-	MapContentPlaceholder(mpc *model.MethodeContentPlaceholder, uuid, tid, lmd string) ([]model.UppContent, error)
+	MapContentPlaceholder(ctx context.Context, mpc *model.MethodeContentPlaceholder, uuid, tid, lmd string) ([]model.UppContent, error)
 }
 
 type DefaultCPHAggregateMapper struct {
-	iResolver    IResolver
-	cphMappers   []CPHMapper
-	cphValidator CPHValidator
+	iResolver       IResolver
+	cphMappers      []CPHMapper
+	cphValidator    CPHValidator
+	categoryRouting *CategoryRoutingTable
 }
 
-func NewAggregateCPHMapper(iResolver IResolver, validator CPHValidator, cphMappers []CPHMapper) *DefaultCPHAggregateMapper {
-	return &DefaultCPHAggregateMapper{iResolver: iResolver, cphValidator: validator, cphMappers: cphMappers}
+func NewAggregateCPHMapper(iResolver IResolver, validator CPHValidator, categoryRouting *CategoryRoutingTable, cphMappers []CPHMapper) *DefaultCPHAggregateMapper {
+	return &DefaultCPHAggregateMapper{iResolver: iResolver, cphValidator: validator, categoryRouting: categoryRouting, cphMappers: cphMappers}
 }
 
-func (m *DefaultCPHAggregateMapper) MapContentPlaceholder(mpc *model.MethodeContentPlaceholder, tid, lmd string) ([]model.UppContent, error) {
-	err := m.cphValidator.Validate(mpc)
+func (m *DefaultCPHAggregateMapper) MapContentPlaceholder(ctx context.Context, mpc *model.MethodeContentPlaceholder, tid, lmd string) ([]model.UppContent, error) {
+	strategy, err := m.categoryRouting.Route(mpc.Attributes.Category, tid)
+	var unknownCategoryErr *UnknownCategoryError
+	if errors.As(err, &unknownCategoryErr) && m.isGenericContent(mpc) {
+		// only ignoring its category keeps a placeholder with an OriginalUUID from being mapped as generic content
+		strategy, err = StrategyGeneric, nil
+	}
 	if err != nil {
-		return nil, err
+		return nil, model.WithStage(model.MappingStageCategoryRouting, err)
+	}
+	if strategy == StrategyIgnore {
+		return nil, ignoredCategoryError(mpc.Attributes.Category)
+	}
+
+	report := m.cphValidator.Validate(mpc)
+	if strategy == StrategyBlog && !m.isGenericContent(mpc) {
+		validateBlogAttributes(mpc, &report)
+	}
+	// only external placeholders are published with the publication date of the native placeholder
+	if strategy == StrategyExternal && !m.isGenericContent(mpc) && !mpc.Attributes.IsDeleted {
+		validatePublicationDate(mpc, &report)
+	}
+	if err := report.Err(); err != nil {
+		return nil, model.WithStage(model.MappingStageValidation, err)
+	}
+	for _, warning := range report.Warnings() {
+		log.WithField("transaction_id", tid).WithField("uuid", mpc.UUID).WithField("field", warning.Field).WithField("code", warning.Code).Warn(warning.Message)
 	}
 	uuid := ""
 
+	// a placeholder with an OriginalUUID points to UPP content whatever its category
 	if m.isGenericContent(mpc) {
 		resolvedUUID, err := gouuid.FromString(mpc.Attributes.OriginalUUID)
 		if err != nil {
-			return nil, fmt.Errorf("invalid generic uuid: %v", err)
+			return nil, model.WithStage(model.MappingStageValidation, fmt.Errorf("invalid generic uuid: %v", err))
 		}
 		uuid = resolvedUUID.String()
-		found, err := m.iResolver.ContentExists(uuid, tid)
+		found, err := m.iResolver.ContentExists(ctx, uuid, tid)
 		if err != nil {
-			return nil, fmt.Errorf("couldn't check OriginalUUID in document store: %v", err)
+			return nil, model.WithStage(model.MappingStageIdentifierResolution, fmt.Errorf("couldn't check OriginalUUID in document store: %w", err))
 		}
 		if !found {
-			return nil, fmt.Errorf("couldn't find OriginalUUID %s in document store", uuid)
+			return nil, model.WithStage(model.MappingStageIdentifierResolution, fmt.Errorf("couldn't find OriginalUUID %s in document store", uuid))
 		}
-	} else if m.isBlogCategory(mpc) {
-		err = m.validateBlogCPH(mpc)
+	} else if strategy == StrategyGeneric {
+		return nil, model.WithStage(model.MappingStageValidation, fmt.Errorf("placeholder of category=%q should have an OriginalUUID", mpc.Attributes.Category))
+	} else if strategy == StrategyBlog {
+		uuid, err = m.iResolver.ResolveIdentifier(ctx, mpc.Attributes.ServiceId, mpc.Attributes.RefField, tid)
 		if err != nil {
-			return nil, err
-		}
-		uuid, err = m.iResolver.ResolveIdentifier(mpc.Attributes.ServiceId, mpc.Attributes.RefField, tid)
-		if err != nil {
-			return nil, fmt.Errorf("couldn't resolve blog uuid: %v", err)
+			return nil, model.WithStage(model.MappingStageIdentifierResolution, fmt.Errorf("couldn't resolve blog uuid: %w", err))
 		}
 	}
 
@@ -63,31 +86,15 @@ func (m *DefaultCPHAggregateMapper) MapContentPlaceholder(mpc *model.MethodeCont
 
 	var transformedResults []model.UppContent
 	for _, cphMapper := range m.cphMappers {
-		transformedContents, err := cphMapper.MapContentPlaceholder(mpc, uuid, tid, lmd)
+		transformedContents, err := cphMapper.MapContentPlaceholder(ctx, mpc, uuid, tid, lmd)
 		if err != nil {
-			return nil, err
+			return nil, model.WithStage(model.MappingStageContentMapping, err)
 		}
 		transformedResults = append(transformedResults, transformedContents...)
 	}
 	return transformedResults, nil
 }
 
-func (m *DefaultCPHAggregateMapper) isBlogCategory(mcp *model.MethodeContentPlaceholder) bool {
-	for _, c := range blogCategories {
-		if c == mcp.Attributes.Category {
-			return true
-		}
-	}
-	return false
-}
-
-func (m *DefaultCPHAggregateMapper) validateBlogCPH(mcp *model.MethodeContentPlaceholder) error {
-	if mcp.Attributes.ServiceId == "" || mcp.Attributes.RefField == "" {
-		return errors.New("blog attributes ServiceId, ref_field should not be empty")
-	}
-	return nil
-}
-
 func (m *DefaultCPHAggregateMapper) isGenericContent(mcp *model.MethodeContentPlaceholder) bool {
 	return mcp.Attributes.OriginalUUID != ""
 }
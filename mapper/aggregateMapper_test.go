@@ -1,6 +1,7 @@
 package mapper
 
 import (
+	"context"
 	"strings"
 	"testing"
 
@@ -16,7 +17,7 @@ func TestAggregateMapperMap_Ok(t *testing.T) {
 	mockContentMapper := new(model.MockCPHMapper)
 	mockCompContentMapper := new(model.MockCPHMapper)
 
-	givenMethodeCPH := &model.MethodeContentPlaceholder{}
+	givenMethodeCPH := &model.MethodeContentPlaceholder{Attributes: model.Attributes{LastPublicationDate: "20170515155432"}}
 
 	expectedUppContents := []model.UppContent{
 		&model.UppContentPlaceholder{
@@ -41,7 +42,7 @@ func TestAggregateMapperMap_Ok(t *testing.T) {
 
 	mockValidator.On("Validate",
 		mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true })).
-		Return(nil)
+		Return(model.ValidationReport{})
 
 	mockResolver.On("ResolveIdentifier",
 		mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true }),
@@ -64,9 +65,9 @@ func TestAggregateMapperMap_Ok(t *testing.T) {
 		mock.MatchedBy(func(lmd string) bool { return true })).
 		Return([]model.UppContent{expectedUppContents[1]}, nil)
 
-	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, []CPHMapper{mockContentMapper, mockCompContentMapper})
+	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, DefaultCategoryRouting(), []CPHMapper{mockContentMapper, mockCompContentMapper})
 
-	actualUppContents, err := aggregateMapper.MapContentPlaceholder(givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
+	actualUppContents, err := aggregateMapper.MapContentPlaceholder(context.Background(), givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
 	assert.NoError(t, err, "No error should be thrown for correct mapping.")
 
 	assert.Equal(t, expectedUppContents[0], actualUppContents[0])
@@ -79,15 +80,15 @@ func TestAggregateMapperValidationError_ThrowsException(t *testing.T) {
 	mockContentMapper := new(model.MockCPHMapper)
 	mockCompContentMapper := new(model.MockCPHMapper)
 
-	givenMethodeCPH := &model.MethodeContentPlaceholder{}
+	givenMethodeCPH := &model.MethodeContentPlaceholder{Attributes: model.Attributes{LastPublicationDate: "20170515155432"}}
 
 	mockValidator.On("Validate",
 		mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true })).
-		Return(errors.New("Some validation error"))
+		Return(model.ValidationReport{Issues: []model.ValidationIssue{{Field: "body.leadHeadline.text", Code: model.CodeMissingHeadlineText, Severity: model.SeverityError, Message: "Some validation error"}}})
 
-	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, []CPHMapper{mockContentMapper, mockCompContentMapper})
+	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, DefaultCategoryRouting(), []CPHMapper{mockContentMapper, mockCompContentMapper})
 
-	_, err := aggregateMapper.MapContentPlaceholder(givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
+	_, err := aggregateMapper.MapContentPlaceholder(context.Background(), givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
 	assert.Error(t, err, "An error should be thrown for validation error.")
 }
 
@@ -129,7 +130,7 @@ func TestAggregateMapperIResolverUUID_IsSet(t *testing.T) {
 
 	mockValidator.On("Validate",
 		mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true })).
-		Return(nil)
+		Return(model.ValidationReport{})
 
 	mockResolver.On("ResolveIdentifier",
 		mock.MatchedBy(func(uuid string) bool { return true }),
@@ -151,9 +152,9 @@ func TestAggregateMapperIResolverUUID_IsSet(t *testing.T) {
 		mock.MatchedBy(func(lmd string) bool { return true })).
 		Return([]model.UppContent{expectedUppContents[1]}, nil)
 
-	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, []CPHMapper{mockContentMapper, mockCompContentMapper})
+	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, DefaultCategoryRouting(), []CPHMapper{mockContentMapper, mockCompContentMapper})
 
-	actualUppContents, err := aggregateMapper.MapContentPlaceholder(givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
+	actualUppContents, err := aggregateMapper.MapContentPlaceholder(context.Background(), givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
 	assert.NoError(t, err, "No error should be thrown for correct mapping.")
 
 	assert.Equal(t, "abac1f3d-e48c-4618-863c-94bc9d913b9b", actualUppContents[0].GetUUID())
@@ -177,7 +178,7 @@ func TestAggregateMapperIResolverError_ThrowsError(t *testing.T) {
 
 	mockValidator.On("Validate",
 		mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true })).
-		Return(nil)
+		Return(model.ValidationReport{})
 
 	mockResolver.On("ResolveIdentifier",
 		mock.MatchedBy(func(uuid string) bool { return true }),
@@ -185,9 +186,9 @@ func TestAggregateMapperIResolverError_ThrowsError(t *testing.T) {
 		mock.MatchedBy(func(lmd string) bool { return true })).
 		Return("", errors.New("Could not resolve uuid"))
 
-	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, []CPHMapper{mockContentMapper, mockCompContentMapper})
+	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, DefaultCategoryRouting(), []CPHMapper{mockContentMapper, mockCompContentMapper})
 
-	_, err := aggregateMapper.MapContentPlaceholder(givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
+	_, err := aggregateMapper.MapContentPlaceholder(context.Background(), givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
 	assert.Error(t, err, "An error should be thrown when could not resolve uuid.")
 }
 
@@ -200,9 +201,10 @@ func TestAggregateMapperNotBlog_NoUUIDResolved(t *testing.T) {
 	givenMethodeCPH := &model.MethodeContentPlaceholder{
 		UUID: "cdac1f3d-e48c-4618-863c-94bc9d913b9b",
 		Attributes: model.Attributes{
-			Category:  "not-a-blog-category",
-			ServiceId: "1111",
-			RefField:  "7777",
+			Category:            "not-a-blog-category",
+			ServiceId:           "1111",
+			RefField:            "7777",
+			LastPublicationDate: "20170515155432",
 		},
 	}
 
@@ -229,7 +231,7 @@ func TestAggregateMapperNotBlog_NoUUIDResolved(t *testing.T) {
 
 	mockValidator.On("Validate",
 		mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true })).
-		Return(nil)
+		Return(model.ValidationReport{})
 
 	mockResolver.On("ResolveIdentifier",
 		mock.MatchedBy(func(uuid string) bool { return true }),
@@ -251,9 +253,11 @@ func TestAggregateMapperNotBlog_NoUUIDResolved(t *testing.T) {
 		mock.MatchedBy(func(lmd string) bool { return true })).
 		Return([]model.UppContent{expectedUppContents[1]}, nil)
 
-	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, []CPHMapper{mockContentMapper, mockCompContentMapper})
+	routing, err := NewCategoryRoutingTable(map[string]ResolutionStrategy{"blog": StrategyBlog}, StrategyExternal)
+	assert.NoError(t, err)
+	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, routing, []CPHMapper{mockContentMapper, mockCompContentMapper})
 
-	actualUppContents, err := aggregateMapper.MapContentPlaceholder(givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
+	actualUppContents, err := aggregateMapper.MapContentPlaceholder(context.Background(), givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
 	assert.NoError(t, err, "No error should be thrown for correct mapping.")
 
 	assert.Equal(t, "cdac1f3d-e48c-4618-863c-94bc9d913b9b", actualUppContents[0].GetUUID())
@@ -287,7 +291,7 @@ func TestAggregateMapperGenericUUIDResolved(t *testing.T) {
 
 	mockValidator.On("Validate",
 		mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true })).
-		Return(nil)
+		Return(model.ValidationReport{})
 
 	mockResolver.On("ContentExists", "075d679e-0033-11e8-9650-9c0ad2d7c5b5", "tid_test123").Return(true, nil)
 
@@ -305,9 +309,9 @@ func TestAggregateMapperGenericUUIDResolved(t *testing.T) {
 		mock.MatchedBy(func(lmd string) bool { return true })).
 		Return([]model.UppContent{expectedUppContents[0]}, nil)
 
-	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, []CPHMapper{mockCompContentMapper})
+	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, DefaultCategoryRouting(), []CPHMapper{mockCompContentMapper})
 
-	actualUppContents, err := aggregateMapper.MapContentPlaceholder(givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
+	actualUppContents, err := aggregateMapper.MapContentPlaceholder(context.Background(), givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
 	assert.NoError(t, err, "No error should be thrown for correct mapping.")
 	assert.Equal(t, "075d679e-0033-11e8-9650-9c0ad2d7c5b5", actualUppContents[0].GetUUID())
 }
@@ -327,7 +331,7 @@ func TestAggregateMapperGenericUUIDNotResolved(t *testing.T) {
 
 	mockValidator.On("Validate",
 		mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true })).
-		Return(nil)
+		Return(model.ValidationReport{})
 
 	mockResolver.On("ContentExists", "075d679e-0033-11e8-9650-9c0ad2d7c5b5", "tid_test123").Return(false, nil)
 
@@ -345,9 +349,9 @@ func TestAggregateMapperGenericUUIDNotResolved(t *testing.T) {
 		mock.MatchedBy(func(lmd string) bool { return true })).
 		Return([]model.UppContent{}, nil)
 
-	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, []CPHMapper{mockCompContentMapper})
+	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, DefaultCategoryRouting(), []CPHMapper{mockCompContentMapper})
 
-	_, err := aggregateMapper.MapContentPlaceholder(givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
+	_, err := aggregateMapper.MapContentPlaceholder(context.Background(), givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
 	assert.Error(t, err, "Error should be thrown for correct mapping.")
 }
 
@@ -379,7 +383,7 @@ func TestAggregateMapperGenerigAndBlog(t *testing.T) {
 	}
 	mockValidator.On("Validate",
 		mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true })).
-		Return(nil)
+		Return(model.ValidationReport{})
 
 	mockResolver.On("ContentExists", "075d679e-0033-11e8-9650-9c0ad2d7c5b5", "tid_test123").Return(true, nil)
 
@@ -397,9 +401,9 @@ func TestAggregateMapperGenerigAndBlog(t *testing.T) {
 		mock.MatchedBy(func(lmd string) bool { return true })).
 		Return([]model.UppContent{expectedUppContents[0]}, nil)
 
-	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, []CPHMapper{mockCompContentMapper})
+	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, DefaultCategoryRouting(), []CPHMapper{mockCompContentMapper})
 
-	actualUppContents, err := aggregateMapper.MapContentPlaceholder(givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
+	actualUppContents, err := aggregateMapper.MapContentPlaceholder(context.Background(), givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
 	assert.NoError(t, err, "No error should be thrown for correct mapping.")
 	assert.Equal(t, "075d679e-0033-11e8-9650-9c0ad2d7c5b5", actualUppContents[0].GetUUID())
 }
@@ -417,11 +421,11 @@ func TestAggregateMapperInvalidBlog(t *testing.T) {
 	}
 	mockValidator.On("Validate",
 		mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true })).
-		Return(nil)
+		Return(model.ValidationReport{})
 
-	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, []CPHMapper{})
+	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, DefaultCategoryRouting(), []CPHMapper{})
 
-	_, err := aggregateMapper.MapContentPlaceholder(givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
+	_, err := aggregateMapper.MapContentPlaceholder(context.Background(), givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
 	assert.Error(t, err, "blog attributes ServiceId ref_field should not be empty")
 }
 func TestAggregateMapperGenericInvalidUUID(t *testing.T) {
@@ -438,7 +442,7 @@ func TestAggregateMapperGenericInvalidUUID(t *testing.T) {
 
 	mockValidator.On("Validate",
 		mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true })).
-		Return(nil)
+		Return(model.ValidationReport{})
 
 	mockCompContentMapper.On("MapContentPlaceholder",
 		mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true }),
@@ -454,9 +458,9 @@ func TestAggregateMapperGenericInvalidUUID(t *testing.T) {
 		mock.MatchedBy(func(lmd string) bool { return true })).
 		Return([]model.UppContent{}, nil)
 
-	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, []CPHMapper{mockCompContentMapper})
+	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, DefaultCategoryRouting(), []CPHMapper{mockCompContentMapper})
 
-	_, err := aggregateMapper.MapContentPlaceholder(givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
+	_, err := aggregateMapper.MapContentPlaceholder(context.Background(), givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
 	assert.Error(t, err, "error should be thrown for correct mapping.")
 }
 func TestAggregateMapperMappingError_ThrowsError(t *testing.T) {
@@ -465,11 +469,11 @@ func TestAggregateMapperMappingError_ThrowsError(t *testing.T) {
 	mockContentMapper := new(model.MockCPHMapper)
 	mockCompContentMapper := new(model.MockCPHMapper)
 
-	givenMethodeCPH := &model.MethodeContentPlaceholder{}
+	givenMethodeCPH := &model.MethodeContentPlaceholder{Attributes: model.Attributes{LastPublicationDate: "20170515155432"}}
 
 	mockValidator.On("Validate",
 		mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true })).
-		Return(nil)
+		Return(model.ValidationReport{})
 
 	mockResolver.On("ResolveIdentifier",
 		mock.MatchedBy(func(uuid string) bool { return true }),
@@ -484,8 +488,116 @@ func TestAggregateMapperMappingError_ThrowsError(t *testing.T) {
 		mock.MatchedBy(func(lmd string) bool { return true })).
 		Return([]model.UppContent{}, errors.New("Some mapping error"))
 
-	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, []CPHMapper{mockContentMapper, mockCompContentMapper})
+	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, DefaultCategoryRouting(), []CPHMapper{mockContentMapper, mockCompContentMapper})
 
-	_, err := aggregateMapper.MapContentPlaceholder(givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
+	_, err := aggregateMapper.MapContentPlaceholder(context.Background(), givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
 	assert.Error(t, err, "Error should be thrown for error in one of the contained mappers.")
 }
+
+func TestAggregateMapperPublicationDateOnlyValidatedForExternalPlaceholders(t *testing.T) {
+	mockResolver := new(model.MockIResolver)
+	mockResolver.On("ResolveIdentifier", mock.Anything, mock.Anything, mock.Anything).Return("512c1f3d-e48c-4618-863c-94bc9d913b9b", nil)
+	mockValidator := new(model.MockCPHValidator)
+	mockValidator.On("Validate", mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true })).Return(model.ValidationReport{})
+	mockCompContentMapper := new(model.MockCPHMapper)
+	mockCompContentMapper.On("MapContentPlaceholder", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return([]model.UppContent{&model.UppComplementaryContent{UppCoreContent: model.UppCoreContent{UUID: "512c1f3d-e48c-4618-863c-94bc9d913b9b"}}}, nil)
+	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, DefaultCategoryRouting(), []CPHMapper{mockCompContentMapper})
+
+	blogCPH := &model.MethodeContentPlaceholder{
+		UUID:       "cdac1f3d-e48c-4618-863c-94bc9d913b9b",
+		Attributes: model.Attributes{Category: "blog", ServiceId: "http://ftalphaville.ft.com/?p=2193913", RefField: "2193913", LastPublicationDate: "2017-05-15"},
+	}
+	_, err := aggregateMapper.MapContentPlaceholder(context.Background(), blogCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
+	assert.NoError(t, err, "the publication date of a blog placeholder isn't mapped")
+
+	externalCPH := &model.MethodeContentPlaceholder{
+		UUID:       "cdac1f3d-e48c-4618-863c-94bc9d913b9b",
+		Attributes: model.Attributes{LastPublicationDate: "2017-05-15"},
+	}
+	_, err = aggregateMapper.MapContentPlaceholder(context.Background(), externalCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
+	validationErr, ok := model.AsValidationError(err)
+	assert.True(t, ok)
+	assert.Equal(t, model.CodeInvalidPublicationDate, validationErr.Report.Issues[0].Code)
+}
+
+func TestAggregateMapperIgnoredCategory(t *testing.T) {
+	mockResolver := new(model.MockIResolver)
+	mockValidator := new(model.MockCPHValidator)
+	mockContentMapper := new(model.MockCPHMapper)
+	mockValidator.On("Validate", mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true })).Return(model.ValidationReport{})
+
+	routing, err := NewCategoryRoutingTable(map[string]ResolutionStrategy{"podcast": StrategyIgnore}, StrategyExternal)
+	assert.NoError(t, err)
+	givenMethodeCPH := &model.MethodeContentPlaceholder{
+		UUID:       "cdac1f3d-e48c-4618-863c-94bc9d913b9b",
+		Attributes: model.Attributes{Category: "podcast", OriginalUUID: "075d679e-0033-11e8-9650-9c0ad2d7c5b5"},
+	}
+
+	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, routing, []CPHMapper{mockContentMapper})
+	_, err = aggregateMapper.MapContentPlaceholder(context.Background(), givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
+
+	assert.IsType(t, &model.InvalidMethodeCPH{}, err)
+	mockResolver.AssertNumberOfCalls(t, "ContentExists", 0)
+	mockContentMapper.AssertNumberOfCalls(t, "MapContentPlaceholder", 0)
+}
+
+func TestAggregateMapperUnknownCategory(t *testing.T) {
+	mockValidator := new(model.MockCPHValidator)
+	mockValidator.On("Validate", mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true })).Return(model.ValidationReport{})
+
+	routing, err := NewCategoryRoutingTable(map[string]ResolutionStrategy{"blog": StrategyBlog}, "")
+	assert.NoError(t, err)
+	givenMethodeCPH := &model.MethodeContentPlaceholder{
+		UUID:       "cdac1f3d-e48c-4618-863c-94bc9d913b9b",
+		Attributes: model.Attributes{Category: "new-live-blog"},
+	}
+
+	aggregateMapper := NewAggregateCPHMapper(new(model.MockIResolver), mockValidator, routing, []CPHMapper{})
+	_, err = aggregateMapper.MapContentPlaceholder(context.Background(), givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
+
+	assert.Equal(t, (&UnknownCategoryError{Category: "new-live-blog"}).Error(), err.Error())
+	assert.Equal(t, model.MappingStageCategoryRouting, model.StageOf(err))
+}
+
+func TestAggregateMapperUnknownCategoryWithOriginalUUID(t *testing.T) {
+	mockResolver := new(model.MockIResolver)
+	mockResolver.On("ContentExists", "075d679e-0033-11e8-9650-9c0ad2d7c5b5", "tid_test123").Return(true, nil)
+	mockValidator := new(model.MockCPHValidator)
+	mockValidator.On("Validate", mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true })).Return(model.ValidationReport{})
+	mockCompContentMapper := new(model.MockCPHMapper)
+	mockCompContentMapper.On("MapContentPlaceholder",
+		mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true }),
+		"075d679e-0033-11e8-9650-9c0ad2d7c5b5", "tid_test123",
+		mock.MatchedBy(func(lmd string) bool { return true })).
+		Return([]model.UppContent{&model.UppComplementaryContent{UppCoreContent: model.UppCoreContent{UUID: "075d679e-0033-11e8-9650-9c0ad2d7c5b5"}}}, nil)
+
+	givenMethodeCPH := &model.MethodeContentPlaceholder{
+		UUID:       "cdac1f3d-e48c-4618-863c-94bc9d913b9b",
+		Attributes: model.Attributes{Category: "new-live-blog", OriginalUUID: "075d679e-0033-11e8-9650-9c0ad2d7c5b5"},
+	}
+
+	aggregateMapper := NewAggregateCPHMapper(mockResolver, mockValidator, DefaultCategoryRouting(), []CPHMapper{mockCompContentMapper})
+	actualUppContents, err := aggregateMapper.MapContentPlaceholder(context.Background(), givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
+
+	assert.NoError(t, err, "a placeholder with an OriginalUUID should be mapped whatever its category")
+	assert.Equal(t, "075d679e-0033-11e8-9650-9c0ad2d7c5b5", actualUppContents[0].GetUUID())
+}
+
+func TestAggregateMapperGenericCategoryWithoutOriginalUUID(t *testing.T) {
+	mockValidator := new(model.MockCPHValidator)
+	mockValidator.On("Validate", mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true })).Return(model.ValidationReport{})
+
+	routing, err := NewCategoryRoutingTable(map[string]ResolutionStrategy{"ft-content": StrategyGeneric}, "")
+	assert.NoError(t, err)
+	givenMethodeCPH := &model.MethodeContentPlaceholder{
+		UUID:       "cdac1f3d-e48c-4618-863c-94bc9d913b9b",
+		Attributes: model.Attributes{Category: "ft-content"},
+	}
+
+	aggregateMapper := NewAggregateCPHMapper(new(model.MockIResolver), mockValidator, routing, []CPHMapper{})
+	_, err = aggregateMapper.MapContentPlaceholder(context.Background(), givenMethodeCPH, "tid_test123", "2017-05-15T15:54:32.166Z")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "OriginalUUID")
+}
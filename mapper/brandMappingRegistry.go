@@ -0,0 +1,253 @@
+package mapper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// BrandMappingMatcher finds the mapping key and authority of a WordPress serviceId
+type BrandMappingMatcher interface {
+	Match(serviceID string) (key string, authority string, err error)
+}
+
+// BrandMappingVersion describes the brand mappings currently in use
+type BrandMappingVersion struct {
+	Version  string    `json:"version"`
+	LoadedAt time.Time `json:"loadedAt"`
+	Source   string    `json:"source"`
+}
+
+type brandMappingSnapshot struct {
+	table   *BrandMappingTable
+	version BrandMappingVersion
+}
+
+// InvalidBrandMappingsError is returned by Update when the new brand mappings are rejected
+type InvalidBrandMappingsError struct {
+	err error
+}
+
+func (e *InvalidBrandMappingsError) Error() string {
+	return e.err.Error()
+}
+
+func (e *InvalidBrandMappingsError) Unwrap() error {
+	return e.err
+}
+
+// BrandMappingsVersionMismatchError is returned by Update when the brand mappings were changed
+// since the version the update was based on, e.g. by another replica or another administrator
+type BrandMappingsVersionMismatchError struct {
+	Expected string
+	Current  string
+}
+
+func (e *BrandMappingsVersionMismatchError) Error() string {
+	return fmt.Sprintf("brand mappings version is %v, not %v", e.Current, e.Expected)
+}
+
+// BrandMappingRegistry holds the active brand mappings, which can be replaced at runtime either
+// by changing the configuration file or through Update. A new configuration is validated before
+// being swapped in, so a bad one never replaces a good one.
+type BrandMappingRegistry struct {
+	path     string
+	current  atomic.Value
+	updating sync.Mutex
+	stop     chan struct{}
+	stopped  sync.WaitGroup
+}
+
+// NewBrandMappingRegistry loads the brand mappings from the given file
+func NewBrandMappingRegistry(path string) (*BrandMappingRegistry, error) {
+	r := &BrandMappingRegistry{path: path}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.swap(data, "file"); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// CopyBrandMappingsIfMissing creates the configuration file at path from defaultPath if it doesn't exist yet,
+// e.g. to initialise the brand mappings kept on a volume shared by the replicas
+func CopyBrandMappingsIfMissing(path string, defaultPath string) error {
+	return lockFile(path+".lock", func() error {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			return err
+		}
+		data, err := ioutil.ReadFile(defaultPath)
+		if err != nil {
+			return err
+		}
+		log.WithField("path", path).WithField("default", defaultPath).Info("Initialising brand mappings from the default file")
+		return writeFileAtomically(path, data)
+	})
+}
+
+// Match resolves the serviceId against the active brand mappings
+func (r *BrandMappingRegistry) Match(serviceID string) (string, string, error) {
+	return r.snapshot().table.Match(serviceID)
+}
+
+// Current returns the active brand mappings and their version
+func (r *BrandMappingRegistry) Current() (map[string]string, BrandMappingVersion) {
+	s := r.snapshot()
+	return s.table.Mappings(), s.version
+}
+
+// Update validates the JSON brand mappings, persists them to the configuration file and makes them active.
+// When expectedVersion isn't empty, the update is only made if the configuration file still holds that version.
+// The check and the write are made under a lock shared by every replica using the same file, e.g. on a shared volume,
+// so concurrent updates can't overwrite each other.
+func (r *BrandMappingRegistry) Update(data []byte, source string, expectedVersion string) (BrandMappingVersion, error) {
+	r.updating.Lock()
+	defer r.updating.Unlock()
+
+	if _, err := ParseBrandMappings(data); err != nil {
+		return BrandMappingVersion{}, &InvalidBrandMappingsError{err: err}
+	}
+	var version BrandMappingVersion
+	err := lockFile(r.path+".lock", func() error {
+		persisted, err := ioutil.ReadFile(r.path)
+		if err != nil {
+			return fmt.Errorf("couldn't read brand mappings from %v: %w", r.path, err)
+		}
+		if current := brandMappingsVersion(persisted); expectedVersion != "" && current != expectedVersion {
+			if current != r.snapshot().version.Version {
+				// the file was changed by another replica since it was last reloaded
+				if _, err := r.swap(persisted, "file"); err != nil {
+					log.WithField("path", r.path).WithError(err).Error("Invalid brand mapping configuration, keeping the active brand mappings")
+				}
+			}
+			return &BrandMappingsVersionMismatchError{Expected: expectedVersion, Current: current}
+		}
+		if err := writeFileAtomically(r.path, data); err != nil {
+			return fmt.Errorf("couldn't write brand mappings to %v: %w", r.path, err)
+		}
+		version, err = r.swap(data, source)
+		return err
+	})
+	return version, err
+}
+
+// Watch reloads the configuration file whenever its content changes, checking it at the given interval
+func (r *BrandMappingRegistry) Watch(interval time.Duration) {
+	r.stop = make(chan struct{})
+	r.stopped.Add(1)
+	go func() {
+		defer r.stopped.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				r.Reload()
+			}
+		}
+	}()
+}
+
+// StopWatching stops the file watch started by Watch
+func (r *BrandMappingRegistry) StopWatching() {
+	if r.stop == nil {
+		return
+	}
+	close(r.stop)
+	r.stopped.Wait()
+}
+
+// Reload loads the configuration file if it differs from the active brand mappings.
+// An invalid file is reported and the active brand mappings are kept.
+func (r *BrandMappingRegistry) Reload() {
+	r.updating.Lock()
+	defer r.updating.Unlock()
+
+	data, err := ioutil.ReadFile(r.path)
+	if err != nil {
+		log.WithField("path", r.path).WithError(err).Error("Couldn't read brand mapping configuration, keeping the active brand mappings")
+		return
+	}
+	if brandMappingsVersion(data) == r.snapshot().version.Version {
+		return
+	}
+	if _, err := r.swap(data, "file"); err != nil {
+		log.WithField("path", r.path).WithError(err).Error("Invalid brand mapping configuration, keeping the active brand mappings")
+	}
+}
+
+func (r *BrandMappingRegistry) swap(data []byte, source string) (BrandMappingVersion, error) {
+	table, err := ParseBrandMappings(data)
+	if err != nil {
+		return BrandMappingVersion{}, err
+	}
+	version := BrandMappingVersion{Version: brandMappingsVersion(data), LoadedAt: time.Now().UTC(), Source: source}
+	r.current.Store(&brandMappingSnapshot{table: table, version: version})
+	log.WithField("version", version.Version).WithField("source", source).Info("Loaded brand mappings")
+	return version, nil
+}
+
+func (r *BrandMappingRegistry) snapshot() *brandMappingSnapshot {
+	return r.current.Load().(*brandMappingSnapshot)
+}
+
+// brandMappingsVersion hashes the mappings rather than the raw file, so formatting changes don't create a new version
+func brandMappingsVersion(data []byte) string {
+	var brandMappings map[string]string
+	if err := json.Unmarshal(data, &brandMappings); err != nil {
+		brandMappings = nil
+	}
+	canonical, _ := json.Marshal(brandMappings)
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:6])
+}
+
+// lockFile runs f holding an exclusive lock on the file at path, which is created if needed
+func lockFile(path string, f func() error) error {
+	lock, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("couldn't open lock %v: %w", path, err)
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("couldn't lock %v: %w", path, err)
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+	return f()
+}
+
+func writeFileAtomically(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
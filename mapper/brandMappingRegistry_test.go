@@ -0,0 +1,156 @@
+package mapper
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBrandMappingRegistry_Update(t *testing.T) {
+	path := writeBrandMappingsFile(t, `{"blogs.ft.com": "FT-LABS-WP-1-2"}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	registry, err := NewBrandMappingRegistry(path)
+	assert.NoError(t, err)
+	_, initial := registry.Current()
+
+	version, err := registry.Update([]byte(`{"blogs.ft.com": "FT-LABS-WP-1-2", "ftalphaville.ft.com": "FT-LABS-WP-1-24"}`), "admin", initial.Version)
+	assert.NoError(t, err)
+	assert.NotEqual(t, initial.Version, version.Version)
+	assert.Equal(t, "admin", version.Source)
+
+	_, authority, err := registry.Match("http://ftalphaville.ft.com/?p=123")
+	assert.NoError(t, err)
+	assert.Equal(t, "FT-LABS-WP-1-24", authority)
+
+	persisted, _ := ioutil.ReadFile(path)
+	assert.Contains(t, string(persisted), "ftalphaville.ft.com", "updates should survive a restart")
+}
+
+func TestBrandMappingRegistry_InvalidUpdateKeepsActiveMappings(t *testing.T) {
+	path := writeBrandMappingsFile(t, `{"blogs.ft.com": "FT-LABS-WP-1-2"}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	registry, err := NewBrandMappingRegistry(path)
+	assert.NoError(t, err)
+	_, initial := registry.Current()
+
+	_, err = registry.Update([]byte(`{"blogs.ft.com": "FT-LABS-WP-1-2", "blogs.ft.com/": "FT-LABS-WP-1-3"}`), "admin", "")
+	var invalidErr *InvalidBrandMappingsError
+	assert.True(t, errors.As(err, &invalidErr))
+
+	mappings, current := registry.Current()
+	assert.Equal(t, initial, current)
+	assert.Equal(t, map[string]string{"blogs.ft.com": "FT-LABS-WP-1-2"}, mappings)
+	persisted, _ := ioutil.ReadFile(path)
+	assert.Equal(t, `{"blogs.ft.com": "FT-LABS-WP-1-2"}`, string(persisted))
+}
+
+func TestBrandMappingRegistry_UpdateSharedByReplicas(t *testing.T) {
+	path := writeBrandMappingsFile(t, `{"blogs.ft.com": "FT-LABS-WP-1-2"}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	replica1, err := NewBrandMappingRegistry(path)
+	assert.NoError(t, err)
+	replica2, err := NewBrandMappingRegistry(path)
+	assert.NoError(t, err)
+	_, initial := replica2.Current()
+
+	updated, err := replica1.Update([]byte(`{"ftalphaville.ft.com": "FT-LABS-WP-1-24"}`), "admin", initial.Version)
+	assert.NoError(t, err)
+
+	_, err = replica2.Update([]byte(`{"blogs.ft.com": "FT-LABS-WP-1-3"}`), "admin", initial.Version)
+	var mismatchErr *BrandMappingsVersionMismatchError
+	assert.True(t, errors.As(err, &mismatchErr), "an update based on a version changed by another replica should be rejected")
+	assert.Equal(t, updated.Version, mismatchErr.Current)
+	_, current := replica2.Current()
+	assert.Equal(t, updated.Version, current.Version, "the mappings of the other replica should be loaded")
+
+	persisted, _ := ioutil.ReadFile(path)
+	assert.Equal(t, `{"ftalphaville.ft.com": "FT-LABS-WP-1-24"}`, string(persisted))
+}
+
+func TestBrandMappingRegistry_UpdateWriteFailure(t *testing.T) {
+	path := writeBrandMappingsFile(t, `{"blogs.ft.com": "FT-LABS-WP-1-2"}`)
+	registry, err := NewBrandMappingRegistry(path)
+	assert.NoError(t, err)
+	os.RemoveAll(filepath.Dir(path))
+
+	_, err = registry.Update([]byte(`{"ftalphaville.ft.com": "FT-LABS-WP-1-24"}`), "admin", "")
+	assert.Error(t, err)
+	var invalidErr *InvalidBrandMappingsError
+	assert.False(t, errors.As(err, &invalidErr))
+}
+
+func TestCopyBrandMappingsIfMissing(t *testing.T) {
+	defaultPath := writeBrandMappingsFile(t, `{"blogs.ft.com": "FT-LABS-WP-1-2"}`)
+	defer os.RemoveAll(filepath.Dir(defaultPath))
+	path := filepath.Join(filepath.Dir(defaultPath), "shared.json")
+
+	assert.NoError(t, CopyBrandMappingsIfMissing(path, defaultPath))
+	persisted, _ := ioutil.ReadFile(path)
+	assert.Equal(t, `{"blogs.ft.com": "FT-LABS-WP-1-2"}`, string(persisted))
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"ftalphaville.ft.com": "FT-LABS-WP-1-24"}`), 0644))
+	assert.NoError(t, CopyBrandMappingsIfMissing(path, defaultPath))
+	persisted, _ = ioutil.ReadFile(path)
+	assert.Equal(t, `{"ftalphaville.ft.com": "FT-LABS-WP-1-24"}`, string(persisted), "existing mappings should be kept")
+}
+
+func TestBrandMappingRegistry_Reload(t *testing.T) {
+	path := writeBrandMappingsFile(t, `{"blogs.ft.com": "FT-LABS-WP-1-2"}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	registry, err := NewBrandMappingRegistry(path)
+	assert.NoError(t, err)
+	_, initial := registry.Current()
+
+	registry.Reload()
+	_, current := registry.Current()
+	assert.Equal(t, initial, current, "an unchanged file should not be reloaded")
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"blogs.ft.com": "FT-LABS-WP-1-2", "blogs.ft.com/": "FT-LABS-WP-1-3"}`), 0644))
+	registry.Reload()
+	_, current = registry.Current()
+	assert.Equal(t, initial, current, "an invalid file should not be loaded")
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"ftalphaville.ft.com": "FT-LABS-WP-1-24"}`), 0644))
+	registry.Reload()
+	_, current = registry.Current()
+	assert.NotEqual(t, initial.Version, current.Version)
+	assert.Equal(t, "file", current.Source)
+	_, _, err = registry.Match("http://blogs.ft.com/?p=123")
+	assert.Error(t, err)
+}
+
+func TestBrandMappingRegistry_Watch(t *testing.T) {
+	path := writeBrandMappingsFile(t, `{"blogs.ft.com": "FT-LABS-WP-1-2"}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	registry, err := NewBrandMappingRegistry(path)
+	assert.NoError(t, err)
+	registry.Watch(10 * time.Millisecond)
+	defer registry.StopWatching()
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"ftalphaville.ft.com": "FT-LABS-WP-1-24"}`), 0644))
+	for i := 0; i < 100; i++ {
+		if _, _, err = registry.Match("http://ftalphaville.ft.com/?p=123"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.NoError(t, err)
+}
+
+func writeBrandMappingsFile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "brandMappings")
+	assert.NoError(t, err)
+	path := filepath.Join(dir, "brandMappings.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	return path
+}
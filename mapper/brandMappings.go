@@ -0,0 +1,152 @@
+package mapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// BrandMappingTable maps the WordPress blogs to the authority of their identifiers.
+// A serviceId is matched on its host and on the leading segments of its path, and the longest matching key wins.
+type BrandMappingTable struct {
+	mappings []brandMapping
+}
+
+type brandMapping struct {
+	key       string
+	host      string
+	segments  []string
+	authority string
+}
+
+// NewBrandMappingTable validates the mappings of keys such as "blogs.ft.com/the-world" to authorities.
+// Keys that are not a host optionally followed by a path, and keys matching exactly the same serviceIds, are rejected.
+func NewBrandMappingTable(brandMappings map[string]string) (*BrandMappingTable, error) {
+	var mappings []brandMapping
+	normalisedKeys := make(map[string]string)
+	for key, authority := range brandMappings {
+		if strings.TrimSpace(authority) == "" {
+			return nil, fmt.Errorf("empty authority for brand mapping key=%v", key)
+		}
+		host, segments, err := parseMappingKey(key)
+		if err != nil {
+			return nil, err
+		}
+		normalisedKey := host + "/" + strings.Join(segments, "/")
+		if other, found := normalisedKeys[normalisedKey]; found {
+			return nil, fmt.Errorf("ambiguous brand mapping keys %v and %v match the same serviceIds", other, key)
+		}
+		normalisedKeys[normalisedKey] = key
+		mappings = append(mappings, brandMapping{key: key, host: host, segments: segments, authority: authority})
+	}
+
+	sort.Slice(mappings, func(i, j int) bool {
+		if len(mappings[i].segments) != len(mappings[j].segments) {
+			return len(mappings[i].segments) > len(mappings[j].segments)
+		}
+		return mappings[i].key < mappings[j].key
+	})
+	return &BrandMappingTable{mappings: mappings}, nil
+}
+
+// ParseBrandMappings builds the table from its JSON representation, rejecting duplicated keys
+func ParseBrandMappings(data []byte) (*BrandMappingTable, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("brand mappings should be a JSON object of keys to authorities")
+	}
+	brandMappings := make(map[string]string)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("invalid brand mappings: %v", err)
+		}
+		key := token.(string)
+		var authority string
+		if err := decoder.Decode(&authority); err != nil {
+			return nil, fmt.Errorf("invalid authority for brand mapping key=%v: %v", key, err)
+		}
+		if _, found := brandMappings[key]; found {
+			return nil, fmt.Errorf("duplicated brand mapping key=%v", key)
+		}
+		brandMappings[key] = authority
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, fmt.Errorf("invalid brand mappings: %v", err)
+	}
+	return NewBrandMappingTable(brandMappings)
+}
+
+// Match returns the key and the authority of the most specific mapping for the serviceId
+func (t *BrandMappingTable) Match(serviceID string) (key string, authority string, err error) {
+	host, segments, err := parseServiceID(serviceID)
+	if err != nil {
+		return "", "", err
+	}
+	for _, m := range t.mappings {
+		if m.host == host && hasPrefix(segments, m.segments) {
+			return m.key, m.authority, nil
+		}
+	}
+	return "", "", fmt.Errorf("couldn't find authority in mapping table serviceId=%v", serviceID)
+}
+
+// Mappings returns a copy of the keys and authorities of the table
+func (t *BrandMappingTable) Mappings() map[string]string {
+	brandMappings := make(map[string]string, len(t.mappings))
+	for _, m := range t.mappings {
+		brandMappings[m.key] = m.authority
+	}
+	return brandMappings
+}
+
+func parseMappingKey(key string) (string, []string, error) {
+	u, err := url.Parse("http://" + strings.TrimSpace(key))
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid brand mapping key=%v: %v", key, err)
+	}
+	if u.Hostname() == "" || u.RawQuery != "" || u.Fragment != "" || strings.Contains(key, "://") {
+		return "", nil, fmt.Errorf("invalid brand mapping key=%v: it should be a host optionally followed by a path", key)
+	}
+	return strings.ToLower(u.Hostname()), pathSegments(u.Path), nil
+}
+
+func parseServiceID(serviceID string) (string, []string, error) {
+	rawURL := strings.TrimSpace(serviceID)
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid serviceId=%v: %v", serviceID, err)
+	}
+	if u.Hostname() == "" {
+		return "", nil, fmt.Errorf("invalid serviceId=%v: no host", serviceID)
+	}
+	return strings.ToLower(u.Hostname()), pathSegments(u.Path), nil
+}
+
+func pathSegments(path string) []string {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+func hasPrefix(segments, prefix []string) bool {
+	if len(prefix) > len(segments) {
+		return false
+	}
+	for i := range prefix {
+		if segments[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
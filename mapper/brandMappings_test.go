@@ -0,0 +1,75 @@
+package mapper
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBrandMappingTable_LongestPrefixWins(t *testing.T) {
+	table, err := NewBrandMappingTable(map[string]string{
+		"blogs.ft.com":               "FT-LABS-WP-1-2",
+		"blogs.ft.com/the-world":     "FT-LABS-WP-1-10",
+		"blogs.ft.com/the-world/pol": "FT-LABS-WP-1-11",
+	})
+	assert.NoError(t, err)
+
+	key, authority, err := table.Match("http://blogs.ft.com/the-world/2017/10/post/?p=123")
+	assert.NoError(t, err)
+	assert.Equal(t, "blogs.ft.com/the-world", key)
+	assert.Equal(t, "FT-LABS-WP-1-10", authority)
+
+	key, _, err = table.Match("http://blogs.ft.com/the-world/pol/?p=123")
+	assert.NoError(t, err)
+	assert.Equal(t, "blogs.ft.com/the-world/pol", key)
+
+	key, _, err = table.Match("http://blogs.ft.com/the-worldcup/?p=123")
+	assert.NoError(t, err)
+	assert.Equal(t, "blogs.ft.com", key, "path segments should match whole, not as substrings")
+}
+
+func TestBrandMappingTable_MatchesHostExactly(t *testing.T) {
+	table, err := NewBrandMappingTable(map[string]string{"ft.com": "FT-LABS-WP-1-1"})
+	assert.NoError(t, err)
+
+	_, _, err = table.Match("http://ftalphaville.ft.com/?p=123")
+	assert.Error(t, err)
+
+	key, _, err := table.Match("HTTP://FT.COM/?p=123")
+	assert.NoError(t, err)
+	assert.Equal(t, "ft.com", key)
+}
+
+func TestBrandMappingTable_RejectsAmbiguousKeys(t *testing.T) {
+	_, err := NewBrandMappingTable(map[string]string{
+		"blogs.ft.com/the-world":  "FT-LABS-WP-1-10",
+		"Blogs.ft.com/the-world/": "FT-LABS-WP-1-11",
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "ambiguous")
+}
+
+func TestBrandMappingTable_RejectsInvalidKeys(t *testing.T) {
+	_, err := NewBrandMappingTable(map[string]string{"http://blogs.ft.com": "FT-LABS-WP-1-10"})
+	assert.Error(t, err)
+
+	_, err = NewBrandMappingTable(map[string]string{"blogs.ft.com": ""})
+	assert.Error(t, err)
+}
+
+func TestParseBrandMappings_RejectsDuplicatedKeys(t *testing.T) {
+	_, err := ParseBrandMappings([]byte(`{"blogs.ft.com": "FT-LABS-WP-1-2", "blogs.ft.com": "FT-LABS-WP-1-3"}`))
+	assert.Error(t, err)
+
+	table, err := ParseBrandMappings([]byte(`{"blogs.ft.com": "FT-LABS-WP-1-2"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"blogs.ft.com": "FT-LABS-WP-1-2"}, table.Mappings())
+}
+
+func TestParseBrandMappings_ProjectConfiguration(t *testing.T) {
+	data, err := ioutil.ReadFile("../brandMappings.json")
+	assert.NoError(t, err)
+	_, err = ParseBrandMappings(data)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,110 @@
+package mapper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	log "github.com/Sirupsen/logrus"
+)
+
+// ResolutionStrategy tells how the UPP UUID of a placeholder is found
+type ResolutionStrategy string
+
+const (
+	// StrategyBlog resolves the UUID of the blog post identified by the serviceid and ref_field of the placeholder
+	StrategyBlog ResolutionStrategy = "blog"
+	// StrategyGeneric uses the OriginalUUID of the placeholder, which should exist in document-store-api
+	StrategyGeneric ResolutionStrategy = "generic"
+	// StrategyExternal maps the placeholder as a link to an external page
+	StrategyExternal ResolutionStrategy = "external"
+	// StrategyIgnore doesn't map the placeholder
+	StrategyIgnore ResolutionStrategy = "ignore"
+)
+
+// UnknownCategoryError is returned for a category which is not in the routing table, when no fallback strategy is configured
+type UnknownCategoryError struct {
+	Category string
+}
+
+func (e *UnknownCategoryError) Error() string {
+	return fmt.Sprintf("no resolution strategy configured for placeholder category=%q", e.Category)
+}
+
+// CategoryRoutingTable maps the Attributes.Category of a placeholder to its resolution strategy
+type CategoryRoutingTable struct {
+	categories      map[string]ResolutionStrategy
+	unknownCategory ResolutionStrategy
+}
+
+type categoryRoutingConfig struct {
+	Categories      map[string]ResolutionStrategy `json:"categories"`
+	UnknownCategory ResolutionStrategy            `json:"unknownCategory"`
+}
+
+// NewCategoryRoutingTable validates the routes. An empty unknownCategory strategy makes unknown categories fail mapping.
+func NewCategoryRoutingTable(categories map[string]ResolutionStrategy, unknownCategory ResolutionStrategy) (*CategoryRoutingTable, error) {
+	routes := make(map[string]ResolutionStrategy, len(categories))
+	for category, strategy := range categories {
+		if !isValidStrategy(strategy) {
+			return nil, fmt.Errorf("invalid resolution strategy %q for category=%q", strategy, category)
+		}
+		routes[category] = strategy
+	}
+	if unknownCategory != "" && !isValidStrategy(unknownCategory) {
+		return nil, fmt.Errorf("invalid resolution strategy %q for unknown categories", unknownCategory)
+	}
+	return &CategoryRoutingTable{categories: routes, unknownCategory: unknownCategory}, nil
+}
+
+// DefaultCategoryRouting routes the live blog categories to blog resolution, placeholders without a category to external placeholders
+// and fails the mapping of the other categories
+func DefaultCategoryRouting() *CategoryRoutingTable {
+	table, _ := NewCategoryRoutingTable(map[string]ResolutionStrategy{
+		"":                     StrategyExternal,
+		"blog":                 StrategyBlog,
+		"webchat-live-blogs":   StrategyBlog,
+		"webchat-live-qa":      StrategyBlog,
+		"webchat-markets-live": StrategyBlog,
+		"fastft":               StrategyBlog,
+	}, "")
+	return table
+}
+
+// ReadCategoryRouting loads the routing table from a JSON file
+func ReadCategoryRouting(path string) (*CategoryRoutingTable, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config categoryRoutingConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid category routing configuration: %v", err)
+	}
+	return NewCategoryRoutingTable(config.Categories, config.UnknownCategory)
+}
+
+// Route returns the resolution strategy of the category
+func (t *CategoryRoutingTable) Route(category, tid string) (ResolutionStrategy, error) {
+	if strategy, found := t.categories[category]; found {
+		return strategy, nil
+	}
+	if t.unknownCategory == "" {
+		return "", &UnknownCategoryError{Category: category}
+	}
+	log.WithField("transaction_id", tid).WithField("category", category).WithField("strategy", t.unknownCategory).Warn("Unknown placeholder category, using the fallback resolution strategy")
+	return t.unknownCategory, nil
+}
+
+func isValidStrategy(strategy ResolutionStrategy) bool {
+	switch strategy {
+	case StrategyBlog, StrategyGeneric, StrategyExternal, StrategyIgnore:
+		return true
+	}
+	return false
+}
+
+func ignoredCategoryError(category string) error {
+	return model.NewInvalidMethodeCPH(fmt.Sprintf("Ignoring content placeholder of category=%q", category))
+}
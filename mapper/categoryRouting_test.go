@@ -0,0 +1,55 @@
+package mapper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategoryRouting_Route(t *testing.T) {
+	routing, err := NewCategoryRoutingTable(map[string]ResolutionStrategy{"blog": StrategyBlog, "podcast": StrategyIgnore}, StrategyExternal)
+	assert.NoError(t, err)
+
+	strategy, err := routing.Route("blog", "tid_test123")
+	assert.NoError(t, err)
+	assert.Equal(t, StrategyBlog, strategy)
+
+	strategy, err = routing.Route("podcast", "tid_test123")
+	assert.NoError(t, err)
+	assert.Equal(t, StrategyIgnore, strategy)
+
+	strategy, err = routing.Route("anything-else", "tid_test123")
+	assert.NoError(t, err)
+	assert.Equal(t, StrategyExternal, strategy)
+}
+
+func TestCategoryRouting_UnknownCategoryWithoutFallback(t *testing.T) {
+	routing, err := NewCategoryRoutingTable(map[string]ResolutionStrategy{"blog": StrategyBlog}, "")
+	assert.NoError(t, err)
+
+	_, err = routing.Route("anything-else", "tid_test123")
+	assert.Equal(t, &UnknownCategoryError{Category: "anything-else"}, err)
+}
+
+func TestCategoryRouting_InvalidStrategy(t *testing.T) {
+	_, err := NewCategoryRoutingTable(map[string]ResolutionStrategy{"blog": "blogs"}, "")
+	assert.Error(t, err)
+
+	_, err = NewCategoryRoutingTable(map[string]ResolutionStrategy{}, "reject")
+	assert.Error(t, err)
+}
+
+func TestCategoryRouting_ProjectConfiguration(t *testing.T) {
+	routing, err := ReadCategoryRouting("../categoryRouting.json")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultCategoryRouting(), routing)
+}
+
+func TestCategoryRouting_DefaultFailsUnknownCategories(t *testing.T) {
+	strategy, err := DefaultCategoryRouting().Route("", "tid_test123")
+	assert.NoError(t, err)
+	assert.Equal(t, StrategyExternal, strategy)
+
+	_, err = DefaultCategoryRouting().Route("new-live-blog", "tid_test123")
+	assert.Equal(t, &UnknownCategoryError{Category: "new-live-blog"}, err)
+}
@@ -1,6 +1,7 @@
 package mapper
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -21,7 +22,7 @@ func NewComplementaryContentCPHMapper(apiHost string, client DocStoreClient) *Co
 	}
 }
 
-func (ccm *ComplementaryContentCPHMapper) MapContentPlaceholder(mcp *model.MethodeContentPlaceholder, uuid, tid, lmd string) ([]model.UppContent, error) {
+func (ccm *ComplementaryContentCPHMapper) MapContentPlaceholder(ctx context.Context, mcp *model.MethodeContentPlaceholder, uuid, tid, lmd string) ([]model.UppContent, error) {
 	var cc *model.UppComplementaryContent
 
 	isInternalCPH := false
@@ -40,8 +41,8 @@ func (ccm *ComplementaryContentCPHMapper) MapContentPlaceholder(mcp *model.Metho
 
 	if isInternalCPH {
 		cc.UUID = uuid
-		if err := ccm.setBrands(uuid, tid, cc); err != nil {
-			return nil, fmt.Errorf("failed to retrieve brands for complementary content: %v", err.Error())
+		if err := ccm.setBrands(ctx, uuid, tid, cc); err != nil {
+			return nil, fmt.Errorf("failed to retrieve brands for complementary content: %w", err)
 		}
 	}
 
@@ -108,10 +109,10 @@ func (ccm *ComplementaryContentCPHMapper) buildCCAlternativeStandfirsts(promoSta
 	return &model.AlternativeStandfirsts{PromotionalStandfirst: promoStandfirst}
 }
 
-func (ccm *ComplementaryContentCPHMapper) setBrands(uuid, tid string, cc *model.UppComplementaryContent) error {
-	content, err := ccm.client.GetContent(uuid, tid)
+func (ccm *ComplementaryContentCPHMapper) setBrands(ctx context.Context, uuid, tid string, cc *model.UppComplementaryContent) error {
+	content, err := ccm.client.GetContent(ctx, uuid, tid)
 	if err != nil {
-		return fmt.Errorf("failed to get content from document-store-api: %v", err.Error())
+		return fmt.Errorf("failed to get content from document-store-api: %w", err)
 	}
 	cc.Brands = content.Brands
 	return nil
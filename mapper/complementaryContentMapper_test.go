@@ -1,6 +1,7 @@
 package mapper
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
@@ -14,7 +15,7 @@ func TestExternalPlaceholderComplementary_Ok(t *testing.T) {
 	mockClient := new(model.MockDocStoreClient)
 	ccMapper := NewComplementaryContentCPHMapper("api.ft.com", mockClient)
 
-	uppContents, err := ccMapper.MapContentPlaceholder(getPlaceholder(), "", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
+	uppContents, err := ccMapper.MapContentPlaceholder(context.Background(), getPlaceholder(), "", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
 
 	assert.NoError(t, err, "Error wasn't expected during MapContentPlaceholder")
 	assert.Equal(t, 1, len(uppContents), "Should be one")
@@ -32,7 +33,7 @@ func TestExternalPlaceholderComplementaryDelete_Ok(t *testing.T) {
 	mockClient := new(model.MockDocStoreClient)
 	ccMapper := NewComplementaryContentCPHMapper("api.ft.com", mockClient)
 
-	uppContents, err := ccMapper.MapContentPlaceholder(getDeletedPlaceholder(), "", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
+	uppContents, err := ccMapper.MapContentPlaceholder(context.Background(), getDeletedPlaceholder(), "", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
 
 	assert.NoError(t, err, "Error wasn't expected during MapContentPlaceholder")
 	assert.Equal(t, 1, len(uppContents))
@@ -48,7 +49,7 @@ func TestInternalPlaceholderComplementary_Ok(t *testing.T) {
 	mockClient.On("GetContent", "abcf2660-bbad-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il").Return(getDocStoreContent(t, "document_store_content.json"), nil)
 	ccMapper := NewComplementaryContentCPHMapper("api.ft.com", mockClient)
 
-	uppContents, err := ccMapper.MapContentPlaceholder(getPlaceholder(), "abcf2660-bbad-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
+	uppContents, err := ccMapper.MapContentPlaceholder(context.Background(), getPlaceholder(), "abcf2660-bbad-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
 
 	assert.NoError(t, err, "Error wasn't expected during MapContentPlaceholder")
 	assert.Equal(t, 1, len(uppContents), "Should be one")
@@ -67,7 +68,7 @@ func TestInternalPlaceholderComplementaryDelete_Ok(t *testing.T) {
 	mockClient.On("GetContent", "abcf2660-bbad-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il").Return(getDocStoreContent(t, "document_store_content.json"), nil)
 	ccMapper := NewComplementaryContentCPHMapper("api.ft.com", mockClient)
 
-	uppContents, err := ccMapper.MapContentPlaceholder(getDeletedPlaceholder(), "abcf2660-bbad-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
+	uppContents, err := ccMapper.MapContentPlaceholder(context.Background(), getDeletedPlaceholder(), "abcf2660-bbad-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
 
 	assert.NoError(t, err, "Error wasn't expected during MapContentPlaceholder")
 	assert.Equal(t, 1, len(uppContents))
@@ -84,8 +85,19 @@ func TestInternalPlaceholderComplementary_DocumentStoreClientError(t *testing.T)
 	mockClient.On("GetContent", "abcf2660-bbad-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il").Return(&model.DocStoreUppContent{}, errors.New("DocStore error"))
 	ccMapper := NewComplementaryContentCPHMapper("api.ft.com", mockClient)
 
-	_, err := ccMapper.MapContentPlaceholder(getPlaceholder(), "abcf2660-bbad-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
+	_, err := ccMapper.MapContentPlaceholder(context.Background(), getPlaceholder(), "abcf2660-bbad-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
 	assert.Error(t, err)
+	assert.False(t, model.IsTransient(err))
+}
+
+func TestInternalPlaceholderComplementary_DocumentStoreClientTransientError(t *testing.T) {
+	mockClient := new(model.MockDocStoreClient)
+	mockClient.On("GetContent", "abcf2660-bbad-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il").Return(&model.DocStoreUppContent{}, model.NewTransientError("received status code=503"))
+	ccMapper := NewComplementaryContentCPHMapper("api.ft.com", mockClient)
+
+	_, err := ccMapper.MapContentPlaceholder(context.Background(), getPlaceholder(), "abcf2660-bbad-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
+	assert.Error(t, err)
+	assert.True(t, model.IsTransient(err), "The transient error should be preserved when wrapped")
 }
 
 func getPlaceholder() *model.MethodeContentPlaceholder {
@@ -1,6 +1,7 @@
 package mapper
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -21,7 +22,7 @@ const (
 type ContentCPHMapper struct {
 }
 
-func (cm *ContentCPHMapper) MapContentPlaceholder(mcp *model.MethodeContentPlaceholder, uuid, tid, lmd string) ([]model.UppContent, error) {
+func (cm *ContentCPHMapper) MapContentPlaceholder(ctx context.Context, mcp *model.MethodeContentPlaceholder, uuid, tid, lmd string) ([]model.UppContent, error) {
 	if uuid != "" {
 		return []model.UppContent{}, nil
 	}
@@ -1,6 +1,7 @@
 package mapper
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -26,7 +27,7 @@ func TestExternalPlaceholder_Ok(t *testing.T) {
 	}
 	contentMapper := ContentCPHMapper{}
 
-	uppContents, err := contentMapper.MapContentPlaceholder(placeholder, "", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
+	uppContents, err := contentMapper.MapContentPlaceholder(context.Background(), placeholder, "", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
 
 	assert.Nil(t, err, "Error wasn't expected during MapContentPlaceholder")
 	assert.Equal(t, 1, len(uppContents), "Should be one")
@@ -65,7 +66,7 @@ func TestExternalPlaceholder_MissingLeadHeadlineUrl(t *testing.T) {
 	}
 	contentMapper := ContentCPHMapper{}
 
-	uppContents, err := contentMapper.MapContentPlaceholder(placeholder, "", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
+	uppContents, err := contentMapper.MapContentPlaceholder(context.Background(), placeholder, "", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
 
 	assert.Nil(t, err, "Error wasn't expected during MapContentPlaceholder")
 	assert.Equal(t, 1, len(uppContents), "Should be one")
@@ -98,7 +99,7 @@ func TestExternalPlaceholderDeleted_Ok(t *testing.T) {
 	}
 	contentMapper := ContentCPHMapper{}
 
-	uppContents, err := contentMapper.MapContentPlaceholder(placeholder, "", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
+	uppContents, err := contentMapper.MapContentPlaceholder(context.Background(), placeholder, "", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
 
 	assert.Nil(t, err, "Error wasn't expected during MapContentPlaceholder")
 	assert.Equal(t, 1, len(uppContents))
@@ -121,7 +122,7 @@ func TestInternalPlaceholder_ReturnsEmpty(t *testing.T) {
 	}
 	contentMapper := &ContentCPHMapper{}
 
-	uppContents, err := contentMapper.MapContentPlaceholder(placeholder, "abcf2660-bbad-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
+	uppContents, err := contentMapper.MapContentPlaceholder(context.Background(), placeholder, "abcf2660-bbad-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
 
 	assert.Nil(t, err, "Error wasn't expected during MapContentPlaceholder")
 	assert.Equal(t, 0, len(uppContents), "Should be zero")
@@ -138,7 +139,7 @@ func TestInternalPlaceholderDeleted_Ok(t *testing.T) {
 	}
 	contentMapper := ContentCPHMapper{}
 
-	uppContents, err := contentMapper.MapContentPlaceholder(placeholder, "abcf2660-bbad-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
+	uppContents, err := contentMapper.MapContentPlaceholder(context.Background(), placeholder, "abcf2660-bbad-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
 
 	assert.Nil(t, err, "Error wasn't expected during MapContentPlaceholder")
 	assert.Equal(t, 0, len(uppContents))
@@ -155,7 +156,7 @@ func TestExternalPlaceholderWrongPublishDate_ThrowsError(t *testing.T) {
 	}
 	contentMapper := &ContentCPHMapper{}
 
-	_, err := contentMapper.MapContentPlaceholder(placeholder, "", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
+	_, err := contentMapper.MapContentPlaceholder(context.Background(), placeholder, "", "tid_bh7VTFj9Il", "2017-09-27T15:00:00.000Z")
 
 	assert.NotNil(t, err, "Error was expected during MapContentPlaceholder")
 }
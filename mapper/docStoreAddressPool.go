@@ -0,0 +1,105 @@
+package mapper
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// DocStoreAddressStatus is the health of one document-store-api address, as found by its last /__gtg check
+type DocStoreAddressStatus struct {
+	Address     string    `json:"address"`
+	Healthy     bool      `json:"healthy"`
+	LastChecked time.Time `json:"lastChecked,omitempty"`
+	LastError   string    `json:"lastError,omitempty"`
+}
+
+// docStoreAddressPool spreads the calls over the healthy document-store-api addresses in turn.
+// Every address is healthy until its /__gtg check fails, and healthy again once it passes.
+type docStoreAddressPool struct {
+	mu       sync.RWMutex
+	statuses []DocStoreAddressStatus
+	next     uint32
+}
+
+func newDocStoreAddressPool(addresses []string) *docStoreAddressPool {
+	p := &docStoreAddressPool{}
+	for _, address := range addresses {
+		if address == "" {
+			continue
+		}
+		p.statuses = append(p.statuses, DocStoreAddressStatus{Address: address, Healthy: true})
+	}
+	return p
+}
+
+// candidates returns the addresses a call should be tried on, in order.
+// The healthy addresses are rotated so that consecutive calls start on a different one.
+// If every address is ejected they are all tried, a stale check shouldn't stop every call.
+func (p *docStoreAddressPool) candidates() []string {
+	p.mu.RLock()
+	var healthy, all []string
+	for _, status := range p.statuses {
+		all = append(all, status.Address)
+		if status.Healthy {
+			healthy = append(healthy, status.Address)
+		}
+	}
+	p.mu.RUnlock()
+
+	if len(healthy) == 0 {
+		healthy = all
+	}
+	if len(healthy) < 2 {
+		return healthy
+	}
+	start := int(atomic.AddUint32(&p.next, 1)-1) % len(healthy)
+	rotated := make([]string, 0, len(healthy))
+	rotated = append(rotated, healthy[start:]...)
+	return append(rotated, healthy[:start]...)
+}
+
+func (p *docStoreAddressPool) addresses() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	addresses := make([]string, len(p.statuses))
+	for i, status := range p.statuses {
+		addresses[i] = status.Address
+	}
+	return addresses
+}
+
+// record updates the health of the address with the outcome of its /__gtg check, ejecting it if the check failed
+func (p *docStoreAddressPool) record(address string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range p.statuses {
+		status := &p.statuses[i]
+		if status.Address != address {
+			continue
+		}
+		wasHealthy := status.Healthy
+		status.Healthy = err == nil
+		status.LastChecked = time.Now().UTC()
+		status.LastError = ""
+		if err != nil {
+			status.LastError = err.Error()
+		}
+		if wasHealthy && !status.Healthy {
+			log.WithField("address", address).WithError(err).Warn("document-store-api address failed its good-to-go check, ejecting it")
+		} else if !wasHealthy && status.Healthy {
+			log.WithField("address", address).Info("document-store-api address passed its good-to-go check, restoring it")
+		}
+		return
+	}
+}
+
+func (p *docStoreAddressPool) snapshot() []DocStoreAddressStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	statuses := make([]DocStoreAddressStatus, len(p.statuses))
+	copy(statuses, p.statuses)
+	return statuses
+}
@@ -0,0 +1,242 @@
+package mapper
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	log "github.com/Sirupsen/logrus"
+)
+
+// States of a CircuitBreakerDocStoreClient
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// ErrDocStoreCircuitOpen is returned instead of calling document-store-api while the circuit breaker is open.
+// It is a transient error, the call may succeed once document-store-api recovers.
+var ErrDocStoreCircuitOpen = model.NewTransientError("document-store-api circuit breaker is open")
+
+// DocStoreBreakerConfig sets when the circuit breaker opens and closes again
+type DocStoreBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed calls opening the breaker
+	FailureThreshold int
+	// SuccessThreshold is the number of consecutive successful trial calls closing a half-open breaker
+	SuccessThreshold int
+	// OpenTimeout is how long the breaker stays open before letting a trial call through
+	OpenTimeout time.Duration
+}
+
+// CircuitBreakerDocStoreClient is a DocStoreClient decorator failing fast while document-store-api is unavailable.
+// A network error or a 5xx or 429 response is a failure, any other response a success.
+// Once open, the breaker rejects every call for OpenTimeout, then turns half-open and lets one trial call through at a time.
+type CircuitBreakerDocStoreClient struct {
+	client DocStoreClient
+	config DocStoreBreakerConfig
+
+	mu        sync.Mutex
+	state     string
+	failures  int
+	successes int
+	trial     bool
+	openedAt  time.Time
+	// changed is closed and replaced on every state change and at the end of every trial call, waking up the waiters
+	changed chan struct{}
+	now     func() time.Time
+}
+
+// NewCircuitBreakerDocStoreClient returns a closed circuit breaker around the given client
+func NewCircuitBreakerDocStoreClient(client DocStoreClient, config DocStoreBreakerConfig) *CircuitBreakerDocStoreClient {
+	if config.FailureThreshold < 1 {
+		config.FailureThreshold = 1
+	}
+	if config.SuccessThreshold < 1 {
+		config.SuccessThreshold = 1
+	}
+	return &CircuitBreakerDocStoreClient{
+		client:  client,
+		config:  config,
+		state:   BreakerClosed,
+		changed: make(chan struct{}),
+		now:     time.Now,
+	}
+}
+
+func (b *CircuitBreakerDocStoreClient) ContentQuery(ctx context.Context, authority, identifier, tid string) (int, string, error) {
+	if err := b.before(); err != nil {
+		return -1, "", err
+	}
+	status, location, err := b.client.ContentQuery(ctx, authority, identifier, tid)
+	b.after(ctx, model.IsTransient(err) || (err == nil && isTransientStatus(status)))
+	return status, location, err
+}
+
+func (b *CircuitBreakerDocStoreClient) GetContent(ctx context.Context, uuid, tid string) (*model.DocStoreUppContent, error) {
+	if err := b.before(); err != nil {
+		return nil, err
+	}
+	content, err := b.client.GetContent(ctx, uuid, tid)
+	b.after(ctx, model.IsTransient(err))
+	return content, err
+}
+
+// RevalidateContent revalidates the content with the wrapped client if it can, or fetches it again otherwise
+func (b *CircuitBreakerDocStoreClient) RevalidateContent(ctx context.Context, uuid, etag, tid string) (*model.DocStoreUppContent, bool, error) {
+	revalidator, ok := b.client.(ContentRevalidator)
+	if !ok {
+		content, err := b.GetContent(ctx, uuid, tid)
+		return content, err == nil, err
+	}
+	if err := b.before(); err != nil {
+		return nil, false, err
+	}
+	content, modified, err := revalidator.RevalidateContent(ctx, uuid, etag, tid)
+	b.after(ctx, model.IsTransient(err))
+	return content, modified, err
+}
+
+func (b *CircuitBreakerDocStoreClient) GetDocument(ctx context.Context, collection, uuid, tid string) (map[string]interface{}, bool, error) {
+	if err := b.before(); err != nil {
+		return nil, false, err
+	}
+	document, found, err := b.client.GetDocument(ctx, collection, uuid, tid)
+	b.after(ctx, model.IsTransient(err))
+	return document, found, err
+}
+
+func (b *CircuitBreakerDocStoreClient) ContentExists(ctx context.Context, uuid, tid string) (bool, error) {
+	if err := b.before(); err != nil {
+		return false, err
+	}
+	exists, err := b.client.ContentExists(ctx, uuid, tid)
+	b.after(ctx, model.IsTransient(err))
+	return exists, err
+}
+
+// ConnectivityCheck fails while the breaker is open, without calling document-store-api
+func (b *CircuitBreakerDocStoreClient) ConnectivityCheck() (string, error) {
+	b.mu.Lock()
+	state, openedAt := b.currentState(), b.openedAt
+	b.mu.Unlock()
+	if state == BreakerOpen {
+		return "Error connecting to document-store-api", fmt.Errorf("circuit breaker open since %v", openedAt.Format(time.RFC3339))
+	}
+	return b.client.ConnectivityCheck()
+}
+
+// State returns the current state of the breaker: closed, open or half-open
+func (b *CircuitBreakerDocStoreClient) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.currentState()
+}
+
+// WaitUntilAvailable blocks while the breaker is open or a trial call is in progress,
+// or until the context is done
+func (b *CircuitBreakerDocStoreClient) WaitUntilAvailable(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		state := b.currentState()
+		if state == BreakerClosed || (state == BreakerHalfOpen && !b.trial) {
+			b.mu.Unlock()
+			return nil
+		}
+		changed := b.changed
+		var timeout <-chan time.Time
+		if state == BreakerOpen {
+			timeout = time.After(b.openedAt.Add(b.config.OpenTimeout).Sub(b.now()))
+		}
+		b.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-timeout:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// before rejects the call while the breaker is open or another trial call is in progress
+func (b *CircuitBreakerDocStoreClient) before() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.currentState() {
+	case BreakerOpen:
+		return ErrDocStoreCircuitOpen
+	case BreakerHalfOpen:
+		if b.trial {
+			return ErrDocStoreCircuitOpen
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// after records the outcome of a call let through by before.
+// A call whose context is done says nothing of document-store-api, it only ends the trial it may have been.
+func (b *CircuitBreakerDocStoreClient) after(ctx context.Context, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.currentState() == BreakerHalfOpen {
+		b.trial = false
+		if ctx.Err() != nil {
+			b.notify()
+			return
+		}
+		if failed {
+			b.open()
+			return
+		}
+		b.successes++
+		if b.successes >= b.config.SuccessThreshold {
+			b.transition(BreakerClosed)
+			log.Info("document-store-api circuit breaker closed")
+			return
+		}
+		b.notify()
+		return
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == BreakerClosed && b.failures >= b.config.FailureThreshold {
+		b.open()
+	}
+}
+
+// currentState turns an open breaker half-open once OpenTimeout elapsed
+func (b *CircuitBreakerDocStoreClient) currentState() string {
+	if b.state == BreakerOpen && !b.now().Before(b.openedAt.Add(b.config.OpenTimeout)) {
+		b.transition(BreakerHalfOpen)
+	}
+	return b.state
+}
+
+func (b *CircuitBreakerDocStoreClient) open() {
+	b.openedAt = b.now()
+	b.transition(BreakerOpen)
+	log.WithField("open_timeout", b.config.OpenTimeout).Warn("document-store-api circuit breaker opened")
+}
+
+func (b *CircuitBreakerDocStoreClient) transition(state string) {
+	b.state = state
+	b.failures = 0
+	b.successes = 0
+	b.trial = false
+	b.notify()
+}
+
+func (b *CircuitBreakerDocStoreClient) notify() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
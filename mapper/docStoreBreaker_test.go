@@ -0,0 +1,172 @@
+package mapper
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestBreaker(client DocStoreClient, now *time.Time) *CircuitBreakerDocStoreClient {
+	breaker := NewCircuitBreakerDocStoreClient(client, DocStoreBreakerConfig{
+		FailureThreshold: 3,
+		SuccessThreshold: 2,
+		OpenTimeout:      30 * time.Second,
+	})
+	breaker.now = func() time.Time { return *now }
+	return breaker
+}
+
+func TestCircuitBreakerDocStoreClient_OpensAfterConsecutiveFailures(t *testing.T) {
+	client := new(model.MockDocStoreClient)
+	client.On("GetContent", "512c1f3d-e48c-4618-863c-94bc9d913b9b", mock.Anything).Return((*model.DocStoreUppContent)(nil), model.NewTransientError("received status code=503"))
+	now := time.Date(2017, 5, 15, 15, 54, 32, 0, time.UTC)
+	breaker := newTestBreaker(client, &now)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, BreakerClosed, breaker.State())
+		_, err := breaker.GetContent(context.Background(), "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+		assert.EqualError(t, err, "received status code=503")
+	}
+	assert.Equal(t, BreakerOpen, breaker.State())
+
+	_, err := breaker.GetContent(context.Background(), "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+
+	assert.Equal(t, ErrDocStoreCircuitOpen, err)
+	assert.True(t, model.IsTransient(err))
+	client.AssertNumberOfCalls(t, "GetContent", 3)
+}
+
+func TestCircuitBreakerDocStoreClient_SuccessResetsFailures(t *testing.T) {
+	client := new(model.MockDocStoreClient)
+	client.On("ContentQuery", "http://api.ft.com/system/FT-LABS-WP-1-24", mock.Anything, mock.Anything).Return(http.StatusServiceUnavailable, "", nil)
+	client.On("ContentQuery", "http://api.ft.com/system/FT-LABS-WP-1-335", mock.Anything, mock.Anything).Return(http.StatusNotFound, "", nil)
+	client.On("ContentExists", "512c1f3d-e48c-4618-863c-94bc9d913b9b", mock.Anything).Return(false, model.NewDependencyError("received status code=400"))
+	now := time.Date(2017, 5, 15, 15, 54, 32, 0, time.UTC)
+	breaker := newTestBreaker(client, &now)
+
+	breaker.ContentQuery(context.Background(), "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", "tid_test123")
+	breaker.ContentQuery(context.Background(), "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", "tid_test123")
+	breaker.ContentQuery(context.Background(), "http://api.ft.com/system/FT-LABS-WP-1-335", "http://ftalphaville.ft.com/?p=2193913", "tid_test123")
+	breaker.ContentQuery(context.Background(), "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", "tid_test123")
+	breaker.ContentExists(context.Background(), "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+	breaker.ContentQuery(context.Background(), "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", "tid_test123")
+
+	assert.Equal(t, BreakerClosed, breaker.State())
+}
+
+func TestCircuitBreakerDocStoreClient_ClosesAfterSuccessfulTrials(t *testing.T) {
+	client := new(model.MockDocStoreClient)
+	client.On("ContentExists", "512c1f3d-e48c-4618-863c-94bc9d913b9b", mock.Anything).Return(false, model.NewTransientError("connection refused")).Times(3)
+	client.On("ContentExists", "512c1f3d-e48c-4618-863c-94bc9d913b9b", mock.Anything).Return(true, nil)
+	now := time.Date(2017, 5, 15, 15, 54, 32, 0, time.UTC)
+	breaker := newTestBreaker(client, &now)
+	for i := 0; i < 3; i++ {
+		breaker.ContentExists(context.Background(), "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+	}
+
+	now = now.Add(29 * time.Second)
+	assert.Equal(t, BreakerOpen, breaker.State())
+	now = now.Add(time.Second)
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+
+	exists, err := breaker.ContentExists(context.Background(), "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+
+	breaker.ContentExists(context.Background(), "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+	assert.Equal(t, BreakerClosed, breaker.State())
+}
+
+func TestCircuitBreakerDocStoreClient_ReopensAfterFailedTrial(t *testing.T) {
+	client := new(model.MockDocStoreClient)
+	client.On("GetDocument", "content", "512c1f3d-e48c-4618-863c-94bc9d913b9b", mock.Anything).Return(nil, false, model.NewTransientError("connection refused"))
+	now := time.Date(2017, 5, 15, 15, 54, 32, 0, time.UTC)
+	breaker := newTestBreaker(client, &now)
+	for i := 0; i < 3; i++ {
+		breaker.GetDocument(context.Background(), "content", "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+	}
+
+	now = now.Add(30 * time.Second)
+	_, _, err := breaker.GetDocument(context.Background(), "content", "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+
+	assert.EqualError(t, err, "connection refused")
+	assert.Equal(t, BreakerOpen, breaker.State())
+	client.AssertNumberOfCalls(t, "GetDocument", 4)
+}
+
+func TestCircuitBreakerDocStoreClient_OneTrialAtATime(t *testing.T) {
+	client := new(model.MockDocStoreClient)
+	now := time.Date(2017, 5, 15, 15, 54, 32, 0, time.UTC)
+	breaker := newTestBreaker(client, &now)
+	breaker.open()
+	now = now.Add(30 * time.Second)
+
+	assert.NoError(t, breaker.before())
+	assert.Equal(t, ErrDocStoreCircuitOpen, breaker.before())
+	breaker.after(context.Background(), false)
+	assert.NoError(t, breaker.before())
+}
+
+func TestCircuitBreakerDocStoreClient_ConnectivityCheckFailsWhileOpen(t *testing.T) {
+	client := new(model.MockDocStoreClient)
+	client.On("ConnectivityCheck").Return("OK", nil)
+	now := time.Date(2017, 5, 15, 15, 54, 32, 0, time.UTC)
+	breaker := newTestBreaker(client, &now)
+
+	_, err := breaker.ConnectivityCheck()
+	assert.NoError(t, err)
+
+	breaker.open()
+	_, err = breaker.ConnectivityCheck()
+	assert.EqualError(t, err, "circuit breaker open since 2017-05-15T15:54:32Z")
+	client.AssertNumberOfCalls(t, "ConnectivityCheck", 1)
+}
+
+func TestCircuitBreakerDocStoreClient_WaitUntilAvailableBlocksWhileOpen(t *testing.T) {
+	breaker := NewCircuitBreakerDocStoreClient(new(model.MockDocStoreClient), DocStoreBreakerConfig{OpenTimeout: 50 * time.Millisecond})
+	assert.NoError(t, breaker.WaitUntilAvailable(context.Background()))
+
+	breaker.mu.Lock()
+	breaker.open()
+	breaker.mu.Unlock()
+	start := time.Now()
+	assert.NoError(t, breaker.WaitUntilAvailable(context.Background()))
+
+	assert.True(t, time.Since(start) >= 40*time.Millisecond)
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+}
+
+func TestCircuitBreakerDocStoreClient_AbandonedCallsNotCountedAsFailures(t *testing.T) {
+	client := new(model.MockDocStoreClient)
+	client.On("ContentExists", "512c1f3d-e48c-4618-863c-94bc9d913b9b", mock.Anything).Return(false, context.Canceled)
+	now := time.Date(2017, 5, 15, 15, 54, 32, 0, time.UTC)
+	breaker := newTestBreaker(client, &now)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for i := 0; i < 5; i++ {
+		breaker.ContentExists(ctx, "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+	}
+
+	assert.Equal(t, BreakerClosed, breaker.State())
+}
+
+func TestCircuitBreakerDocStoreClient_WaitUntilAvailableStopsWhenContextDone(t *testing.T) {
+	breaker := NewCircuitBreakerDocStoreClient(new(model.MockDocStoreClient), DocStoreBreakerConfig{OpenTimeout: time.Minute})
+	breaker.mu.Lock()
+	breaker.open()
+	breaker.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := breaker.WaitUntilAvailable(ctx)
+
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, BreakerOpen, breaker.State())
+}
@@ -0,0 +1,284 @@
+package mapper
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+)
+
+// Operations of the DocStoreClient reported in the cache statistics
+const (
+	opContentQuery  = "contentQuery"
+	opGetContent    = "getContent"
+	opGetDocument   = "getDocument"
+	opContentExists = "contentExists"
+)
+
+// DocStoreCacheConfig bounds the cache and sets how long the responses of each operation are kept.
+// A zero TTL disables the caching of an operation, but concurrent lookups are still coalesced.
+type DocStoreCacheConfig struct {
+	MaxEntries      int
+	ContentQueryTTL time.Duration
+	ContentTTL      time.Duration
+	DocumentTTL     time.Duration
+	ExistsTTL       time.Duration
+	// NotFoundTTL is how long the identifiers found not to resolve to any content are remembered.
+	// The content and documents found not to exist are never remembered, so they are found as soon as they are published.
+	NotFoundTTL time.Duration
+}
+
+// CacheOperationStats counts the lookups of an operation answered from the cache, by document-store-api,
+// or by a concurrent lookup of the same key. Revalidated counts the misses document-store-api answered
+// with a 304, keeping the expired value.
+type CacheOperationStats struct {
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Coalesced   uint64 `json:"coalesced"`
+	Revalidated uint64 `json:"revalidated"`
+}
+
+// DocStoreCacheStats are the statistics of a CachingDocStoreClient
+type DocStoreCacheStats struct {
+	Entries    int                            `json:"entries"`
+	MaxEntries int                            `json:"maxEntries"`
+	Evictions  uint64                         `json:"evictions"`
+	Operations map[string]CacheOperationStats `json:"operations"`
+}
+
+// CachingDocStoreClient is a DocStoreClient decorator caching the responses of document-store-api.
+// The cached content and documents are shared by all the callers and must not be modified.
+// Expired content with an ETag is revalidated with a conditional request when the wrapped client is a ContentRevalidator.
+type CachingDocStoreClient struct {
+	client DocStoreClient
+	config DocStoreCacheConfig
+
+	mu        sync.Mutex
+	entries   map[string]*list.Element
+	recency   *list.List
+	inflight  map[string]*cacheCall
+	evictions uint64
+	stats     map[string]*CacheOperationStats
+	now       func() time.Time
+}
+
+type cacheEntry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+// cacheCall is a lookup in progress, which the concurrent lookups of the same key wait for
+type cacheCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+type contentQueryResult struct {
+	status   int
+	location string
+}
+
+type documentResult struct {
+	document map[string]interface{}
+	found    bool
+}
+
+func NewCachingDocStoreClient(client DocStoreClient, config DocStoreCacheConfig) *CachingDocStoreClient {
+	stats := make(map[string]*CacheOperationStats)
+	for _, op := range []string{opContentQuery, opGetContent, opGetDocument, opContentExists} {
+		stats[op] = &CacheOperationStats{}
+	}
+	return &CachingDocStoreClient{
+		client:   client,
+		config:   config,
+		entries:  make(map[string]*list.Element),
+		recency:  list.New(),
+		inflight: make(map[string]*cacheCall),
+		stats:    stats,
+		now:      time.Now,
+	}
+}
+
+// ContentQuery caches the location of the content found for an identifier, and the identifiers not found.
+// Any other status, like a server error, is never cached.
+func (c *CachingDocStoreClient) ContentQuery(ctx context.Context, authority, identifier, tid string) (int, string, error) {
+	value, err := c.lookup(ctx, opContentQuery, "content-query:"+authority+"|"+identifier, func(value interface{}) time.Duration {
+		switch value.(contentQueryResult).status {
+		case http.StatusMovedPermanently:
+			return c.config.ContentQueryTTL
+		case http.StatusNotFound:
+			return c.config.NotFoundTTL
+		}
+		return 0
+	}, func(interface{}) (interface{}, error) {
+		status, location, err := c.client.ContentQuery(ctx, authority, identifier, tid)
+		return contentQueryResult{status: status, location: location}, err
+	})
+	if err != nil {
+		return -1, "", err
+	}
+	result := value.(contentQueryResult)
+	return result.status, result.location, nil
+}
+
+func (c *CachingDocStoreClient) GetContent(ctx context.Context, uuid, tid string) (*model.DocStoreUppContent, error) {
+	value, err := c.lookup(ctx, opGetContent, "content:"+uuid, func(interface{}) time.Duration {
+		return c.config.ContentTTL
+	}, func(expired interface{}) (interface{}, error) {
+		revalidator, ok := c.client.(ContentRevalidator)
+		if expired == nil || expired.(*model.DocStoreUppContent).ETag == "" || !ok {
+			return c.client.GetContent(ctx, uuid, tid)
+		}
+		content, modified, err := revalidator.RevalidateContent(ctx, uuid, expired.(*model.DocStoreUppContent).ETag, tid)
+		if err != nil || modified {
+			return content, err
+		}
+		c.mu.Lock()
+		c.stats[opGetContent].Revalidated++
+		c.mu.Unlock()
+		return expired, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*model.DocStoreUppContent), nil
+}
+
+func (c *CachingDocStoreClient) GetDocument(ctx context.Context, collection, uuid, tid string) (map[string]interface{}, bool, error) {
+	value, err := c.lookup(ctx, opGetDocument, "document:"+collection+"/"+uuid, func(value interface{}) time.Duration {
+		if !value.(documentResult).found {
+			return 0
+		}
+		return c.config.DocumentTTL
+	}, func(interface{}) (interface{}, error) {
+		document, found, err := c.client.GetDocument(ctx, collection, uuid, tid)
+		return documentResult{document: document, found: found}, err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	result := value.(documentResult)
+	return result.document, result.found, nil
+}
+
+func (c *CachingDocStoreClient) ContentExists(ctx context.Context, uuid, tid string) (bool, error) {
+	value, err := c.lookup(ctx, opContentExists, "exists:"+uuid, func(value interface{}) time.Duration {
+		if !value.(bool) {
+			return 0
+		}
+		return c.config.ExistsTTL
+	}, func(interface{}) (interface{}, error) {
+		return c.client.ContentExists(ctx, uuid, tid)
+	})
+	if err != nil {
+		return false, err
+	}
+	return value.(bool), nil
+}
+
+func (c *CachingDocStoreClient) ConnectivityCheck() (string, error) {
+	return c.client.ConnectivityCheck()
+}
+
+// Stats returns a snapshot of the cache statistics
+func (c *CachingDocStoreClient) Stats() DocStoreCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	operations := make(map[string]CacheOperationStats, len(c.stats))
+	for op, stats := range c.stats {
+		operations[op] = *stats
+	}
+	return DocStoreCacheStats{
+		Entries:    c.recency.Len(),
+		MaxEntries: c.config.MaxEntries,
+		Evictions:  c.evictions,
+		Operations: operations,
+	}
+}
+
+// lookup returns the cached value of the key, or fetches it, sharing the fetch with the concurrent lookups of the same key.
+// Values are cached for the TTL returned by ttl; errors are never cached.
+// A lookup stops waiting for a shared fetch when its context is done, and fetches again if the shared fetch was abandoned by its own caller.
+// The fetch is given the expired value of the key, if any, to revalidate it.
+func (c *CachingDocStoreClient) lookup(ctx context.Context, op, key string, ttl func(value interface{}) time.Duration, fetch func(expired interface{}) (interface{}, error)) (interface{}, error) {
+	var expired interface{}
+	c.mu.Lock()
+	for {
+		if e, found := c.entries[key]; found {
+			entry := e.Value.(*cacheEntry)
+			if c.now().Before(entry.expiresAt) {
+				c.recency.MoveToFront(e)
+				c.stats[op].Hits++
+				c.mu.Unlock()
+				return entry.value, nil
+			}
+			expired = entry.value
+			c.remove(e)
+		}
+		call, found := c.inflight[key]
+		if !found {
+			break
+		}
+		c.stats[op].Coalesced++
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if !isContextDone(call.err) || ctx.Err() != nil {
+			return call.value, call.err
+		}
+		c.mu.Lock()
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.stats[op].Misses++
+	c.mu.Unlock()
+
+	call.value, call.err = fetch(expired)
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if call.err == nil {
+		if d := ttl(call.value); d > 0 {
+			c.store(key, call.value, d)
+		}
+	}
+	c.mu.Unlock()
+	close(call.done)
+	return call.value, call.err
+}
+
+func (c *CachingDocStoreClient) store(key string, value interface{}, ttl time.Duration) {
+	if c.config.MaxEntries <= 0 {
+		return
+	}
+	entry := &cacheEntry{key: key, value: value, expiresAt: c.now().Add(ttl)}
+	if e, found := c.entries[key]; found {
+		e.Value = entry
+		c.recency.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.recency.PushFront(entry)
+	for c.recency.Len() > c.config.MaxEntries {
+		c.remove(c.recency.Back())
+		c.evictions++
+	}
+}
+
+func (c *CachingDocStoreClient) remove(e *list.Element) {
+	c.recency.Remove(e)
+	delete(c.entries, e.Value.(*cacheEntry).key)
+}
+
+// isContextDone reports whether err is due to a context canceled or past its deadline
+func isContextDone(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
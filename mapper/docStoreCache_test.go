@@ -0,0 +1,282 @@
+package mapper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func testCacheConfig() DocStoreCacheConfig {
+	return DocStoreCacheConfig{
+		MaxEntries:      10,
+		ContentQueryTTL: time.Minute,
+		ContentTTL:      time.Minute,
+		DocumentTTL:     time.Minute,
+		ExistsTTL:       time.Minute,
+		NotFoundTTL:     10 * time.Second,
+	}
+}
+
+func TestCachingDocStoreClient_ContentQueryCachedUntilExpired(t *testing.T) {
+	client := new(model.MockDocStoreClient)
+	client.On("ContentQuery", "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", "tid_1").Return(http.StatusMovedPermanently, "http://api.ft.com/content/2f4ef4fa-c4ef-11e6-9e20-4d1f2b3c7d0e", nil).Once()
+	client.On("ContentQuery", "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", "tid_3").Return(http.StatusMovedPermanently, "http://api.ft.com/content/2f4ef4fa-c4ef-11e6-9e20-4d1f2b3c7d0e", nil).Once()
+	now := time.Date(2017, 5, 15, 15, 54, 32, 0, time.UTC)
+	cache := NewCachingDocStoreClient(client, testCacheConfig())
+	cache.now = func() time.Time { return now }
+
+	status, location, err := cache.ContentQuery(context.Background(), "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", "tid_1")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusMovedPermanently, status)
+	assert.Equal(t, "http://api.ft.com/content/2f4ef4fa-c4ef-11e6-9e20-4d1f2b3c7d0e", location)
+
+	status, location, err = cache.ContentQuery(context.Background(), "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", "tid_2")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusMovedPermanently, status)
+	assert.Equal(t, "http://api.ft.com/content/2f4ef4fa-c4ef-11e6-9e20-4d1f2b3c7d0e", location)
+	client.AssertNumberOfCalls(t, "ContentQuery", 1)
+
+	now = now.Add(time.Minute)
+	cache.ContentQuery(context.Background(), "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", "tid_3")
+	client.AssertNumberOfCalls(t, "ContentQuery", 2)
+
+	stats := cache.Stats().Operations[opContentQuery]
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(2), stats.Misses)
+}
+
+func TestCachingDocStoreClient_NotFoundCachedForNotFoundTTL(t *testing.T) {
+	client := new(model.MockDocStoreClient)
+	client.On("ContentQuery", "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", mock.Anything).Return(http.StatusNotFound, "", nil)
+	now := time.Date(2017, 5, 15, 15, 54, 32, 0, time.UTC)
+	cache := NewCachingDocStoreClient(client, testCacheConfig())
+	cache.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		status, _, err := cache.ContentQuery(context.Background(), "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", "tid_test123")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, status)
+	}
+	client.AssertNumberOfCalls(t, "ContentQuery", 1)
+
+	now = now.Add(10 * time.Second)
+	cache.ContentQuery(context.Background(), "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", "tid_test123")
+	client.AssertNumberOfCalls(t, "ContentQuery", 2)
+}
+
+func TestCachingDocStoreClient_MissingContentNotCached(t *testing.T) {
+	client := new(model.MockDocStoreClient)
+	client.On("ContentExists", "512c1f3d-e48c-4618-863c-94bc9d913b9b", mock.Anything).Return(false, nil)
+	client.On("GetDocument", "content", "512c1f3d-e48c-4618-863c-94bc9d913b9b", mock.Anything).Return(nil, false, nil)
+	cache := NewCachingDocStoreClient(client, testCacheConfig())
+
+	for i := 0; i < 2; i++ {
+		exists, err := cache.ContentExists(context.Background(), "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+		assert.NoError(t, err)
+		assert.False(t, exists)
+		_, found, err := cache.GetDocument(context.Background(), "content", "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+		assert.NoError(t, err)
+		assert.False(t, found)
+	}
+	client.AssertNumberOfCalls(t, "ContentExists", 2)
+	client.AssertNumberOfCalls(t, "GetDocument", 2)
+}
+
+func TestCachingDocStoreClient_ErrorsAndServerErrorsNotCached(t *testing.T) {
+	client := new(model.MockDocStoreClient)
+	client.On("GetContent", "512c1f3d-e48c-4618-863c-94bc9d913b9b", mock.Anything).Return((*model.DocStoreUppContent)(nil), model.NewTransientError("document-store-api unavailable"))
+	client.On("ContentQuery", "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", mock.Anything).Return(http.StatusServiceUnavailable, "", nil)
+	cache := NewCachingDocStoreClient(client, testCacheConfig())
+
+	for i := 0; i < 2; i++ {
+		_, err := cache.GetContent(context.Background(), "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+		assert.True(t, model.IsTransient(err))
+		status, _, err := cache.ContentQuery(context.Background(), "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", "tid_test123")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+	}
+	client.AssertNumberOfCalls(t, "GetContent", 2)
+	client.AssertNumberOfCalls(t, "ContentQuery", 2)
+}
+
+func TestCachingDocStoreClient_EvictsLeastRecentlyUsed(t *testing.T) {
+	client := new(model.MockDocStoreClient)
+	client.On("ContentExists", mock.Anything, mock.Anything).Return(true, nil)
+	config := testCacheConfig()
+	config.MaxEntries = 2
+	cache := NewCachingDocStoreClient(client, config)
+
+	cache.ContentExists(context.Background(), "uuid1", "tid_test123")
+	cache.ContentExists(context.Background(), "uuid2", "tid_test123")
+	cache.ContentExists(context.Background(), "uuid1", "tid_test123")
+	cache.ContentExists(context.Background(), "uuid3", "tid_test123")
+	cache.ContentExists(context.Background(), "uuid1", "tid_test123")
+	client.AssertNumberOfCalls(t, "ContentExists", 3)
+
+	cache.ContentExists(context.Background(), "uuid2", "tid_test123")
+	client.AssertNumberOfCalls(t, "ContentExists", 4)
+	stats := cache.Stats()
+	assert.Equal(t, 2, stats.Entries)
+	assert.Equal(t, uint64(2), stats.Evictions)
+}
+
+// blockingDocStoreClient answers GetContent once released, counting the calls
+type blockingDocStoreClient struct {
+	model.MockDocStoreClient
+	mu      sync.Mutex
+	calls   int
+	release chan struct{}
+}
+
+func (c *blockingDocStoreClient) GetContent(ctx context.Context, uuid, tid string) (*model.DocStoreUppContent, error) {
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+	select {
+	case <-c.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &model.DocStoreUppContent{UppCoreContent: model.UppCoreContent{UUID: uuid}}, nil
+}
+
+func TestCachingDocStoreClient_CoalescesConcurrentLookups(t *testing.T) {
+	client := &blockingDocStoreClient{release: make(chan struct{})}
+	cache := NewCachingDocStoreClient(client, testCacheConfig())
+
+	var wg sync.WaitGroup
+	results := make([]*model.DocStoreUppContent, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = cache.GetContent(context.Background(), "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+		}(i)
+	}
+	for {
+		stats := cache.Stats().Operations[opGetContent]
+		if stats.Misses+stats.Coalesced == 5 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(client.release)
+	wg.Wait()
+
+	assert.Equal(t, 1, client.calls)
+	for _, result := range results {
+		assert.Equal(t, "512c1f3d-e48c-4618-863c-94bc9d913b9b", result.UUID)
+	}
+	stats := cache.Stats().Operations[opGetContent]
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, uint64(4), stats.Coalesced)
+}
+
+func TestCachingDocStoreClient_CoalescedLookupStopsWaitingWhenContextDone(t *testing.T) {
+	client := &blockingDocStoreClient{release: make(chan struct{})}
+	defer close(client.release)
+	cache := NewCachingDocStoreClient(client, testCacheConfig())
+	go cache.GetContent(context.Background(), "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+	for cache.Stats().Operations[opGetContent].Misses == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := cache.GetContent(ctx, "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, uint64(1), cache.Stats().Operations[opGetContent].Coalesced)
+}
+
+func TestCachingDocStoreClient_LookupAbandonedByItsCallerFetchedAgain(t *testing.T) {
+	client := &blockingDocStoreClient{release: make(chan struct{})}
+	cache := NewCachingDocStoreClient(client, testCacheConfig())
+	ctx, cancel := context.WithCancel(context.Background())
+	abandoned := make(chan error)
+	go func() {
+		_, err := cache.GetContent(ctx, "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+		abandoned <- err
+	}()
+	for cache.Stats().Operations[opGetContent].Misses == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	results := make(chan *model.DocStoreUppContent)
+	go func() {
+		content, _ := cache.GetContent(context.Background(), "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+		results <- content
+	}()
+	for cache.Stats().Operations[opGetContent].Coalesced == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-abandoned)
+	close(client.release)
+	content := <-results
+
+	assert.Equal(t, "512c1f3d-e48c-4618-863c-94bc9d913b9b", content.UUID)
+	assert.Equal(t, 2, client.calls)
+}
+
+func TestCachingDocStoreClient_ExpiredContentRevalidated(t *testing.T) {
+	var conditional []string
+	serverMock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditional = append(conditional, r.Header.Get("If-None-Match"))
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(`{"uuid": "512c1f3d-e48c-4618-863c-94bc9d913b9b"}`))
+	}))
+	defer serverMock.Close()
+	now := time.Date(2017, 5, 15, 15, 54, 32, 0, time.UTC)
+	cache := NewCachingDocStoreClient(NewHttpDocStoreClient(http.DefaultClient, serverMock.URL), testCacheConfig())
+	cache.now = func() time.Time { return now }
+
+	first, err := cache.GetContent(context.Background(), "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+	assert.NoError(t, err)
+	now = now.Add(time.Minute)
+	revalidated, err := cache.GetContent(context.Background(), "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+	assert.NoError(t, err)
+	cache.GetContent(context.Background(), "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+
+	assert.True(t, first == revalidated)
+	assert.Equal(t, []string{"", `"v1"`}, conditional)
+	stats := cache.Stats().Operations[opGetContent]
+	assert.Equal(t, uint64(2), stats.Misses)
+	assert.Equal(t, uint64(1), stats.Revalidated)
+	assert.Equal(t, uint64(1), stats.Hits)
+}
+
+func TestCachingDocStoreClient_ZeroTTLNotCached(t *testing.T) {
+	client := new(model.MockDocStoreClient)
+	client.On("GetDocument", "content", "512c1f3d-e48c-4618-863c-94bc9d913b9b", mock.Anything).Return(map[string]interface{}{"uuid": "512c1f3d-e48c-4618-863c-94bc9d913b9b"}, true, nil)
+	config := testCacheConfig()
+	config.DocumentTTL = 0
+	cache := NewCachingDocStoreClient(client, config)
+
+	cache.GetDocument(context.Background(), "content", "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+	cache.GetDocument(context.Background(), "content", "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+
+	client.AssertNumberOfCalls(t, "GetDocument", 2)
+}
+
+func TestCachingDocStoreClient_ConnectivityCheckNotCached(t *testing.T) {
+	client := new(model.MockDocStoreClient)
+	client.On("ConnectivityCheck").Return("Error connecting to document-store-api", errors.New("status=503"))
+	cache := NewCachingDocStoreClient(client, testCacheConfig())
+
+	_, err := cache.ConnectivityCheck()
+
+	assert.EqualError(t, err, "status=503")
+}
@@ -1,113 +1,249 @@
 package mapper
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
 	"github.com/Financial-Times/transactionid-utils-go"
 	"github.com/Sirupsen/logrus"
 )
 
+// DocStoreClient reads from document-store-api. Every lookup gives up as soon as its context is done.
 type DocStoreClient interface {
-	ContentQuery(authority, identifier, tid string) (status int, location string, err error)
-	GetContent(uuid, tid string) (*model.DocStoreUppContent, error)
-	ContentExists(uuid, tid string) (bool, error)
+	ContentQuery(ctx context.Context, authority, identifier, tid string) (status int, location string, err error)
+	GetContent(ctx context.Context, uuid, tid string) (*model.DocStoreUppContent, error)
+	GetDocument(ctx context.Context, collection, uuid, tid string) (document map[string]interface{}, found bool, err error)
+	ContentExists(ctx context.Context, uuid, tid string) (bool, error)
 	ConnectivityCheck() (string, error)
 }
 
+// ContentRevalidator is implemented by the DocStoreClients able to fetch content only if it changed
+// since the version with the given ETag. The content is nil and modified is false when it didn't change.
+type ContentRevalidator interface {
+	RevalidateContent(ctx context.Context, uuid, etag, tid string) (content *model.DocStoreUppContent, modified bool, err error)
+}
+
+// httpDocStoreClient calls document-store-api on one or more addresses.
+// Calls are spread over the healthy addresses and retried on the next one after a network error or a 5xx or 429 response,
+// which is safe as they are all idempotent GETs and HEADs.
 type httpDocStoreClient struct {
-	docStoreAddress string
-	client          *http.Client
+	addresses *docStoreAddressPool
+	client    *http.Client
+	// headUnsupported is set once document-store-api refused a HEAD request, to check existence with GETs from then on
+	headUnsupported int32
+
+	stop    chan struct{}
+	stopped sync.WaitGroup
 }
 
-func NewHttpDocStoreClient(client *http.Client, docStoreAddress string) *httpDocStoreClient {
-	return &httpDocStoreClient{docStoreAddress: docStoreAddress, client: client}
+func NewHttpDocStoreClient(client *http.Client, docStoreAddresses ...string) *httpDocStoreClient {
+	return &httpDocStoreClient{addresses: newDocStoreAddressPool(docStoreAddresses), client: client}
 }
 
-func (c *httpDocStoreClient) GetContent(uuid, tid string) (*model.DocStoreUppContent, error) {
-	docStoreURL, err := url.Parse(c.docStoreAddress + "/content/" + uuid)
+// GetContent returns the content with the given uuid, or a NotFoundError if document-store-api has none
+func (c *httpDocStoreClient) GetContent(ctx context.Context, uuid, tid string) (*model.DocStoreUppContent, error) {
+	resp, err := c.get(ctx, "/content/"+uuid, nil, tid)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse rawurl into URL structure for docStoreAddress=%v uuid=%v: %v", c.docStoreAddress, uuid, err.Error())
+		return nil, fmt.Errorf("unsuccessful request for content for uuid=%v: %w", uuid, err)
 	}
-	req, err := http.NewRequest(http.MethodGet, docStoreURL.String(), nil)
+	defer niceClose(resp)
+	return readContent(resp, uuid)
+}
+
+// RevalidateContent fetches the content with the given uuid only if its ETag changed, with a conditional request
+func (c *httpDocStoreClient) RevalidateContent(ctx context.Context, uuid, etag, tid string) (*model.DocStoreUppContent, bool, error) {
+	header := http.Header{}
+	header.Set("If-None-Match", etag)
+	resp, err := c.send(ctx, http.MethodGet, "/content/"+uuid, nil, header, tid)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request to fetch content for uuid=%v: %v", uuid, err.Error())
+		return nil, false, fmt.Errorf("unsuccessful request for content for uuid=%v: %w", uuid, err)
 	}
-	req.Header.Add(transactionidutils.TransactionIDHeader, tid)
-	resp, err := c.client.Do(req)
+	defer niceClose(resp)
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, false, nil
+	}
+	content, err := readContent(resp, uuid)
 	if err != nil {
-		return nil, fmt.Errorf("unsuccessful request for content for uuid=%v: %v", uuid, err.Error())
+		return nil, false, err
 	}
-	defer niceClose(resp)
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("received status code=%v for uuid=%v", resp.StatusCode, uuid)
+	return content, true, nil
+}
+
+func readContent(resp *http.Response, uuid string) (*model.DocStoreUppContent, error) {
+	if err := contentStatusError(resp.StatusCode, uuid); err != nil {
+		return nil, err
 	}
 	bodyAsBytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body for uuid=%v: %v", uuid, err.Error())
+		return nil, model.NewDependencyError(fmt.Sprintf("failed to read response body for uuid=%v: %v", uuid, err.Error()))
 	}
 	var content model.DocStoreUppContent
 	err = json.Unmarshal(bodyAsBytes, &content)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response body for uuid=%v: %v", uuid, err.Error())
+		return nil, model.NewDependencyError(fmt.Sprintf("failed to unmarshal response body for uuid=%v: %v", uuid, err.Error()))
 	}
+	content.ETag = resp.Header.Get("ETag")
 
 	return &content, nil
 }
 
-func (c *httpDocStoreClient) ContentExists(uuid, tid string) (bool, error) {
-	docStoreUrl, err := url.Parse(c.docStoreAddress + "/content/" + uuid)
-	if err != nil {
-		return false, fmt.Errorf("failed to parse rawurl into URL structure for docStoreAddress=%v uuid=%v: %v", c.docStoreAddress, uuid, err.Error())
-	}
-	req, err := http.NewRequest(http.MethodGet, docStoreUrl.String(), nil)
-	if err != nil {
-		return false, fmt.Errorf("failed to create request to fetch content for uuid=%v: %v", uuid, err.Error())
+// contentStatusError tells a missing content apart from a document-store-api failing to answer
+func contentStatusError(status int, uuid string) error {
+	switch {
+	case status == http.StatusOK:
+		return nil
+	case status == http.StatusNotFound:
+		return model.NewNotFoundError(fmt.Sprintf("content not found for uuid=%v", uuid))
+	case isTransientStatus(status):
+		return model.NewTransientError(fmt.Sprintf("received status code=%v for uuid=%v", status, uuid))
 	}
-	req.Header.Add(transactionidutils.TransactionIDHeader, tid)
-	resp, err := c.client.Do(req)
+	return model.NewDependencyError(fmt.Sprintf("received status code=%v for uuid=%v", status, uuid))
+}
+
+// GetDocument returns the document stored in the given collection (e.g. content or complementarycontent) as it is
+func (c *httpDocStoreClient) GetDocument(ctx context.Context, collection, uuid, tid string) (map[string]interface{}, bool, error) {
+	resp, err := c.get(ctx, "/"+collection+"/"+uuid, nil, tid)
 	if err != nil {
-		return false, fmt.Errorf("unsuccessful request for content for uuid=%v: %v", uuid, err.Error())
+		return nil, false, fmt.Errorf("unsuccessful request for document for collection=%v uuid=%v: %w", collection, uuid, err)
 	}
 	defer niceClose(resp)
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if isTransientStatus(resp.StatusCode) {
+		return nil, false, model.NewTransientError(fmt.Sprintf("received status code=%v for collection=%v uuid=%v", resp.StatusCode, collection, uuid))
+	}
 	if resp.StatusCode != http.StatusOK {
-		return false, nil
+		return nil, false, model.NewDependencyError(fmt.Sprintf("received status code=%v for collection=%v uuid=%v", resp.StatusCode, collection, uuid))
 	}
-	return true, nil
+	var document map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		return nil, false, model.NewDependencyError(fmt.Sprintf("failed to unmarshal response body for collection=%v uuid=%v: %v", collection, uuid, err.Error()))
+	}
+	return document, true, nil
 }
 
-func (c *httpDocStoreClient) ContentQuery(authority, identifier, tid string) (status int, location string, err error) {
-	docStoreURL, err := url.Parse(c.docStoreAddress + "/content-query")
+// ContentExists checks the content with a HEAD request, falling back to a GET if document-store-api doesn't support HEAD.
+// Only a 404 means the content doesn't exist; any other failure is returned as an error.
+func (c *httpDocStoreClient) ContentExists(ctx context.Context, uuid, tid string) (bool, error) {
+	method := http.MethodHead
+	if atomic.LoadInt32(&c.headUnsupported) == 1 {
+		method = http.MethodGet
+	}
+	resp, err := c.send(ctx, method, "/content/"+uuid, nil, nil, tid)
+	if err == nil && method == http.MethodHead && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		niceClose(resp)
+		if atomic.CompareAndSwapInt32(&c.headUnsupported, 0, 1) {
+			logrus.WithField("status", resp.StatusCode).WithField("transaction_id", tid).Warn("document-store-api doesn't support HEAD requests, checking content existence with GET")
+		}
+		resp, err = c.get(ctx, "/content/"+uuid, nil, tid)
+	}
 	if err != nil {
-		return -1, "", fmt.Errorf("invalid address docStoreAddress=%v: %v", c.docStoreAddress, err.Error())
+		return false, fmt.Errorf("unsuccessful request for content for uuid=%v: %w", uuid, err)
+	}
+	defer niceClose(resp)
+	err = contentStatusError(resp.StatusCode, uuid)
+	if model.IsNotFound(err) {
+		return false, nil
 	}
+	return err == nil, err
+}
+
+func (c *httpDocStoreClient) ContentQuery(ctx context.Context, authority, identifier, tid string) (status int, location string, err error) {
 	query := url.Values{}
 	query.Add("identifierValue", identifier)
 	query.Add("identifierAuthority", authority)
-	docStoreURL.RawQuery = query.Encode()
-	req, err := http.NewRequest(http.MethodGet, docStoreURL.String(), nil)
-	if err != nil {
-		return -1, "", fmt.Errorf("couldn't create request to fetch canonical identifier for authority=%v identifier=%v: %v", authority, identifier, err.Error())
-	}
-	req.Header.Add(transactionidutils.TransactionIDHeader, tid)
-	resp, err := c.client.Do(req)
+	resp, err := c.get(ctx, "/content-query", query, tid)
 	if err != nil {
-		return -1, "", fmt.Errorf("unsuccessful request for fetching canonical identifier for authority=%v identifier=%v: %v", authority, identifier, err.Error())
+		return -1, "", fmt.Errorf("unsuccessful request for fetching canonical identifier for authority=%v identifier=%v: %w", authority, identifier, err)
 	}
 	niceClose(resp)
 
 	return resp.StatusCode, resp.Header.Get("Location"), nil
 }
 
+// ConnectivityCheck checks every address and succeeds if at least one of them is good to go
 func (c *httpDocStoreClient) ConnectivityCheck() (string, error) {
 	errMsg := "Error connecting to document-store-api"
-	docStoreGtgUrl, err := url.Parse(c.docStoreAddress + "/__gtg")
+	addresses := c.addresses.addresses()
+	if len(addresses) == 0 {
+		return errMsg, errors.New("no document-store-api address configured")
+	}
+	var failures []string
+	for _, address := range addresses {
+		if _, err := c.CheckAddress(address); err != nil {
+			failures = append(failures, fmt.Sprintf("%v: %v", address, err.Error()))
+		}
+	}
+	if len(failures) == len(addresses) {
+		return errMsg, errors.New(strings.Join(failures, "; "))
+	}
+	return "OK", nil
+}
+
+// Addresses returns the document-store-api addresses the calls are spread over
+func (c *httpDocStoreClient) Addresses() []string {
+	return c.addresses.addresses()
+}
+
+// AddressStatuses returns the health of every document-store-api address as found by its last check
+func (c *httpDocStoreClient) AddressStatuses() []DocStoreAddressStatus {
+	return c.addresses.snapshot()
+}
+
+// CheckAddress calls the /__gtg endpoint of one document-store-api address,
+// ejecting the address if the check fails and restoring it once it passes again
+func (c *httpDocStoreClient) CheckAddress(address string) (string, error) {
+	msg, err := c.gtg(address)
+	c.addresses.record(address, err)
+	return msg, err
+}
+
+// StartHealthChecks checks every address with the given interval in the background
+func (c *httpDocStoreClient) StartHealthChecks(interval time.Duration) {
+	c.stop = make(chan struct{})
+	c.stopped.Add(1)
+	go func() {
+		defer c.stopped.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				for _, address := range c.addresses.addresses() {
+					c.CheckAddress(address)
+				}
+			}
+		}
+	}()
+}
+
+// StopHealthChecks stops the checks started by StartHealthChecks
+func (c *httpDocStoreClient) StopHealthChecks() {
+	if c.stop == nil {
+		return
+	}
+	close(c.stop)
+	c.stopped.Wait()
+}
+
+func (c *httpDocStoreClient) gtg(address string) (string, error) {
+	errMsg := "Error connecting to document-store-api"
+	docStoreGtgUrl, err := url.Parse(address + "/__gtg")
 	if err != nil {
-		return errMsg, fmt.Errorf("invalid address docStoreAddress=%v: %v", c.docStoreAddress, err.Error())
+		return errMsg, fmt.Errorf("invalid address docStoreAddress=%v: %v", address, err.Error())
 	}
 	req, err := http.NewRequest(http.MethodGet, docStoreGtgUrl.String(), nil)
 	if err != nil {
@@ -119,11 +255,80 @@ func (c *httpDocStoreClient) ConnectivityCheck() (string, error) {
 	}
 	niceClose(resp)
 	if resp.StatusCode != http.StatusOK {
-		return "Error connecting to document-store-api", fmt.Errorf("status=%v", resp.StatusCode)
+		return errMsg, fmt.Errorf("status=%v", resp.StatusCode)
 	}
 	return "OK", nil
 }
 
+func (c *httpDocStoreClient) get(ctx context.Context, path string, query url.Values, tid string) (*http.Response, error) {
+	return c.send(ctx, http.MethodGet, path, query, nil, tid)
+}
+
+// send sends a request for the path to the candidate addresses in turn,
+// until one of them answers without a network error or a transient status code.
+// When every address fails, the error or response of the last one is returned.
+// No further address is tried once the context is done.
+func (c *httpDocStoreClient) send(ctx context.Context, method, path string, query url.Values, header http.Header, tid string) (*http.Response, error) {
+	candidates := c.addresses.candidates()
+	if len(candidates) == 0 {
+		return nil, errors.New("no document-store-api address configured")
+	}
+	var resp *http.Response
+	var err error
+	for i, address := range candidates {
+		if resp != nil {
+			niceClose(resp)
+		}
+		resp, err = c.sendTo(ctx, address, method, path, query, header, tid)
+		if err == nil && !isTransientStatus(resp.StatusCode) || ctx.Err() != nil {
+			return resp, err
+		}
+		if i < len(candidates)-1 {
+			logger := logrus.WithField("address", address).WithField("path", path).WithField("transaction_id", tid)
+			if err != nil {
+				logger = logger.WithError(err)
+			} else {
+				logger = logger.WithField("status", resp.StatusCode)
+			}
+			logger.Warn("document-store-api call failed, retrying on the next address")
+		}
+	}
+	return resp, err
+}
+
+func (c *httpDocStoreClient) sendTo(ctx context.Context, address, method, path string, query url.Values, header http.Header, tid string) (*http.Response, error) {
+	docStoreURL, err := url.Parse(address + path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rawurl into URL structure for docStoreAddress=%v: %v", address, err.Error())
+	}
+	if query != nil {
+		docStoreURL.RawQuery = query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, docStoreURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create request to docStoreAddress=%v: %v", address, err.Error())
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Add(transactionidutils.TransactionIDHeader, tid)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		// a request abandoned by its caller, or past its deadline, is not a failure of document-store-api
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, model.NewTransientError(err.Error())
+	}
+	return resp, nil
+}
+
+// isTransientStatus reports whether a request answered with status may succeed if retried.
+// A 501 never will, as the server doesn't implement the request at all.
+func isTransientStatus(status int) bool {
+	return (status >= http.StatusInternalServerError && status != http.StatusNotImplemented) || status == http.StatusTooManyRequests
+}
+
 func niceClose(resp *http.Response) {
 	defer func() {
 		err := resp.Body.Close()
@@ -1,11 +1,15 @@
 package mapper
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
 	"github.com/stretchr/testify/assert"
@@ -34,7 +38,7 @@ func TestGetContent_StatusOk(t *testing.T) {
 	defer serverMock.Close()
 	client := NewHttpDocStoreClient(http.DefaultClient, serverMock.URL)
 
-	content, err := client.GetContent("e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
+	content, err := client.GetContent(context.Background(), "e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
 
 	assert.NoError(t, err, "Error wasn't expected during GetContent")
 	assert.Equal(t, []model.Brand{{ID: "http://api.ft.com/things/164d0c3b-8a5a-4163-9519-96b57ed159bf"}, {ID: "http://api.ft.com/things/dbb0bdae-1f0c-11e4-b0cb-b2227cce2b54"}}, content.Brands)
@@ -48,9 +52,10 @@ func TestGetContent_StatusInternalServerError(t *testing.T) {
 	defer serverMock.Close()
 	client := NewHttpDocStoreClient(http.DefaultClient, serverMock.URL)
 
-	_, err := client.GetContent("e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
+	_, err := client.GetContent(context.Background(), "e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
 
 	assert.Error(t, err)
+	assert.True(t, model.IsTransient(err), "A 500 from document-store-api should be a transient error")
 }
 
 func TestGetContent_StatusNotFound(t *testing.T) {
@@ -58,9 +63,11 @@ func TestGetContent_StatusNotFound(t *testing.T) {
 	defer serverMock.Close()
 	client := NewHttpDocStoreClient(http.DefaultClient, serverMock.URL)
 
-	_, err := client.GetContent("e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
+	_, err := client.GetContent(context.Background(), "e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
 
 	assert.Error(t, err)
+	assert.False(t, model.IsTransient(err), "A 404 from document-store-api should be a permanent error")
+	assert.True(t, model.IsNotFound(err))
 }
 
 func TestGetContent_StatusServiceUnavailable(t *testing.T) {
@@ -68,9 +75,21 @@ func TestGetContent_StatusServiceUnavailable(t *testing.T) {
 	defer serverMock.Close()
 	client := NewHttpDocStoreClient(http.DefaultClient, serverMock.URL)
 
-	_, err := client.GetContent("e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
+	_, err := client.GetContent(context.Background(), "e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
 
 	assert.Error(t, err)
+	assert.True(t, model.IsTransient(err), "A 503 from document-store-api should be a transient error")
+}
+
+func TestGetContent_NetworkError(t *testing.T) {
+	serverMock := errorDocumentStoreServerMock(t, http.StatusServiceUnavailable)
+	serverMock.Close()
+	client := NewHttpDocStoreClient(http.DefaultClient, serverMock.URL)
+
+	_, err := client.GetContent(context.Background(), "e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
+
+	assert.Error(t, err)
+	assert.True(t, model.IsTransient(err), "A network error should be a transient error")
 }
 
 func TestGetContent_InvalidJsonInResponse(t *testing.T) {
@@ -78,7 +97,7 @@ func TestGetContent_InvalidJsonInResponse(t *testing.T) {
 	defer serverMock.Close()
 	client := NewHttpDocStoreClient(http.DefaultClient, serverMock.URL)
 
-	_, err := client.GetContent("e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
+	_, err := client.GetContent(context.Background(), "e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
 
 	assert.Error(t, err)
 }
@@ -87,7 +106,7 @@ func TestContentExists_StatusNotFound(t *testing.T) {
 	serverMock := errorDocumentStoreServerMock(t, http.StatusNotFound)
 	defer serverMock.Close()
 	client := NewHttpDocStoreClient(http.DefaultClient, serverMock.URL)
-	found, err := client.ContentExists("e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
+	found, err := client.ContentExists(context.Background(), "e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
 	assert.NoError(t, err)
 	assert.False(t, found)
 }
@@ -96,7 +115,259 @@ func TestContentExists_StatusOK(t *testing.T) {
 	serverMock := successfulDocumentStoreServerMock(t, "document_store_content.json")
 	defer serverMock.Close()
 	client := NewHttpDocStoreClient(http.DefaultClient, serverMock.URL)
-	found, err := client.ContentExists("e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
+	found, err := client.ContentExists(context.Background(), "e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
 	assert.NoError(t, err)
 	assert.True(t, found)
 }
+
+func TestContentExists_UsesHead(t *testing.T) {
+	var methods []string
+	serverMock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+	}))
+	defer serverMock.Close()
+	client := NewHttpDocStoreClient(http.DefaultClient, serverMock.URL)
+
+	found, err := client.ContentExists(context.Background(), "e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
+
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []string{http.MethodHead}, methods)
+}
+
+func TestContentExists_FallsBackToGetWhenHeadNotAllowed(t *testing.T) {
+	var methods []string
+	serverMock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer serverMock.Close()
+	client := NewHttpDocStoreClient(http.DefaultClient, serverMock.URL)
+
+	for i := 0; i < 2; i++ {
+		found, err := client.ContentExists(context.Background(), "e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
+		assert.NoError(t, err)
+		assert.True(t, found)
+	}
+	assert.Equal(t, []string{http.MethodHead, http.MethodGet, http.MethodGet}, methods)
+}
+
+func TestContentExists_StatusInternalServerError(t *testing.T) {
+	serverMock := errorDocumentStoreServerMock(t, http.StatusInternalServerError)
+	defer serverMock.Close()
+	client := NewHttpDocStoreClient(http.DefaultClient, serverMock.URL)
+
+	found, err := client.ContentExists(context.Background(), "e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
+
+	assert.False(t, found)
+	assert.True(t, model.IsTransient(err), "A 500 from document-store-api should not mean the content doesn't exist")
+	assert.False(t, model.IsNotFound(err))
+}
+
+func TestContentExists_StatusBadRequest(t *testing.T) {
+	serverMock := errorDocumentStoreServerMock(t, http.StatusBadRequest)
+	defer serverMock.Close()
+	client := NewHttpDocStoreClient(http.DefaultClient, serverMock.URL)
+
+	found, err := client.ContentExists(context.Background(), "e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
+
+	assert.False(t, found)
+	assert.True(t, model.IsDependencyFailure(err))
+}
+
+func TestRevalidateContent(t *testing.T) {
+	serverMock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v2"`)
+		if r.Header.Get("If-None-Match") == `"v2"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(`{"uuid": "e1f02660-d41a-4a56-8eca-d0f8f0fac068"}`))
+	}))
+	defer serverMock.Close()
+	client := NewHttpDocStoreClient(http.DefaultClient, serverMock.URL)
+
+	content, modified, err := client.RevalidateContent(context.Background(), "e1f02660-d41a-4a56-8eca-d0f8f0fac068", `"v1"`, "tid_bh7VTFj9Il")
+	assert.NoError(t, err)
+	assert.True(t, modified)
+	assert.Equal(t, "e1f02660-d41a-4a56-8eca-d0f8f0fac068", content.UUID)
+	assert.Equal(t, `"v2"`, content.ETag)
+
+	content, modified, err = client.RevalidateContent(context.Background(), "e1f02660-d41a-4a56-8eca-d0f8f0fac068", `"v2"`, "tid_bh7VTFj9Il")
+	assert.NoError(t, err)
+	assert.False(t, modified)
+	assert.Nil(t, content)
+}
+
+func TestGetDocument_StatusOk(t *testing.T) {
+	var requestedPath string
+	serverMock := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		w.Write([]byte(`{"uuid": "e1f02660-d41a-4a56-8eca-d0f8f0fac068", "title": "Some title"}`))
+	}))
+	defer serverMock.Close()
+	client := NewHttpDocStoreClient(http.DefaultClient, serverMock.URL)
+
+	document, found, err := client.GetDocument(context.Background(), "complementarycontent", "e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
+
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "/complementarycontent/e1f02660-d41a-4a56-8eca-d0f8f0fac068", requestedPath)
+	assert.Equal(t, "Some title", document["title"])
+}
+
+func TestGetDocument_StatusNotFound(t *testing.T) {
+	serverMock := errorDocumentStoreServerMock(t, http.StatusNotFound)
+	defer serverMock.Close()
+	client := NewHttpDocStoreClient(http.DefaultClient, serverMock.URL)
+
+	_, found, err := client.GetDocument(context.Background(), "content", "e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
+
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestGetDocument_StatusBadRequest(t *testing.T) {
+	serverMock := errorDocumentStoreServerMock(t, http.StatusBadRequest)
+	defer serverMock.Close()
+	client := NewHttpDocStoreClient(http.DefaultClient, serverMock.URL)
+
+	_, _, err := client.GetDocument(context.Background(), "content", "e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
+
+	assert.True(t, model.IsDependencyFailure(err))
+}
+
+func TestGetContent_RetriesOnNextAddress(t *testing.T) {
+	failingServerMock := errorDocumentStoreServerMock(t, http.StatusServiceUnavailable)
+	defer failingServerMock.Close()
+	serverMock := successfulDocumentStoreServerMock(t, "document_store_content.json")
+	defer serverMock.Close()
+	client := NewHttpDocStoreClient(http.DefaultClient, failingServerMock.URL, serverMock.URL)
+
+	for i := 0; i < 2; i++ {
+		content, err := client.GetContent(context.Background(), "e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
+
+		assert.NoError(t, err, "The call should succeed on the healthy address whichever address it starts on")
+		assert.Equal(t, "abcf2660-bbad-4a56-8eca-d0f8f0fac068", content.GetUppCoreContent().UUID)
+	}
+}
+
+func TestGetContent_AllAddressesFail(t *testing.T) {
+	failingServerMock := errorDocumentStoreServerMock(t, http.StatusServiceUnavailable)
+	defer failingServerMock.Close()
+	unreachableServerMock := errorDocumentStoreServerMock(t, http.StatusServiceUnavailable)
+	unreachableServerMock.Close()
+	client := NewHttpDocStoreClient(http.DefaultClient, unreachableServerMock.URL, failingServerMock.URL)
+
+	_, err := client.GetContent(context.Background(), "e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
+
+	assert.Error(t, err)
+	assert.True(t, model.IsTransient(err))
+}
+
+func TestContentQuery_SpreadsCallsOverAddresses(t *testing.T) {
+	var calls [2]int
+	newServerMock := func(i int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls[i]++
+			w.WriteHeader(http.StatusNotFound)
+		}))
+	}
+	first, second := newServerMock(0), newServerMock(1)
+	defer first.Close()
+	defer second.Close()
+	client := NewHttpDocStoreClient(http.DefaultClient, first.URL, second.URL)
+
+	for i := 0; i < 4; i++ {
+		status, _, err := client.ContentQuery(context.Background(), "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", "tid_bh7VTFj9Il")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, status)
+	}
+	assert.Equal(t, [2]int{2, 2}, calls)
+}
+
+func TestCheckAddress_EjectsAndRestoresAddress(t *testing.T) {
+	gtgStatus := http.StatusServiceUnavailable
+	var contentCalls [2]int
+	newServerMock := func(i int, gtg *int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/__gtg" {
+				w.WriteHeader(*gtg)
+				return
+			}
+			contentCalls[i]++
+			w.WriteHeader(http.StatusNotFound)
+		}))
+	}
+	healthy := http.StatusOK
+	first, second := newServerMock(0, &gtgStatus), newServerMock(1, &healthy)
+	defer first.Close()
+	defer second.Close()
+	client := NewHttpDocStoreClient(http.DefaultClient, first.URL, second.URL)
+
+	_, err := client.CheckAddress(first.URL)
+	assert.Error(t, err)
+	assert.Equal(t, []DocStoreAddressStatus{{Address: first.URL, Healthy: false}, {Address: second.URL, Healthy: true}}, withoutCheckDetails(client.AddressStatuses()))
+	msg, err := client.ConnectivityCheck()
+	assert.NoError(t, err, "document-store-api is reachable while one of its addresses is")
+	assert.Equal(t, "OK", msg)
+
+	for i := 0; i < 2; i++ {
+		client.ContentExists(context.Background(), "e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
+	}
+	assert.Equal(t, [2]int{0, 2}, contentCalls, "An ejected address shouldn't be called")
+
+	gtgStatus = http.StatusOK
+	_, err = client.CheckAddress(first.URL)
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		client.ContentExists(context.Background(), "e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
+	}
+	assert.Equal(t, [2]int{1, 3}, contentCalls)
+}
+
+func TestConnectivityCheck_AllAddressesFail(t *testing.T) {
+	first := errorDocumentStoreServerMock(t, http.StatusServiceUnavailable)
+	defer first.Close()
+	second := errorDocumentStoreServerMock(t, http.StatusInternalServerError)
+	defer second.Close()
+	client := NewHttpDocStoreClient(http.DefaultClient, first.URL, second.URL)
+
+	_, err := client.ConnectivityCheck()
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), first.URL)
+	assert.Contains(t, err.Error(), second.URL)
+}
+
+func withoutCheckDetails(statuses []DocStoreAddressStatus) []DocStoreAddressStatus {
+	for i := range statuses {
+		statuses[i].LastChecked = time.Time{}
+		statuses[i].LastError = ""
+	}
+	return statuses
+}
+
+func TestGetContent_ContextDoneNotRetriedOnNextAddress(t *testing.T) {
+	var calls int32
+	newServerMock := func() *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			<-r.Context().Done()
+		}))
+	}
+	first, second := newServerMock(), newServerMock()
+	defer first.Close()
+	defer second.Close()
+	client := NewHttpDocStoreClient(http.DefaultClient, first.URL, second.URL)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := client.GetContent(ctx, "e1f02660-d41a-4a56-8eca-d0f8f0fac068", "tid_bh7VTFj9Il")
+
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.False(t, model.IsTransient(err), "A call past its deadline says nothing of document-store-api")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
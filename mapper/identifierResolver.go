@@ -1,10 +1,14 @@
 package mapper
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"regexp"
 	"strings"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	log "github.com/Sirupsen/logrus"
 )
 
 const (
@@ -15,54 +19,65 @@ const (
 var uuidRegex = regexp.MustCompile(uuidPattern)
 
 type IResolver interface {
-	ResolveIdentifier(serviceID, refField, tid string) (string, error)
-	ContentExists(uuid, tid string) (bool, error)
+	ResolveIdentifier(ctx context.Context, serviceID, refField, tid string) (string, error)
+	ContentExists(ctx context.Context, uuid, tid string) (bool, error)
 }
 
 type HTTPIResolver struct {
-	brandMappings map[string]string
+	brandMappings BrandMappingMatcher
 	client        DocStoreClient
 }
 
-func NewHttpIResolver(client DocStoreClient, brandMappings map[string]string) *HTTPIResolver {
+func NewHttpIResolver(client DocStoreClient, brandMappings BrandMappingMatcher) *HTTPIResolver {
 	return &HTTPIResolver{client: client, brandMappings: brandMappings}
 }
 
-func (r *HTTPIResolver) ResolveIdentifier(serviceID, refField, tid string) (string, error) {
-	mappingKey := strings.Split(serviceID, "?")[0]
-	mappingKey = strings.Split(mappingKey, "#")[0]
-	for key, value := range r.brandMappings {
-		if strings.Contains(mappingKey, key) {
-			authority := authorityPrefix + value
-			identifierValue := strings.Split(serviceID, "://")[0] + "://" + key + "/?p=" + refField
-			return r.resolveIdentifier(authority, identifierValue, tid)
-		}
+func (r *HTTPIResolver) ResolveIdentifier(ctx context.Context, serviceID, refField, tid string) (string, error) {
+	key, authority, identifierValue, err := BlogIdentifier(r.brandMappings, serviceID, refField)
+	if err != nil {
+		return "", model.WithStage(model.MappingStageBrandLookup, err)
 	}
-	return "", fmt.Errorf("couldn't find authority in mapping table serviceId=%v refField=%v", serviceID, refField)
+	log.WithField("transaction_id", tid).WithField("serviceId", serviceID).WithField("brand_mapping_key", key).Info("Matched brand mapping")
+
+	return r.resolveIdentifier(ctx, key, authority, identifierValue, tid)
 }
 
-func (r *HTTPIResolver) resolveIdentifier(authority string, identifier string, tid string) (string, error) {
-	status, location, err := r.client.ContentQuery(authority, identifier, tid)
+// BlogIdentifier returns the brand mapping key of a WordPress serviceId, and the authority and identifier its post is looked up with in document-store-api
+func BlogIdentifier(brandMappings BrandMappingMatcher, serviceID, refField string) (key string, authority string, identifier string, err error) {
+	key, value, err := brandMappings.Match(serviceID)
 	if err != nil {
-		return "", err
+		return "", "", "", fmt.Errorf("%v refField=%v", err.Error(), refField)
+	}
+	return key, authorityPrefix + value, strings.Split(serviceID, "://")[0] + "://" + key + "/?p=" + refField, nil
+}
+
+func (r *HTTPIResolver) resolveIdentifier(ctx context.Context, mappingKey, authority, identifier, tid string) (string, error) {
+	status, location, err := r.client.ContentQuery(ctx, authority, identifier, tid)
+	if err != nil {
+		return "", fmt.Errorf("brand mapping key=%v: %w", mappingKey, err)
+	}
+	if isTransientStatus(status) {
+		return "", model.NewTransientError(fmt.Sprintf("unexpected response code while fetching canonical identifier for mappingKey=%v authority=%v identifier=%v status=%v", mappingKey, authority, identifier, status))
+	}
+	if status == http.StatusNotFound {
+		return "", fmt.Errorf("unexpected response code while fetching canonical identifier for mappingKey=%v authority=%v identifier=%v status=%v", mappingKey, authority, identifier, status)
 	}
 	if status != http.StatusMovedPermanently {
-		return "", fmt.Errorf("unexpected response code while fetching canonical identifier for authority=%v identifier=%v status=%v", authority, identifier, status)
+		return "", model.NewDependencyError(fmt.Sprintf("unexpected response code while fetching canonical identifier for mappingKey=%v authority=%v identifier=%v status=%v", mappingKey, authority, identifier, status))
 	}
 
 	parts := strings.Split(location, "/")
 	if len(parts) < 2 {
-		return "", fmt.Errorf("resolved a canonical identifier which is an invalid FT URI for authority=%v identifier=%v location=%v", authority, identifier, location)
+		return "", model.NewDependencyError(fmt.Sprintf("resolved a canonical identifier which is an invalid FT URI for mappingKey=%v authority=%v identifier=%v location=%v", mappingKey, authority, identifier, location))
 	}
 	uuid := parts[len(parts)-1]
 	if !uuidRegex.MatchString(uuid) {
-		fmt.Println(parts)
-		return "", fmt.Errorf("resolved a canonical identifier which contains an invalid uuid for authority=%v identifier=%v uuid=%v", authority, identifier, uuid)
+		return "", model.NewDependencyError(fmt.Sprintf("resolved a canonical identifier which contains an invalid uuid for mappingKey=%v authority=%v identifier=%v uuid=%v", mappingKey, authority, identifier, uuid))
 	}
 
 	return uuid, nil
 }
 
-func (r *HTTPIResolver) ContentExists(uuid, tid string) (bool, error) {
-	return r.client.ContentExists(uuid, tid)
+func (r *HTTPIResolver) ContentExists(ctx context.Context, uuid, tid string) (bool, error) {
+	return r.client.ContentExists(ctx, uuid, tid)
 }
@@ -1,6 +1,7 @@
 package mapper
 
 import (
+	"context"
 	"net/http"
 	"strings"
 	"testing"
@@ -14,8 +15,8 @@ func TestResolve_Ok(t *testing.T) {
 	mockClient := new(model.MockDocStoreClient)
 	mockClient.On("ContentQuery", "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", "tid_1").Return(http.StatusMovedPermanently, "http://api.ft.com/content/5414b08f-5ae1-3bd6-9901-a9dd1bf9db03", nil)
 
-	resolver := NewHttpIResolver(mockClient, map[string]string{"ftalphaville.ft.com": "FT-LABS-WP-1-24"})
-	uuid, err := resolver.ResolveIdentifier("http://ftalphaville.ft.com/?p=2193913", "2193913", "tid_1")
+	resolver := NewHttpIResolver(mockClient, brandMappingTable(t, map[string]string{"ftalphaville.ft.com": "FT-LABS-WP-1-24"}))
+	uuid, err := resolver.ResolveIdentifier(context.Background(), "http://ftalphaville.ft.com/?p=2193913", "2193913", "tid_1")
 
 	assert.NoError(t, err, "Should resolve fine.")
 	assert.Equal(t, "5414b08f-5ae1-3bd6-9901-a9dd1bf9db03", uuid)
@@ -25,8 +26,8 @@ func TestResolve_NotInMap(t *testing.T) {
 	mockClient := new(model.MockDocStoreClient)
 	mockClient.On("ContentQuery", "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", "tid_1").Return(http.StatusMovedPermanently, "http://api.ft.com/content/5414b08f-5ae1-3bd6-9901-a9dd1bf9db03", nil)
 
-	resolver := NewHttpIResolver(mockClient, map[string]string{})
-	_, err := resolver.ResolveIdentifier("http://ftalphaville.ft.com/?p=2193913", "2193913", "tid_1")
+	resolver := NewHttpIResolver(mockClient, brandMappingTable(t, map[string]string{}))
+	_, err := resolver.ResolveIdentifier(context.Background(), "http://ftalphaville.ft.com/?p=2193913", "2193913", "tid_1")
 
 	assert.True(t, strings.Contains(err.Error(), "couldn't find authority in mapping table"))
 }
@@ -35,8 +36,8 @@ func TestResolve_InvalidUuid(t *testing.T) {
 	mockClient := new(model.MockDocStoreClient)
 	mockClient.On("ContentQuery", "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", "tid_1").Return(http.StatusMovedPermanently, "http://api.ft.com/content/5414b08f-xxxxx", nil)
 
-	resolver := NewHttpIResolver(mockClient, map[string]string{"ftalphaville.ft.com": "FT-LABS-WP-1-24"})
-	_, err := resolver.ResolveIdentifier("http://ftalphaville.ft.com/?p=2193913", "2193913", "tid_1")
+	resolver := NewHttpIResolver(mockClient, brandMappingTable(t, map[string]string{"ftalphaville.ft.com": "FT-LABS-WP-1-24"}))
+	_, err := resolver.ResolveIdentifier(context.Background(), "http://ftalphaville.ft.com/?p=2193913", "2193913", "tid_1")
 
 	assert.True(t, strings.Contains(err.Error(), "invalid uuid"))
 }
@@ -45,8 +46,8 @@ func TestResolve_InvalidLocation(t *testing.T) {
 	mockClient := new(model.MockDocStoreClient)
 	mockClient.On("ContentQuery", "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", "tid_1").Return(http.StatusMovedPermanently, "wrong", nil)
 
-	resolver := NewHttpIResolver(mockClient, map[string]string{"ftalphaville.ft.com": "FT-LABS-WP-1-24"})
-	_, err := resolver.ResolveIdentifier("http://ftalphaville.ft.com/?p=2193913", "2193913", "tid_1")
+	resolver := NewHttpIResolver(mockClient, brandMappingTable(t, map[string]string{"ftalphaville.ft.com": "FT-LABS-WP-1-24"}))
+	_, err := resolver.ResolveIdentifier(context.Background(), "http://ftalphaville.ft.com/?p=2193913", "2193913", "tid_1")
 
 	assert.True(t, strings.Contains(err.Error(), "invalid FT URI"))
 }
@@ -55,28 +56,39 @@ func TestResolve_NotFound(t *testing.T) {
 	mockClient := new(model.MockDocStoreClient)
 	mockClient.On("ContentQuery", "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", "tid_1").Return(http.StatusNotFound, "", nil)
 
-	resolver := NewHttpIResolver(mockClient, map[string]string{"ftalphaville.ft.com": "FT-LABS-WP-1-24"})
-	_, err := resolver.ResolveIdentifier("http://ftalphaville.ft.com/?p=2193913", "2193913", "tid_1")
+	resolver := NewHttpIResolver(mockClient, brandMappingTable(t, map[string]string{"ftalphaville.ft.com": "FT-LABS-WP-1-24"}))
+	_, err := resolver.ResolveIdentifier(context.Background(), "http://ftalphaville.ft.com/?p=2193913", "2193913", "tid_1")
 
 	assert.True(t, strings.Contains(err.Error(), "404"))
 }
 
+func TestResolve_ServerError(t *testing.T) {
+	mockClient := new(model.MockDocStoreClient)
+	mockClient.On("ContentQuery", "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", "tid_1").Return(http.StatusServiceUnavailable, "", nil)
+
+	resolver := NewHttpIResolver(mockClient, brandMappingTable(t, map[string]string{"ftalphaville.ft.com": "FT-LABS-WP-1-24"}))
+	_, err := resolver.ResolveIdentifier(context.Background(), "http://ftalphaville.ft.com/?p=2193913", "2193913", "tid_1")
+
+	assert.True(t, strings.Contains(err.Error(), "503"))
+	assert.True(t, model.IsTransient(err))
+}
+
 func TestResolve_NetFail(t *testing.T) {
 	mockClient := new(model.MockDocStoreClient)
 	mockClient.On("ContentQuery", "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", "tid_1").Return(-1, "", errors.New("Couldn't make HTTP call"))
 
-	resolver := NewHttpIResolver(mockClient, map[string]string{"ftalphaville.ft.com": "FT-LABS-WP-1-24"})
-	_, err := resolver.ResolveIdentifier("http://ftalphaville.ft.com/?p=2193913", "2193913", "tid_1")
+	resolver := NewHttpIResolver(mockClient, brandMappingTable(t, map[string]string{"ftalphaville.ft.com": "FT-LABS-WP-1-24"}))
+	_, err := resolver.ResolveIdentifier(context.Background(), "http://ftalphaville.ft.com/?p=2193913", "2193913", "tid_1")
 
-	assert.Equal(t, "Couldn't make HTTP call", err.Error())
+	assert.Equal(t, "brand mapping key=ftalphaville.ft.com: Couldn't make HTTP call", err.Error())
 }
 
 func TestContentExists_OK(t *testing.T) {
 	mockClient := new(model.MockDocStoreClient)
 	mockClient.On("ContentExists", "111", "tid_1").Return(true, nil)
 
-	resolver := NewHttpIResolver(mockClient, map[string]string{})
-	found, err := resolver.ContentExists("111", "tid_1")
+	resolver := NewHttpIResolver(mockClient, brandMappingTable(t, map[string]string{}))
+	found, err := resolver.ContentExists(context.Background(), "111", "tid_1")
 	assert.NoError(t, err)
 	assert.True(t, found)
 }
@@ -85,8 +97,52 @@ func TestContentExists_NOK(t *testing.T) {
 	mockClient := new(model.MockDocStoreClient)
 	mockClient.On("ContentExists", "111", "tid_1").Return(false, errors.New("any"))
 
-	resolver := NewHttpIResolver(mockClient, map[string]string{})
-	found, err := resolver.ContentExists("111", "tid_1")
+	resolver := NewHttpIResolver(mockClient, brandMappingTable(t, map[string]string{}))
+	found, err := resolver.ContentExists(context.Background(), "111", "tid_1")
 	assert.Error(t, err)
 	assert.False(t, found)
 }
+
+func TestResolve_MostSpecificMappingWins(t *testing.T) {
+	mockClient := new(model.MockDocStoreClient)
+	mockClient.On("ContentQuery", "http://api.ft.com/system/FT-LABS-WP-1-335", "http://www.ft.com/fastft/?p=2193913", "tid_1").Return(http.StatusMovedPermanently, "http://api.ft.com/content/5414b08f-5ae1-3bd6-9901-a9dd1bf9db03", nil)
+
+	resolver := NewHttpIResolver(mockClient, brandMappingTable(t, map[string]string{"www.ft.com": "FT-LABS-WP-1-1", "www.ft.com/fastft": "FT-LABS-WP-1-335"}))
+	uuid, err := resolver.ResolveIdentifier(context.Background(), "http://www.ft.com/fastft/2017/10/12/some-post/?p=2193913", "2193913", "tid_1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "5414b08f-5ae1-3bd6-9901-a9dd1bf9db03", uuid)
+}
+
+func TestResolve_ErrorReportsMappingKey(t *testing.T) {
+	mockClient := new(model.MockDocStoreClient)
+	mockClient.On("ContentQuery", "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", "tid_1").Return(http.StatusNotFound, "", nil)
+
+	resolver := NewHttpIResolver(mockClient, brandMappingTable(t, map[string]string{"ftalphaville.ft.com": "FT-LABS-WP-1-24"}))
+	_, err := resolver.ResolveIdentifier(context.Background(), "http://ftalphaville.ft.com/?p=2193913", "2193913", "tid_1")
+
+	assert.Contains(t, err.Error(), "mappingKey=ftalphaville.ft.com")
+}
+
+func brandMappingTable(t *testing.T, brandMappings map[string]string) *BrandMappingTable {
+	table, err := NewBrandMappingTable(brandMappings)
+	assert.NoError(t, err)
+	return table
+}
+
+func TestResolve_UnknownBrandIsBrandLookupFailure(t *testing.T) {
+	resolver := NewHttpIResolver(new(model.MockDocStoreClient), brandMappingTable(t, map[string]string{"ftalphaville.ft.com": "FT-LABS-WP-1-24"}))
+	_, err := resolver.ResolveIdentifier(context.Background(), "http://blogs.ft.com/the-world/?p=2193913", "2193913", "tid_1")
+
+	assert.Equal(t, model.MappingStageBrandLookup, model.StageOf(err))
+}
+
+func TestResolve_UnexpectedStatusIsDependencyFailure(t *testing.T) {
+	mockClient := new(model.MockDocStoreClient)
+	mockClient.On("ContentQuery", "http://api.ft.com/system/FT-LABS-WP-1-24", "http://ftalphaville.ft.com/?p=2193913", "tid_1").Return(http.StatusBadRequest, "", nil)
+
+	resolver := NewHttpIResolver(mockClient, brandMappingTable(t, map[string]string{"ftalphaville.ft.com": "FT-LABS-WP-1-24"}))
+	_, err := resolver.ResolveIdentifier(context.Background(), "http://ftalphaville.ft.com/?p=2193913", "2193913", "tid_1")
+
+	assert.True(t, model.IsDependencyFailure(err))
+}
@@ -19,10 +19,14 @@ type MessageToContentPlaceholderMapper interface {
 type DefaultMessageMapper struct {
 }
 
+// Map returns a *model.ValidationError reporting all the parts of the message which couldn't be decoded,
+// or a *model.InvalidMethodeCPH if the message is not a content placeholder
 func (m DefaultMessageMapper) Map(messageBody []byte) (*model.MethodeContentPlaceholder, error) {
+	report := model.ValidationReport{}
 	var p model.MethodeContentPlaceholder
 	if err := json.Unmarshal(messageBody, &p); err != nil {
-		return nil, fmt.Errorf("error unmarshalling methode messageBody: %v", err)
+		report.AddError("$", model.CodeMalformedJSON, fmt.Sprintf("error unmarshalling methode messageBody: %v", err))
+		return nil, report.Err()
 	}
 	if p.Type != eomCompoundStory {
 		return nil, model.NewInvalidMethodeCPH(fmt.Sprintf("Methode content has not type %s", eomCompoundStory))
@@ -30,20 +34,22 @@ func (m DefaultMessageMapper) Map(messageBody []byte) (*model.MethodeContentPlac
 
 	attrs, err := buildAttributes(p.AttributesXML)
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshalling not build xml attributes: %v", err.Error())
-	}
-	p.Attributes = attrs
-
-	if p.Attributes.SourceCode != contentPlaceholderSourceCode {
+		report.AddError("attributes", model.CodeMalformedXML, fmt.Sprintf("error unmarshalling xml attributes: %v", err.Error()))
+	} else if attrs.SourceCode != contentPlaceholderSourceCode {
 		return nil, model.NewInvalidMethodeCPH("Methode content is not a content placeholder")
 	}
+	p.Attributes = attrs
 
-	body, err := buildMethodeBody(p.Value)
+	methodeBodyXML, err := base64.StdEncoding.DecodeString(p.Value)
 	if err != nil {
-		return nil, fmt.Errorf("error decoding or unmarshalling methode body: %v", err.Error())
+		report.AddError("value", model.CodeMalformedBase64, fmt.Sprintf("error decoding methode body: %v", err.Error()))
+	} else if err := xml.Unmarshal(methodeBodyXML, &p.Body); err != nil {
+		report.AddError("value", model.CodeMalformedXML, fmt.Sprintf("error unmarshalling methode body: %v", err.Error()))
 	}
-	p.Body = body
 
+	if err := report.Err(); err != nil {
+		return nil, err
+	}
 	return &p, nil
 }
 
@@ -54,15 +60,3 @@ func buildAttributes(attributesXML string) (model.Attributes, error) {
 	}
 	return attrs, nil
 }
-
-func buildMethodeBody(methodeBodyXMLBase64 string) (model.MethodeBody, error) {
-	methodeBodyXML, err := base64.StdEncoding.DecodeString(methodeBodyXMLBase64)
-	if err != nil {
-		return model.MethodeBody{}, err
-	}
-	var body model.MethodeBody
-	if err := xml.Unmarshal(methodeBodyXML, &body); err != nil {
-		return model.MethodeBody{}, err
-	}
-	return body, nil
-}
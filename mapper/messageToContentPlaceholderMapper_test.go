@@ -65,6 +65,30 @@ func TestMessageMapperMapWrongSourceCode_ThrowsError(t *testing.T) {
 	assert.Error(t, err, "An error should thrown on wrong type for methode message.")
 }
 
+func TestMessageMapperMapMalformedAttributesAndBody_ReportsBoth(t *testing.T) {
+	defaultMessageMappper := DefaultMessageMapper{}
+
+	_, err := defaultMessageMappper.Map([]byte(`{"type": "EOM::CompoundStory", "attributes": "<ObjectMetadata>", "value": "not base64!"}`))
+
+	validationErr, ok := model.AsValidationError(err)
+	assert.True(t, ok, "A validation error should be returned")
+	assert.Len(t, validationErr.Report.Issues, 2)
+	assert.Equal(t, "attributes", validationErr.Report.Issues[0].Field)
+	assert.Equal(t, model.CodeMalformedXML, validationErr.Report.Issues[0].Code)
+	assert.Equal(t, "value", validationErr.Report.Issues[1].Field)
+	assert.Equal(t, model.CodeMalformedBase64, validationErr.Report.Issues[1].Code)
+}
+
+func TestMessageMapperMapMalformedJSON_ThrowsValidationError(t *testing.T) {
+	defaultMessageMappper := DefaultMessageMapper{}
+
+	_, err := defaultMessageMappper.Map([]byte(`{"type": `))
+
+	validationErr, ok := model.AsValidationError(err)
+	assert.True(t, ok, "A validation error should be returned")
+	assert.Equal(t, model.CodeMalformedJSON, validationErr.Report.Issues[0].Code)
+}
+
 func jsonStringToMap(jsonString []byte) map[string]interface{} {
 	var jsonMap map[string]interface{}
 	json.Unmarshal(jsonString, &jsonMap)
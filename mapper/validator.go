@@ -1,14 +1,15 @@
 package mapper
 
 import (
-	"errors"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
 )
 
 type CPHValidator interface {
-	Validate(mcp *model.MethodeContentPlaceholder) error
+	Validate(mcp *model.MethodeContentPlaceholder) model.ValidationReport
 }
 
 type defaultCPHValidator struct {
@@ -18,23 +19,60 @@ func NewDefaultCPHValidator() *defaultCPHValidator {
 	return &defaultCPHValidator{}
 }
 
-func (dcv *defaultCPHValidator) Validate(mcp *model.MethodeContentPlaceholder) error {
-	return dcv.validateHeadline(mcp.Body.LeadHeadline)
+// Validate collects all the problems of the placeholder instead of stopping at the first one
+func (dcv *defaultCPHValidator) Validate(mcp *model.MethodeContentPlaceholder) model.ValidationReport {
+	report := model.ValidationReport{}
+	dcv.validateHeadline(mcp.Body.LeadHeadline, &report)
+	if !mcp.Attributes.IsDeleted {
+		dcv.validateLeadImage(mcp.Body.LeadImage, &report)
+	}
+	return report
 }
 
-func (dcv *defaultCPHValidator) validateHeadline(headline model.LeadHeadline) error {
+func (dcv *defaultCPHValidator) validateHeadline(headline model.LeadHeadline, report *model.ValidationReport) {
 	if headline.Text == "" {
-		return errors.New("Methode Content headline does not contain text")
+		report.AddError("body.leadHeadline.text", model.CodeMissingHeadlineText, "Methode Content headline does not contain text")
 	}
 	if headline.URL == "" {
-		return errors.New("Methode Content headline does not contain a link")
+		report.AddError("body.leadHeadline.url", model.CodeMissingHeadlineURL, "Methode Content headline does not contain a link")
+		return
 	}
 	headlineURL, err := url.Parse(headline.URL)
 	if err != nil {
-		return errors.New("Methode Content headline does not contain a valid URL - " + err.Error())
+		report.AddError("body.leadHeadline.url", model.CodeInvalidHeadlineURL, "Methode Content headline does not contain a valid URL - "+err.Error())
+		return
 	}
 	if !headlineURL.IsAbs() {
-		return errors.New("Methode Content headline does not contain an absolute URL")
+		report.AddError("body.leadHeadline.url", model.CodeRelativeHeadlineURL, "Methode Content headline does not contain an absolute URL")
+	}
+}
+
+// validatePublicationDate reports a publication date that can't be mapped to the published date of an external placeholder
+func validatePublicationDate(mcp *model.MethodeContentPlaceholder, report *model.ValidationReport) {
+	if _, err := time.Parse(methodeDateFormat, mcp.Attributes.LastPublicationDate); err != nil {
+		report.AddError("attributes.lastPublicationDate", model.CodeInvalidPublicationDate, "Methode Content publication date is not in the "+methodeDateFormat+" format")
+	}
+}
+
+func (dcv *defaultCPHValidator) validateLeadImage(leadImage model.LeadImage, report *model.ValidationReport) {
+	if leadImage.FileRef == "" {
+		return
+	}
+	if !strings.Contains(leadImage.FileRef, "uuid=") {
+		report.AddError("body.leadImage.fileref", model.CodeMalformedLeadImage, "Methode Content lead image fileref does not contain an image uuid")
+		return
+	}
+	if !uuidRegex.MatchString(extractImageUUID(leadImage.FileRef)) {
+		report.AddWarning("body.leadImage.fileref", model.CodeInvalidLeadImageUUID, "Methode Content lead image fileref does not contain a valid uuid")
+	}
+}
+
+// validateBlogAttributes reports the attributes missing to resolve a blog post
+func validateBlogAttributes(mcp *model.MethodeContentPlaceholder, report *model.ValidationReport) {
+	if mcp.Attributes.ServiceId == "" {
+		report.AddError("attributes.serviceId", model.CodeMissingBlogAttribute, "blog attribute ServiceId should not be empty")
+	}
+	if mcp.Attributes.RefField == "" {
+		report.AddError("attributes.refField", model.CodeMissingBlogAttribute, "blog attribute ref_field should not be empty")
 	}
-	return nil
 }
@@ -11,6 +11,9 @@ func TestValidatorHeadline_Ok(t *testing.T) {
 	defaultValidator := defaultCPHValidator{}
 
 	mcp := &model.MethodeContentPlaceholder{
+		Attributes: model.Attributes{
+			LastPublicationDate: "20140805134048",
+		},
 		Body: model.MethodeBody{
 			LeadHeadline: model.LeadHeadline{
 				Text: "some lead headline",
@@ -19,7 +22,7 @@ func TestValidatorHeadline_Ok(t *testing.T) {
 		},
 	}
 
-	err := defaultValidator.Validate(mcp)
+	err := defaultValidator.Validate(mcp).Err()
 
 	assert.NoError(t, err, "No error should be thrown for valid lead headline.")
 }
@@ -35,7 +38,7 @@ func TestValidatorHeadlineMissingText_ThrowsError(t *testing.T) {
 		},
 	}
 
-	err := defaultValidator.Validate(mcp)
+	err := defaultValidator.Validate(mcp).Err()
 
 	assert.Error(t, err, "Error should be thrown for missing text in lead headline.")
 }
@@ -52,7 +55,7 @@ func TestValidatorHeadlineEmptyText_ThrowsError(t *testing.T) {
 		},
 	}
 
-	err := defaultValidator.Validate(mcp)
+	err := defaultValidator.Validate(mcp).Err()
 
 	assert.Error(t, err, "Error should be thrown for empty text in lead headline.")
 }
@@ -68,7 +71,7 @@ func TestValidatorHeadlineMissingURL_ThrowsError(t *testing.T) {
 		},
 	}
 
-	err := defaultValidator.Validate(mcp)
+	err := defaultValidator.Validate(mcp).Err()
 
 	assert.Error(t, err, "Error should be thrown for missing URL in lead headline.")
 }
@@ -85,7 +88,7 @@ func TestValidatorHeadlineEmptyURL_ThrowsError(t *testing.T) {
 		},
 	}
 
-	err := defaultValidator.Validate(mcp)
+	err := defaultValidator.Validate(mcp).Err()
 
 	assert.Error(t, err, "Error should be thrown for empty URL in lead headline.")
 }
@@ -102,7 +105,7 @@ func TestValidatorHeadlineRelativeURL_ThrowsError(t *testing.T) {
 		},
 	}
 
-	err := defaultValidator.Validate(mcp)
+	err := defaultValidator.Validate(mcp).Err()
 
 	assert.Error(t, err, "Error should be thrown for relative URL in lead headline.")
 }
@@ -119,7 +122,82 @@ func TestValidatorHeadlineInvalidURL_ThrowsError(t *testing.T) {
 		},
 	}
 
-	err := defaultValidator.Validate(mcp)
+	err := defaultValidator.Validate(mcp).Err()
 
 	assert.Error(t, err, "Error should be thrown for invalid URL in lead headline.")
 }
+
+func TestValidator_ReportsAllProblems(t *testing.T) {
+	defaultValidator := defaultCPHValidator{}
+
+	mcp := &model.MethodeContentPlaceholder{
+		Attributes: model.Attributes{
+			LastPublicationDate: "2014-08-05",
+		},
+		Body: model.MethodeBody{
+			LeadHeadline: model.LeadHeadline{
+				URL: "/content/e1f02660-d41a-4a56-8eca-d0f8f0fac068",
+			},
+			LeadImage: model.LeadImage{
+				FileRef: "/FT/Graphics/Online/Master_2048x1152/2017/10/img.jpg",
+			},
+		},
+	}
+
+	report := defaultValidator.Validate(mcp)
+
+	var codes []string
+	for _, issue := range report.Issues {
+		assert.Equal(t, model.SeverityError, issue.Severity)
+		codes = append(codes, issue.Code)
+	}
+	assert.Equal(t, []string{model.CodeMissingHeadlineText, model.CodeRelativeHeadlineURL, model.CodeMalformedLeadImage}, codes)
+}
+
+func TestValidator_WarningsDoNotFailValidation(t *testing.T) {
+	defaultValidator := defaultCPHValidator{}
+
+	mcp := &model.MethodeContentPlaceholder{
+		Attributes: model.Attributes{
+			LastPublicationDate: "20140805134048",
+		},
+		Body: model.MethodeBody{
+			LeadHeadline: model.LeadHeadline{
+				Text: "some lead headline",
+				URL:  "https://www.ft.com/content/e1f02660-d41a-4a56-8eca-d0f8f0fac068",
+			},
+			LeadImage: model.LeadImage{
+				FileRef: "/FT/Graphics/Online/Master_2048x1152/2017/10/img.jpg?uuid=not-a-uuid",
+			},
+		},
+	}
+
+	report := defaultValidator.Validate(mcp)
+
+	assert.False(t, report.HasErrors())
+	assert.Equal(t, []model.ValidationIssue{{
+		Field:    "body.leadImage.fileref",
+		Code:     model.CodeInvalidLeadImageUUID,
+		Severity: model.SeverityWarning,
+		Message:  "Methode Content lead image fileref does not contain a valid uuid",
+	}}, report.Warnings())
+}
+
+func TestValidator_DeletedPlaceholderSkipsBodyChecks(t *testing.T) {
+	defaultValidator := defaultCPHValidator{}
+
+	mcp := &model.MethodeContentPlaceholder{
+		Attributes: model.Attributes{
+			IsDeleted: true,
+		},
+		Body: model.MethodeBody{
+			LeadHeadline: model.LeadHeadline{
+				Text: "some lead headline",
+				URL:  "https://www.ft.com/content/e1f02660-d41a-4a56-8eca-d0f8f0fac068",
+			},
+		},
+	}
+
+	report := defaultValidator.Validate(mcp)
+	assert.Empty(t, report.Issues)
+}
@@ -0,0 +1,115 @@
+package message
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+)
+
+// Formats of the produced messages accepted by NewMessageCreator
+const (
+	FTMessageFormat                    = "ft"
+	CloudEventsStructuredMessageFormat = "cloudevents-structured"
+	CloudEventsBinaryMessageFormat     = "cloudevents-binary"
+)
+
+const (
+	cloudEventsSpecVersion = "1.0"
+	cloudEventsContentType = "application/cloudevents+json"
+	// cloudEventsSource is the source of every event, the Methode system the content was published from
+	cloudEventsSource         = model.MethodeSystemID
+	contentPublishedEventType = "com.ft.upp.content.published"
+	contentDeletedEventType   = "com.ft.upp.content.deleted"
+	cloudEventsHeaderPrefix   = "ce-"
+)
+
+// cloudEvent is a CloudEvents 1.0 event in structured mode
+type cloudEvent struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Subject         string      `json:"subject"`
+	Time            string      `json:"time,omitempty"`
+	DataContentType string      `json:"datacontenttype"`
+	Data            interface{} `json:"data"`
+}
+
+// CloudEventsMessageCreator creates CloudEvents 1.0 messages whose data is the publication event created by CPHMessageCreator.
+// In structured mode the whole event is the body of the message; in binary mode the event attributes are ce- headers
+// and the body is the publication event. The FT message headers are kept in both modes.
+type CloudEventsMessageCreator struct {
+	*CPHMessageCreator
+	binary bool
+}
+
+// NewCloudEventsMessageCreator returns a creator of structured mode events, or of binary mode events if binary is set
+func NewCloudEventsMessageCreator(passthroughHeaders []string, binary bool) *CloudEventsMessageCreator {
+	return &CloudEventsMessageCreator{CPHMessageCreator: NewCPHMessageCreator(passthroughHeaders), binary: binary}
+}
+
+// NewMessageCreator returns the creator of the messages in the given format
+func NewMessageCreator(format string, passthroughHeaders []string) (MessageCreator, error) {
+	switch format {
+	case FTMessageFormat:
+		return NewCPHMessageCreator(passthroughHeaders), nil
+	case CloudEventsStructuredMessageFormat:
+		return NewCloudEventsMessageCreator(passthroughHeaders, false), nil
+	case CloudEventsBinaryMessageFormat:
+		return NewCloudEventsMessageCreator(passthroughHeaders, true), nil
+	}
+	return nil, fmt.Errorf("unknown message format %q, should be %v, %v or %v", format, FTMessageFormat, CloudEventsStructuredMessageFormat, CloudEventsBinaryMessageFormat)
+}
+
+func (cec *CloudEventsMessageCreator) ToPublicationEventMessage(coreAttributes *model.UppCoreContent, payload interface{}, nativeHeaders map[string]string) (*producer.Message, error) {
+	publicationEvent := cec.ToPublicationEvent(coreAttributes, payload)
+	headers := cec.headers(coreAttributes, nativeHeaders)
+	event := cloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		ID:              headers["Message-Id"],
+		Source:          cloudEventsSource,
+		Type:            contentPublishedEventType,
+		Subject:         coreAttributes.UUID,
+		Time:            cloudEventsTime(coreAttributes.LastModified),
+		DataContentType: "application/json",
+		Data:            publicationEvent,
+	}
+	if coreAttributes.IsMarkedDeleted {
+		event.Type = contentDeletedEventType
+	}
+
+	if cec.binary {
+		body, err := json.Marshal(publicationEvent)
+		if err != nil {
+			return nil, err
+		}
+		headers[cloudEventsHeaderPrefix+"specversion"] = event.SpecVersion
+		headers[cloudEventsHeaderPrefix+"id"] = event.ID
+		headers[cloudEventsHeaderPrefix+"source"] = event.Source
+		headers[cloudEventsHeaderPrefix+"type"] = event.Type
+		headers[cloudEventsHeaderPrefix+"subject"] = event.Subject
+		if event.Time != "" {
+			headers[cloudEventsHeaderPrefix+"time"] = event.Time
+		}
+		return &producer.Message{Headers: headers, Body: string(body)}, nil
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	headers["Content-Type"] = cloudEventsContentType
+	return &producer.Message{Headers: headers, Body: string(body)}, nil
+}
+
+// cloudEventsTime converts the UPP last modified date, which has no colon in its zone offset, to RFC 3339
+func cloudEventsTime(lastModified string) string {
+	t, err := time.Parse(model.UPPDateFormat, lastModified)
+	if err != nil {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,81 @@
+package message
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func cloudEventsCoreContent() *model.UppCoreContent {
+	return &model.UppCoreContent{
+		UUID:             "512c1f3d-e48c-4618-863c-94bc9d913b9b",
+		PublishReference: "tid_test123",
+		LastModified:     "2017-05-15T15:54:32.166Z",
+		ContentURI:       "http://methode-content-placeholder-mapper-iw-uk-p.svc.ft.com/content/",
+	}
+}
+
+func TestCloudEventsStructured_Ok(t *testing.T) {
+	messageCreator := NewCloudEventsMessageCreator(nil, false)
+	nativeHeaders := map[string]string{"Message-Id": "7c4b9e60-3a8c-4b8f-9cbb-0e4a2f1c1a11"}
+
+	msg, err := messageCreator.ToPublicationEventMessage(cloudEventsCoreContent(), model.UppContentPlaceholder{Title: "Some title"}, nativeHeaders)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "application/cloudevents+json", msg.Headers["Content-Type"])
+	assert.Equal(t, "tid_test123", msg.Headers["X-Request-Id"])
+	var event map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(msg.Body), &event))
+	assert.Equal(t, "1.0", event["specversion"])
+	assert.Equal(t, OutboundMessageID("7c4b9e60-3a8c-4b8f-9cbb-0e4a2f1c1a11", "content"), event["id"])
+	assert.Equal(t, msg.Headers["Message-Id"], event["id"])
+	assert.Equal(t, model.MethodeSystemID, event["source"])
+	assert.Equal(t, "com.ft.upp.content.published", event["type"])
+	assert.Equal(t, "512c1f3d-e48c-4618-863c-94bc9d913b9b", event["subject"])
+	assert.Equal(t, "2017-05-15T15:54:32.166Z", event["time"])
+	assert.Equal(t, "application/json", event["datacontenttype"])
+	data := event["data"].(map[string]interface{})
+	assert.Equal(t, "http://methode-content-placeholder-mapper-iw-uk-p.svc.ft.com/content/512c1f3d-e48c-4618-863c-94bc9d913b9b", data["contentUri"])
+	assert.Equal(t, "Some title", data["payload"].(map[string]interface{})["title"])
+}
+
+func TestCloudEventsBinary_Ok(t *testing.T) {
+	messageCreator := NewCloudEventsMessageCreator(nil, true)
+	coreContent := cloudEventsCoreContent()
+	coreContent.IsMarkedDeleted = true
+
+	msg, err := messageCreator.ToPublicationEventMessage(coreContent, model.UppContentPlaceholder{}, map[string]string{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "application/json", msg.Headers["Content-Type"])
+	assert.Equal(t, "1.0", msg.Headers["ce-specversion"])
+	assert.Equal(t, msg.Headers["Message-Id"], msg.Headers["ce-id"])
+	assert.Equal(t, model.MethodeSystemID, msg.Headers["ce-source"])
+	assert.Equal(t, "com.ft.upp.content.deleted", msg.Headers["ce-type"])
+	assert.Equal(t, "512c1f3d-e48c-4618-863c-94bc9d913b9b", msg.Headers["ce-subject"])
+	assert.Equal(t, "2017-05-15T15:54:32.166Z", msg.Headers["ce-time"])
+	var publicationEvent map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(msg.Body), &publicationEvent))
+	assert.Equal(t, "http://methode-content-placeholder-mapper-iw-uk-p.svc.ft.com/content/512c1f3d-e48c-4618-863c-94bc9d913b9b", publicationEvent["contentUri"])
+	assert.NotContains(t, publicationEvent, "payload")
+}
+
+func TestCloudEventsTime_ConvertsZoneOffset(t *testing.T) {
+	assert.Equal(t, "2017-05-15T15:54:32.166+01:00", cloudEventsTime("2017-05-15T15:54:32.166+0100"))
+	assert.Equal(t, "", cloudEventsTime(""))
+}
+
+func TestNewMessageCreator(t *testing.T) {
+	messageCreator, err := NewMessageCreator("ft", nil)
+	assert.NoError(t, err)
+	assert.IsType(t, &CPHMessageCreator{}, messageCreator)
+
+	messageCreator, err = NewMessageCreator("cloudevents-binary", nil)
+	assert.NoError(t, err)
+	assert.IsType(t, &CloudEventsMessageCreator{}, messageCreator)
+
+	_, err = NewMessageCreator("avro", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,31 @@
+package message
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	"github.com/satori/go.uuid"
+)
+
+const deadLetterMessageType = "cms-content-placeholder-failed"
+
+func ToDeadLetterMessage(event *model.DeadLetterEvent) (*producer.Message, error) {
+	jsonEvent, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string]string{
+		"X-Request-Id":      event.TransactionID,
+		"Message-Timestamp": time.Now().Format(model.UPPDateFormat),
+		"Message-Id":        uuid.NewV4().String(),
+		"Message-Type":      deadLetterMessageType,
+		"Content-Type":      "application/json",
+		"Origin-System-Id":  model.MethodeSystemID,
+		"Failure-Stage":     event.Stage,
+	}
+
+	return &producer.Message{Headers: headers, Body: string(jsonEvent)}, nil
+}
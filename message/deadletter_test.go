@@ -0,0 +1,35 @@
+package message
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToDeadLetterMessage_Ok(t *testing.T) {
+	event := &model.DeadLetterEvent{
+		Stage:         "content-mapping",
+		Error:         "Methode Content headline does not contain text",
+		TransactionID: "tid_test123",
+		FailedAt:      "2017-05-15T15:54:32.166Z",
+		OriginalMessage: model.NativeMessage{
+			Headers: map[string]string{"X-Request-Id": "tid_test123", "Message-Id": "e7b8b8a5-5e1e-4e55-a5a4-9c4b3a7e3d1a"},
+			Body:    "{\"uuid\":\"512c1f3d-e48c-4618-863c-94bc9d913b9b\"}",
+		},
+	}
+
+	msg, err := ToDeadLetterMessage(event)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "tid_test123", msg.Headers["X-Request-Id"])
+	assert.Equal(t, "content-mapping", msg.Headers["Failure-Stage"])
+	assert.Equal(t, deadLetterMessageType, msg.Headers["Message-Type"])
+	assert.NotEmpty(t, msg.Headers["Message-Id"])
+
+	var actualEvent model.DeadLetterEvent
+	err = json.Unmarshal([]byte(msg.Body), &actualEvent)
+	assert.NoError(t, err)
+	assert.Equal(t, *event, actualEvent)
+}
@@ -2,6 +2,7 @@ package message
 
 import (
 	"encoding/json"
+	"strings"
 	"time"
 
 	"github.com/Financial-Times/message-queue-go-producer/producer"
@@ -9,19 +10,32 @@ import (
 	"github.com/satori/go.uuid"
 )
 
+// messageIDNamespace is the namespace of the name-based UUIDs used as the Message-Id of the produced messages
+var messageIDNamespace = uuid.FromStringOrNil("9b5e0fd4-5c41-4e0b-8f43-a7a3c9d0a1f6")
+
 type MessageCreator interface {
-	ToPublicationEventMessage(coreAttributes *model.UppCoreContent, payload interface{}) (*producer.Message, error)
+	// ToPublicationEventMessage creates the message publishing the content mapped from the native message with the given headers
+	ToPublicationEventMessage(coreAttributes *model.UppCoreContent, payload interface{}, nativeHeaders map[string]string) (*producer.Message, error)
 	ToPublicationEvent(coreAttributes *model.UppCoreContent, payload interface{}) *model.PublicationEvent
 }
 
+// CausationIDHeader carries the Message-Id of the native message a produced message was mapped from
+const CausationIDHeader = "Causation-Id"
+
 type CPHMessageCreator struct {
+	passthroughHeaders []string
 }
 
 func NewDefaultCPHMessageCreator() *CPHMessageCreator {
 	return &CPHMessageCreator{}
 }
 
-func (cmc *CPHMessageCreator) ToPublicationEventMessage(coreAttributes *model.UppCoreContent, payload interface{}) (*producer.Message, error) {
+// NewCPHMessageCreator returns a message creator copying the given headers of the native message to the produced messages
+func NewCPHMessageCreator(passthroughHeaders []string) *CPHMessageCreator {
+	return &CPHMessageCreator{passthroughHeaders: passthroughHeaders}
+}
+
+func (cmc *CPHMessageCreator) ToPublicationEventMessage(coreAttributes *model.UppCoreContent, payload interface{}, nativeHeaders map[string]string) (*producer.Message, error) {
 	publicationEvent := cmc.ToPublicationEvent(coreAttributes, payload)
 
 	jsonPublicationEvent, err := json.Marshal(publicationEvent)
@@ -29,16 +43,25 @@ func (cmc *CPHMessageCreator) ToPublicationEventMessage(coreAttributes *model.Up
 		return nil, err
 	}
 
+	headers := cmc.headers(coreAttributes, nativeHeaders)
+	return &producer.Message{Headers: headers, Body: string(jsonPublicationEvent)}, nil
+}
+
+// headers returns the FT message headers of the message publishing the content
+func (cmc *CPHMessageCreator) headers(coreAttributes *model.UppCoreContent, nativeHeaders map[string]string) map[string]string {
 	headers := map[string]string{
 		"X-Request-Id":      coreAttributes.PublishReference,
-		"Message-Timestamp": time.Now().Format(model.UPPDateFormat),
-		"Message-Id":        uuid.NewV4().String(),
+		"Message-Timestamp": messageTimestamp(nativeHeaders),
+		"Message-Id":        OutboundMessageID(nativeHeaders["Message-Id"], collectionOf(coreAttributes.ContentURI)),
 		"Message-Type":      "cms-content-published",
 		"Content-Type":      "application/json",
 		"Origin-System-Id":  model.MethodeSystemID,
 	}
-
-	return &producer.Message{Headers: headers, Body: string(jsonPublicationEvent)}, nil
+	if causationID := nativeHeaders["Message-Id"]; causationID != "" {
+		headers[CausationIDHeader] = causationID
+	}
+	addPassthroughHeaders(headers, nativeHeaders, cmc.passthroughHeaders)
+	return headers
 }
 
 func (cmc *CPHMessageCreator) ToPublicationEvent(coreAttributes *model.UppCoreContent, payload interface{}) *model.PublicationEvent {
@@ -54,3 +77,51 @@ func (cmc *CPHMessageCreator) ToPublicationEvent(coreAttributes *model.UppCoreCo
 		LastModified: coreAttributes.LastModified,
 	}
 }
+
+// OutboundMessageID derives the Message-Id of a produced message from the Message-Id of the native message and the collection
+// the content is published to, so that processing the same native message again produces messages downstream consumers can de-duplicate.
+// A random id is returned when the native message has no Message-Id.
+func OutboundMessageID(nativeMessageID, collection string) string {
+	if nativeMessageID == "" {
+		return uuid.NewV4().String()
+	}
+	return uuid.NewV5(messageIDNamespace, nativeMessageID+"/"+collection).String()
+}
+
+// collectionOf returns the last path segment of a content URI like http://host/complementarycontent/
+func collectionOf(contentURI string) string {
+	path := strings.TrimSuffix(contentURI, "/")
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// addPassthroughHeaders copies the allowed headers of the native message, whatever the case of their names,
+// unless they would replace one of the headers already set
+func addPassthroughHeaders(headers, nativeHeaders map[string]string, allowed []string) {
+	for _, name := range allowed {
+		if hasHeader(headers, name) {
+			continue
+		}
+		for nativeName, value := range nativeHeaders {
+			if strings.EqualFold(name, nativeName) {
+				headers[nativeName] = value
+			}
+		}
+	}
+}
+
+func hasHeader(headers map[string]string, name string) bool {
+	for existing := range headers {
+		if strings.EqualFold(existing, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// messageTimestamp keeps the Message-Timestamp of the native message, so the produced messages carry the time of the Methode publish
+func messageTimestamp(nativeHeaders map[string]string) string {
+	if timestamp := nativeHeaders["Message-Timestamp"]; timestamp != "" {
+		return timestamp
+	}
+	return time.Now().Format(model.UPPDateFormat)
+}
@@ -145,10 +145,10 @@ func TestToPublicationEventMsgUpdate_Ok(t *testing.T) {
 	compContentPubEventMarshalled, _ := json.Marshal(expectedCompContentPubEvent)
 	expectedCompContentPubEventMsg := &producer.Message{Headers: headers, Body: string(compContentPubEventMarshalled)}
 
-	actualCPHPubEventMsg, err := defaultMessageCreator.ToPublicationEventMessage(coreContentCPH, cphContent)
+	actualCPHPubEventMsg, err := defaultMessageCreator.ToPublicationEventMessage(coreContentCPH, cphContent, map[string]string{})
 	assert.NoError(t, err, "No error should be thrown.")
 
-	actualCompContentPubEventMsg, err := defaultMessageCreator.ToPublicationEventMessage(coreContentCompContent, cContent)
+	actualCompContentPubEventMsg, err := defaultMessageCreator.ToPublicationEventMessage(coreContentCompContent, cContent, map[string]string{})
 	assert.NoError(t, err, "No error should be thrown.")
 
 	verifyMessageIsCorrect(t, expectedCPHPubEventMsg, actualCPHPubEventMsg)
@@ -171,3 +171,78 @@ func jsonStringToMap(marshalled string, t *testing.T) map[string]interface{} {
 	assert.NoError(t, err, "Unmashalling the json content has encountered and error")
 	return unmarshalled
 }
+
+func TestToPublicationEventMessage_MessageIdDerivedFromNativeMessage(t *testing.T) {
+	defaultMessageCreator := NewDefaultCPHMessageCreator()
+	coreContentCPH := &model.UppCoreContent{
+		UUID:       "512c1f3d-e48c-4618-863c-94bc9d913b9b",
+		ContentURI: "http://methode-content-placeholder-mapper-iw-uk-p.svc.ft.com/content/",
+	}
+	coreContentCompContent := &model.UppCoreContent{
+		UUID:       "512c1f3d-e48c-4618-863c-94bc9d913b9b",
+		ContentURI: "http://methode-content-placeholder-mapper-iw-uk-p.svc.ft.com/complementarycontent/",
+	}
+	nativeHeaders := map[string]string{"Message-Id": "7c4b9e60-3a8c-4b8f-9cbb-0e4a2f1c1a11"}
+
+	first, err := defaultMessageCreator.ToPublicationEventMessage(coreContentCPH, model.UppContentPlaceholder{}, nativeHeaders)
+	assert.NoError(t, err)
+	again, err := defaultMessageCreator.ToPublicationEventMessage(coreContentCPH, model.UppContentPlaceholder{}, nativeHeaders)
+	assert.NoError(t, err)
+	complementary, err := defaultMessageCreator.ToPublicationEventMessage(coreContentCompContent, model.UppComplementaryContent{}, nativeHeaders)
+	assert.NoError(t, err)
+
+	assert.Equal(t, first.Headers["Message-Id"], again.Headers["Message-Id"])
+	assert.NotEqual(t, first.Headers["Message-Id"], complementary.Headers["Message-Id"])
+	assert.Equal(t, OutboundMessageID("7c4b9e60-3a8c-4b8f-9cbb-0e4a2f1c1a11", "content"), first.Headers["Message-Id"])
+	assert.Equal(t, OutboundMessageID("7c4b9e60-3a8c-4b8f-9cbb-0e4a2f1c1a11", "complementarycontent"), complementary.Headers["Message-Id"])
+}
+
+func TestOutboundMessageID_RandomWithoutNativeMessageId(t *testing.T) {
+	assert.NotEqual(t, OutboundMessageID("", "content"), OutboundMessageID("", "content"))
+}
+
+func TestToPublicationEventMessage_PropagatesNativeLineage(t *testing.T) {
+	messageCreator := NewCPHMessageCreator([]string{"X-Origin-Env", "X-Missing"})
+	coreContentCPH := &model.UppCoreContent{
+		UUID:             "512c1f3d-e48c-4618-863c-94bc9d913b9b",
+		PublishReference: "tid_test123",
+		ContentURI:       "http://methode-content-placeholder-mapper-iw-uk-p.svc.ft.com/content/",
+	}
+	nativeHeaders := map[string]string{
+		"Message-Id":        "7c4b9e60-3a8c-4b8f-9cbb-0e4a2f1c1a11",
+		"Message-Type":      "cms-content-published",
+		"Message-Timestamp": "2017-05-15T15:54:32.166Z",
+		"X-Origin-Env":      "prod",
+		"X-Not-Allowed":     "secret",
+	}
+
+	msg, err := messageCreator.ToPublicationEventMessage(coreContentCPH, model.UppContentPlaceholder{}, nativeHeaders)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "7c4b9e60-3a8c-4b8f-9cbb-0e4a2f1c1a11", msg.Headers[CausationIDHeader])
+	assert.Equal(t, "2017-05-15T15:54:32.166Z", msg.Headers["Message-Timestamp"])
+	assert.Equal(t, "prod", msg.Headers["X-Origin-Env"])
+	assert.NotContains(t, msg.Headers, "X-Missing")
+	assert.NotContains(t, msg.Headers, "X-Not-Allowed")
+}
+
+func TestToPublicationEventMessage_PassthroughHeadersDontOverrideOwnHeaders(t *testing.T) {
+	messageCreator := NewCPHMessageCreator([]string{"message-type", "x-request-id"})
+	coreContentCPH := &model.UppCoreContent{
+		UUID:             "512c1f3d-e48c-4618-863c-94bc9d913b9b",
+		PublishReference: "tid_test123",
+		ContentURI:       "http://methode-content-placeholder-mapper-iw-uk-p.svc.ft.com/content/",
+	}
+	nativeHeaders := map[string]string{
+		"Message-Type": "cms-content-published-native",
+		"X-Request-Id": "tid_native",
+	}
+
+	msg, err := messageCreator.ToPublicationEventMessage(coreContentCPH, model.UppContentPlaceholder{}, nativeHeaders)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "cms-content-published", msg.Headers["Message-Type"])
+	assert.Equal(t, "tid_test123", msg.Headers["X-Request-Id"])
+	assert.NotContains(t, msg.Headers, CausationIDHeader)
+	assert.Len(t, msg.Headers, 6)
+}
@@ -0,0 +1,47 @@
+package message
+
+import (
+	"fmt"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+)
+
+// KeyStrategy chooses the key a message is produced with, which decides the partition it lands on:
+// messages produced with the same key are consumed in the order they were produced.
+type KeyStrategy func(coreAttributes *model.UppCoreContent) string
+
+// Names of the key strategies accepted by ParseKeyStrategy
+const (
+	UUIDKeyStrategy          = "uuid"
+	TransactionIDKeyStrategy = "transaction-id"
+	NoKeyStrategy            = "none"
+)
+
+// UUIDKey keys the messages by the UUID of the content they publish,
+// so the content and complementary content events of a UUID are kept in order
+func UUIDKey(coreAttributes *model.UppCoreContent) string {
+	return coreAttributes.UUID
+}
+
+// TransactionIDKey keys the messages by the transaction they were published in
+func TransactionIDKey(coreAttributes *model.UppCoreContent) string {
+	return coreAttributes.PublishReference
+}
+
+// NoKey produces the messages without a key, leaving the partition to the proxy
+func NoKey(coreAttributes *model.UppCoreContent) string {
+	return ""
+}
+
+// ParseKeyStrategy returns the key strategy with the given name
+func ParseKeyStrategy(name string) (KeyStrategy, error) {
+	switch name {
+	case UUIDKeyStrategy:
+		return UUIDKey, nil
+	case TransactionIDKeyStrategy:
+		return TransactionIDKey, nil
+	case NoKeyStrategy:
+		return NoKey, nil
+	}
+	return nil, fmt.Errorf("unknown message key strategy %q, should be %v, %v or %v", name, UUIDKeyStrategy, TransactionIDKeyStrategy, NoKeyStrategy)
+}
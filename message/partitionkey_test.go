@@ -0,0 +1,41 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUUIDKey_SameForContentAndComplementaryContent(t *testing.T) {
+	content := &model.UppCoreContent{
+		UUID:       "512c1f3d-e48c-4618-863c-94bc9d913b9b",
+		ContentURI: "http://methode-content-placeholder-mapper-iw-uk-p.svc.ft.com/content/",
+	}
+	complementaryContent := &model.UppCoreContent{
+		UUID:       "512c1f3d-e48c-4618-863c-94bc9d913b9b",
+		ContentURI: "http://methode-content-placeholder-mapper-iw-uk-p.svc.ft.com/complementarycontent/",
+	}
+
+	assert.Equal(t, "512c1f3d-e48c-4618-863c-94bc9d913b9b", UUIDKey(content))
+	assert.Equal(t, UUIDKey(content), UUIDKey(complementaryContent))
+}
+
+func TestParseKeyStrategy(t *testing.T) {
+	coreAttributes := &model.UppCoreContent{UUID: "512c1f3d-e48c-4618-863c-94bc9d913b9b", PublishReference: "tid_test123"}
+
+	strategy, err := ParseKeyStrategy("uuid")
+	assert.NoError(t, err)
+	assert.Equal(t, "512c1f3d-e48c-4618-863c-94bc9d913b9b", strategy(coreAttributes))
+
+	strategy, err = ParseKeyStrategy("transaction-id")
+	assert.NoError(t, err)
+	assert.Equal(t, "tid_test123", strategy(coreAttributes))
+
+	strategy, err = ParseKeyStrategy("none")
+	assert.NoError(t, err)
+	assert.Equal(t, "", strategy(coreAttributes))
+
+	_, err = ParseKeyStrategy("random")
+	assert.Error(t, err)
+}
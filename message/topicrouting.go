@@ -0,0 +1,89 @@
+package message
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+)
+
+// TopicRoute sends the messages publishing the content of a collection, e.g. complementarycontent, to their own topic,
+// optionally through another queue proxy than the default one
+type TopicRoute struct {
+	Collection string `json:"collection"`
+	Topic      string `json:"topic"`
+	Address    string `json:"address,omitempty"`
+}
+
+type topicRoutingConfig struct {
+	Routes []TopicRoute `json:"routes"`
+}
+
+// ParseTopicRoutes reads the routes from a JSON document like {"routes":[{"collection":"complementarycontent","topic":"ComplementaryContent"}]}
+func ParseTopicRoutes(data []byte) ([]TopicRoute, error) {
+	var config topicRoutingConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	collections := make(map[string]bool)
+	for _, route := range config.Routes {
+		if route.Collection == "" || route.Topic == "" {
+			return nil, fmt.Errorf("topic route %+v should have a collection and a topic", route)
+		}
+		if collections[route.Collection] {
+			return nil, fmt.Errorf("collection=%q is routed more than once", route.Collection)
+		}
+		collections[route.Collection] = true
+	}
+	return config.Routes, nil
+}
+
+// ReadTopicRoutes loads the routes from a JSON file
+func ReadTopicRoutes(path string) ([]TopicRoute, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTopicRoutes(data)
+}
+
+// ProducerRouter selects the producer of a message from the collection of the content it publishes
+type ProducerRouter struct {
+	defaultProducer producer.MessageProducer
+	producers       map[string]producer.MessageProducer
+}
+
+// NewProducerRouter routes the collections in producers to their producer and every other collection to defaultProducer
+func NewProducerRouter(defaultProducer producer.MessageProducer, producers map[string]producer.MessageProducer) *ProducerRouter {
+	return &ProducerRouter{defaultProducer: defaultProducer, producers: producers}
+}
+
+// ProducerFor returns the producer of the message publishing the content
+func (r *ProducerRouter) ProducerFor(coreAttributes *model.UppCoreContent) producer.MessageProducer {
+	if p, found := r.producers[collectionOf(coreAttributes.ContentURI)]; found {
+		return p
+	}
+	return r.defaultProducer
+}
+
+// ConnectivityCheck checks the connectivity of every producer
+func (r *ProducerRouter) ConnectivityCheck() (string, error) {
+	msg, err := r.defaultProducer.ConnectivityCheck()
+	if err != nil {
+		return msg, err
+	}
+	var failures []string
+	for collection, p := range r.producers {
+		if _, err := p.ConnectivityCheck(); err != nil {
+			failures = append(failures, fmt.Sprintf("producer of collection=%v: %v", collection, err))
+		}
+	}
+	if len(failures) > 0 {
+		return "Producer queue proxy is not reachable for every topic", errors.New(strings.Join(failures, "; "))
+	}
+	return msg, nil
+}
@@ -0,0 +1,51 @@
+package message
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Financial-Times/message-queue-go-producer/producer"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTopicRoutes_Ok(t *testing.T) {
+	routes, err := ParseTopicRoutes([]byte(`{"routes":[{"collection":"complementarycontent","topic":"ComplementaryContent","address":"http://kafka-proxy-2:8080"}]}`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []TopicRoute{{Collection: "complementarycontent", Topic: "ComplementaryContent", Address: "http://kafka-proxy-2:8080"}}, routes)
+}
+
+func TestParseTopicRoutes_Invalid(t *testing.T) {
+	for _, config := range []string{
+		`{"routes":[{"collection":"complementarycontent"}]}`,
+		`{"routes":[{"topic":"ComplementaryContent"}]}`,
+		`{"routes":[{"collection":"content","topic":"A"},{"collection":"content","topic":"B"}]}`,
+		`{"routes":`,
+	} {
+		_, err := ParseTopicRoutes([]byte(config))
+		assert.Error(t, err, config)
+	}
+}
+
+func TestProducerRouter_ProducerFor(t *testing.T) {
+	defaultProducer := new(model.MockProducer)
+	complementaryProducer := new(model.MockProducer)
+	router := NewProducerRouter(defaultProducer, map[string]producer.MessageProducer{"complementarycontent": complementaryProducer})
+
+	assert.Equal(t, complementaryProducer, router.ProducerFor(&model.UppCoreContent{UUID: "512c1f3d-e48c-4618-863c-94bc9d913b9b", ContentURI: "http://methode-content-placeholder-mapper-iw-uk-p.svc.ft.com/complementarycontent/"}))
+	assert.Equal(t, defaultProducer, router.ProducerFor(&model.UppCoreContent{UUID: "512c1f3d-e48c-4618-863c-94bc9d913b9b", ContentURI: "http://methode-content-placeholder-mapper-iw-uk-p.svc.ft.com/content/"}))
+}
+
+func TestProducerRouter_ConnectivityCheckFailsWithAnyProducer(t *testing.T) {
+	defaultProducer := new(model.MockProducer)
+	defaultProducer.On("ConnectivityCheck").Return("Connectivity to producer is OK.", nil)
+	complementaryProducer := new(model.MockProducer)
+	complementaryProducer.On("ConnectivityCheck").Return("Error connecting to producer", errors.New("connection refused"))
+	router := NewProducerRouter(defaultProducer, map[string]producer.MessageProducer{"complementarycontent": complementaryProducer})
+
+	_, err := router.ConnectivityCheck()
+
+	assert.EqualError(t, err, "producer of collection=complementarycontent: connection refused")
+}
@@ -0,0 +1,18 @@
+package model
+
+// DeadLetterEvent describes a native message that could not be mapped or published,
+// together with the stage it failed at, so that it can be inspected and re-driven.
+type DeadLetterEvent struct {
+	Stage           string            `json:"stage"`
+	Error           string            `json:"error"`
+	Validation      *ValidationReport `json:"validation,omitempty"`
+	TransactionID   string            `json:"transactionId"`
+	FailedAt        string            `json:"failedAt"`
+	OriginalMessage NativeMessage     `json:"originalMessage"`
+}
+
+// NativeMessage is a copy of the consumed queue message, headers included.
+type NativeMessage struct {
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
@@ -3,4 +3,6 @@ package model
 type DocStoreUppContent struct {
 	UppCoreContent
 	Brands []Brand `json:"brands"`
+	// ETag is the entity tag document-store-api returned the content with, used to revalidate it
+	ETag string `json:"-"`
 }
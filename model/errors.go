@@ -1,5 +1,7 @@
 package model
 
+import "errors"
+
 type InvalidMethodeCPH struct {
 	s string
 }
@@ -11,3 +13,104 @@ func (e *InvalidMethodeCPH) Error() string {
 func NewInvalidMethodeCPH(msg string) error {
 	return &InvalidMethodeCPH{s: msg}
 }
+
+// TransientError is returned when a dependency failed in a way that may succeed if retried,
+// e.g. a network error or a 5xx response from document-store-api.
+type TransientError struct {
+	s string
+}
+
+func (e *TransientError) Error() string {
+	return e.s
+}
+
+func NewTransientError(msg string) error {
+	return &TransientError{s: msg}
+}
+
+// IsTransient reports whether err, or any error it wraps, is a TransientError
+func IsTransient(err error) bool {
+	var transientErr *TransientError
+	return errors.As(err, &transientErr)
+}
+
+// DependencyError is returned when a dependency answered with a response that couldn't be used,
+// e.g. an unexpected status code or a malformed body from document-store-api.
+type DependencyError struct {
+	s string
+}
+
+func (e *DependencyError) Error() string {
+	return e.s
+}
+
+func NewDependencyError(msg string) error {
+	return &DependencyError{s: msg}
+}
+
+// IsDependencyFailure reports whether err, or any error it wraps, is a DependencyError
+func IsDependencyFailure(err error) bool {
+	var dependencyErr *DependencyError
+	return errors.As(err, &dependencyErr)
+}
+
+// NotFoundError is returned when document-store-api has no document for the requested uuid,
+// as opposed to failing to answer.
+type NotFoundError struct {
+	s string
+}
+
+func (e *NotFoundError) Error() string {
+	return e.s
+}
+
+func NewNotFoundError(msg string) error {
+	return &NotFoundError{s: msg}
+}
+
+// IsNotFound reports whether err, or any error it wraps, is a NotFoundError
+func IsNotFound(err error) bool {
+	var notFoundErr *NotFoundError
+	return errors.As(err, &notFoundErr)
+}
+
+// Mapping stages reported when a placeholder couldn't be mapped
+const (
+	MappingStageNativeParse          = "native-parse"
+	MappingStageCategoryRouting      = "category-routing"
+	MappingStageValidation           = "validation"
+	MappingStageBrandLookup          = "brand-lookup"
+	MappingStageIdentifierResolution = "identifier-resolution"
+	MappingStageContentMapping       = "content-mapping"
+)
+
+// StageError records the mapping stage an error happened at
+type StageError struct {
+	Stage string
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// WithStage records the mapping stage of err, unless it already has one
+func WithStage(stage string, err error) error {
+	if err == nil || StageOf(err) != "" {
+		return err
+	}
+	return &StageError{Stage: stage, Err: err}
+}
+
+// StageOf returns the mapping stage recorded in err's chain, or an empty string
+func StageOf(err error) string {
+	var stageErr *StageError
+	if errors.As(err, &stageErr) {
+		return stageErr.Stage
+	}
+	return ""
+}
@@ -0,0 +1,21 @@
+package model
+
+// Statuses of the publication of the contents mapped from one placeholder
+const (
+	PublicationComplete   = "complete"
+	PublicationFailed     = "failed"
+	PublicationIncomplete = "incomplete"
+	// PublicationStale is recorded when a message is dropped because a later one was already published
+	PublicationStale = "stale"
+)
+
+// PublicationOutcome records what happened when the contents mapped from a placeholder were published for a UUID
+type PublicationOutcome struct {
+	UUID          string   `json:"uuid"`
+	TransactionID string   `json:"transactionId"`
+	Status        string   `json:"status"`
+	Published     []string `json:"published"`
+	Unpublished   []string `json:"unpublished"`
+	Error         string   `json:"error,omitempty"`
+	RecordedAt    string   `json:"recordedAt"`
+}
@@ -1,7 +1,10 @@
 package model
 
 import (
+	"context"
+
 	"github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/Financial-Times/message-queue-gonsumer/consumer"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -18,7 +21,7 @@ type MockCPHAggregateMapper struct {
 	mock.Mock
 }
 
-func (m *MockCPHAggregateMapper) MapContentPlaceholder(mpc *MethodeContentPlaceholder, tid, lmd string) ([]UppContent, error) {
+func (m *MockCPHAggregateMapper) MapContentPlaceholder(ctx context.Context, mpc *MethodeContentPlaceholder, tid, lmd string) ([]UppContent, error) {
 	args := m.Called(mpc, tid, lmd)
 	return args.Get(0).([]UppContent), args.Error(1)
 }
@@ -41,8 +44,8 @@ type MockMessageCreator struct {
 	mock.Mock
 }
 
-func (m *MockMessageCreator) ToPublicationEventMessage(coreAttributes *UppCoreContent, payload interface{}) (*producer.Message, error) {
-	args := m.Called(coreAttributes, payload)
+func (m *MockMessageCreator) ToPublicationEventMessage(coreAttributes *UppCoreContent, payload interface{}, nativeHeaders map[string]string) (*producer.Message, error) {
+	args := m.Called(coreAttributes, payload, nativeHeaders)
 	return args.Get(0).(*producer.Message), args.Error(1)
 }
 
@@ -55,22 +58,28 @@ type MockDocStoreClient struct {
 	mock.Mock
 }
 
-func (m *MockDocStoreClient) ContentQuery(authority string, identifier string, tid string) (status int, location string, err error) {
+func (m *MockDocStoreClient) ContentQuery(ctx context.Context, authority string, identifier string, tid string) (status int, location string, err error) {
 	args := m.Called(authority, identifier, tid)
 	return args.Int(0), args.String(1), args.Error(2)
 }
 
-func (m *MockDocStoreClient) GetContent(uuid, tid string) (*DocStoreUppContent, error) {
+func (m *MockDocStoreClient) GetContent(ctx context.Context, uuid, tid string) (*DocStoreUppContent, error) {
 	args := m.Called(uuid, tid)
 	return args.Get(0).(*DocStoreUppContent), args.Error(1)
 }
 
+func (m *MockDocStoreClient) GetDocument(ctx context.Context, collection, uuid, tid string) (map[string]interface{}, bool, error) {
+	args := m.Called(collection, uuid, tid)
+	document, _ := args.Get(0).(map[string]interface{})
+	return document, args.Bool(1), args.Error(2)
+}
+
 func (m *MockDocStoreClient) ConnectivityCheck() (string, error) {
 	args := m.Called()
 	return args.String(0), args.Error(1)
 }
 
-func (m *MockDocStoreClient) ContentExists(uuid, tid string) (bool, error) {
+func (m *MockDocStoreClient) ContentExists(ctx context.Context, uuid, tid string) (bool, error) {
 	args := m.Called(uuid, tid)
 	return args.Bool(0), args.Error(1)
 }
@@ -79,12 +88,12 @@ type MockIResolver struct {
 	mock.Mock
 }
 
-func (m *MockIResolver) ContentExists(uuid, tid string) (bool, error) {
+func (m *MockIResolver) ContentExists(ctx context.Context, uuid, tid string) (bool, error) {
 	args := m.Called(uuid, tid)
 	return args.Bool(0), args.Error(1)
 }
 
-func (m *MockIResolver) ResolveIdentifier(serviceId, refField, tid string) (string, error) {
+func (m *MockIResolver) ResolveIdentifier(ctx context.Context, serviceId, refField, tid string) (string, error) {
 	args := m.Called(serviceId, refField, tid)
 	return args.String(0), args.Error(1)
 }
@@ -93,7 +102,7 @@ type MockCPHMapper struct {
 	mock.Mock
 }
 
-func (m *MockCPHMapper) MapContentPlaceholder(mpc *MethodeContentPlaceholder, uuid, tid, lmd string) ([]UppContent, error) {
+func (m *MockCPHMapper) MapContentPlaceholder(ctx context.Context, mpc *MethodeContentPlaceholder, uuid, tid, lmd string) ([]UppContent, error) {
 	args := m.Called(mpc, uuid, tid, lmd)
 	return args.Get(0).([]UppContent), args.Error(1)
 }
@@ -102,7 +111,16 @@ type MockCPHValidator struct {
 	mock.Mock
 }
 
-func (m *MockCPHValidator) Validate(mcp *MethodeContentPlaceholder) error {
+func (m *MockCPHValidator) Validate(mcp *MethodeContentPlaceholder) ValidationReport {
 	args := m.Called(mcp)
+	return args.Get(0).(ValidationReport)
+}
+
+type MockDeadLetterQueue struct {
+	mock.Mock
+}
+
+func (m *MockDeadLetterQueue) Send(msg consumer.Message, stage string, cause error, tid string) error {
+	args := m.Called(msg, stage, cause, tid)
 	return args.Error(0)
 }
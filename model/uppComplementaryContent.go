@@ -2,15 +2,15 @@ package model
 
 type UppComplementaryContent struct {
 	UppCoreContent
-	AlternativeTitles      *AlternativeTitles      `json:"alternativeTitles"`
-	AlternativeImages      *AlternativeImages      `json:"alternativeImages"`
-	AlternativeStandfirsts *AlternativeStandfirsts `json:"alternativeStandfirsts"`
+	AlternativeTitles      *AlternativeTitles      `json:"alternativeTitles,omitempty"`
+	AlternativeImages      *AlternativeImages      `json:"alternativeImages,omitempty"`
+	AlternativeStandfirsts *AlternativeStandfirsts `json:"alternativeStandfirsts,omitempty"`
 	Brands                 []Brand                 `json:"brands"`
 	Type                   string                  `json:"type"`
 }
 
 type AlternativeImages struct {
-	PromotionalImage *PromotionalImage `json:"promotionalImage"`
+	PromotionalImage *PromotionalImage `json:"promotionalImage,omitempty"`
 }
 
 type PromotionalImage struct {
@@ -14,7 +14,7 @@ type UppContentPlaceholder struct {
 	Title             string             `json:"title"`
 	Identifiers       []Identifier       `json:"identifiers"`
 	Brands            []Brand            `json:"brands"`
-	AlternativeTitles *AlternativeTitles `json:"alternativeTitles"`
+	AlternativeTitles *AlternativeTitles `json:"alternativeTitles,omitempty"`
 	WebURL            string             `json:"webUrl"`
 	CanonicalWebUrl   string             `json:"canonicalWebUrl"`
 	Type              string             `json:"type"`
@@ -0,0 +1,106 @@
+package model
+
+import (
+	"errors"
+	"strings"
+)
+
+// Severity tells whether a validation issue prevents the placeholder from being mapped
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Validation issue codes
+const (
+	CodeMalformedJSON          = "malformed_json"
+	CodeMalformedXML           = "malformed_xml"
+	CodeMalformedBase64        = "malformed_base64"
+	CodeMissingHeadlineText    = "missing_headline_text"
+	CodeMissingHeadlineURL     = "missing_headline_url"
+	CodeInvalidHeadlineURL     = "invalid_headline_url"
+	CodeRelativeHeadlineURL    = "relative_headline_url"
+	CodeMalformedLeadImage     = "malformed_lead_image_fileref"
+	CodeInvalidLeadImageUUID   = "invalid_lead_image_uuid"
+	CodeInvalidPublicationDate = "invalid_publication_date"
+	CodeMissingBlogAttribute   = "missing_blog_attribute"
+	CodeSchemaViolation        = "schema_violation"
+)
+
+// ValidationIssue is a single problem found in a native content placeholder
+type ValidationIssue struct {
+	Field    string   `json:"field"`
+	Code     string   `json:"code"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// ValidationReport collects all the problems found in a native content placeholder
+type ValidationReport struct {
+	Issues []ValidationIssue `json:"issues"`
+}
+
+func (r *ValidationReport) AddError(field, code, message string) {
+	r.Issues = append(r.Issues, ValidationIssue{Field: field, Code: code, Severity: SeverityError, Message: message})
+}
+
+func (r *ValidationReport) AddWarning(field, code, message string) {
+	r.Issues = append(r.Issues, ValidationIssue{Field: field, Code: code, Severity: SeverityWarning, Message: message})
+}
+
+// Merge appends the issues of another report
+func (r *ValidationReport) Merge(other ValidationReport) {
+	r.Issues = append(r.Issues, other.Issues...)
+}
+
+func (r ValidationReport) HasErrors() bool {
+	for _, issue := range r.Issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Warnings returns the issues which don't prevent the placeholder from being mapped
+func (r ValidationReport) Warnings() []ValidationIssue {
+	var warnings []ValidationIssue
+	for _, issue := range r.Issues {
+		if issue.Severity == SeverityWarning {
+			warnings = append(warnings, issue)
+		}
+	}
+	return warnings
+}
+
+// Err returns a ValidationError if the report has errors, nil otherwise
+func (r ValidationReport) Err() error {
+	if !r.HasErrors() {
+		return nil
+	}
+	return &ValidationError{Report: r}
+}
+
+// ValidationError is returned when a native content placeholder is invalid, with the full report of its problems
+type ValidationError struct {
+	Report ValidationReport
+}
+
+func (e *ValidationError) Error() string {
+	var messages []string
+	for _, issue := range e.Report.Issues {
+		if issue.Severity == SeverityError {
+			messages = append(messages, issue.Field+": "+issue.Message)
+		}
+	}
+	return "invalid content placeholder: " + strings.Join(messages, "; ")
+}
+
+// AsValidationError returns the ValidationError in err's chain, if any
+func AsValidationError(err error) (*ValidationError, bool) {
+	var validationErr *ValidationError
+	ok := errors.As(err, &validationErr)
+	return validationErr, ok
+}
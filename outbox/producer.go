@@ -0,0 +1,171 @@
+package outbox
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
+	"github.com/Financial-Times/message-queue-go-producer/producer"
+	log "github.com/Sirupsen/logrus"
+)
+
+// Store is the durable queue the messages that could not be produced are spooled to
+type Store interface {
+	Append(e Entry) error
+	Peek() (Entry, bool)
+	Ack() error
+	Len() int
+}
+
+// Producer is a producer.MessageProducer that spools the messages it fails to send to a Store.
+// While the store is not empty every new message is spooled as well, so that messages are produced in order,
+// and a background flusher re-sends them as soon as the underlying producer is reachable again.
+type Producer struct {
+	// MaxAttempts is how many times the first message of the outbox is re-sent to a reachable producer before it is given up
+	// and moved to Rejected, so that a message the producer never accepts doesn't hold up the others. It is re-sent forever if 0.
+	MaxAttempts int
+	// Rejected, when set, keeps the messages given up after MaxAttempts
+	Rejected Store
+
+	producer producer.MessageProducer
+	store    Store
+	flushing sync.Mutex
+	// attempts is how many times the first message of the outbox failed to be re-sent
+	attempts int
+	interval time.Duration
+	stop     chan struct{}
+	stopped  sync.WaitGroup
+}
+
+func NewProducer(p producer.MessageProducer, store Store, flushInterval time.Duration) *Producer {
+	return &Producer{
+		producer: p,
+		store:    store,
+		interval: flushInterval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// SendMessage produces the message, or spools it if it can't be produced now.
+// An error is returned only if the message could neither be sent nor spooled.
+func (p *Producer) SendMessage(key string, msg producer.Message) error {
+	if p.store.Len() == 0 {
+		err := p.producer.SendMessage(key, msg)
+		if err == nil {
+			return nil
+		}
+		log.WithField("transaction_id", msg.Headers["X-Request-Id"]).WithError(err).Warn("Error sending message, spooling it to the outbox")
+	}
+	if err := p.store.Append(Entry{Key: key, Message: msg}); err != nil {
+		return fmt.Errorf("couldn't spool message to the outbox: %v", err)
+	}
+	return nil
+}
+
+func (p *Producer) ConnectivityCheck() (string, error) {
+	return p.producer.ConnectivityCheck()
+}
+
+// Backlog returns the number of messages waiting in the outbox
+func (p *Producer) Backlog() int {
+	return p.store.Len()
+}
+
+// Start runs the background flusher
+func (p *Producer) Start() {
+	p.stopped.Add(1)
+	go func() {
+		defer p.stopped.Done()
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.Flush()
+			}
+		}
+	}()
+}
+
+func (p *Producer) Stop() {
+	close(p.stop)
+	p.stopped.Wait()
+}
+
+// Flush re-sends the spooled messages in order, if the underlying producer is reachable,
+// until the outbox is empty or a message fails, unless it failed MaxAttempts times and is rejected
+func (p *Producer) Flush() {
+	if p.store.Len() == 0 {
+		return
+	}
+	if _, err := p.producer.ConnectivityCheck(); err != nil {
+		log.WithError(err).Warnf("Producer still unreachable, %d messages waiting in the outbox", p.store.Len())
+		return
+	}
+
+	p.flushing.Lock()
+	defer p.flushing.Unlock()
+	sent := 0
+	for {
+		e, found := p.store.Peek()
+		if !found {
+			break
+		}
+		if err := p.producer.SendMessage(e.Key, e.Message); err != nil {
+			p.attempts++
+			if p.MaxAttempts <= 0 || p.attempts < p.MaxAttempts {
+				log.WithError(err).Warnf("Error re-sending message from the outbox, %d messages waiting", p.store.Len())
+				break
+			}
+			if !p.reject(e, err) {
+				break
+			}
+		} else {
+			sent++
+		}
+		p.attempts = 0
+		if err := p.store.Ack(); err != nil {
+			log.WithError(err).Error("Error acknowledging message in the outbox")
+			break
+		}
+	}
+	if sent > 0 {
+		log.Infof("Re-sent %d messages from the outbox, %d waiting", sent, p.store.Len())
+	}
+}
+
+// reject moves a message given up to Rejected, and returns false if it couldn't be kept there
+func (p *Producer) reject(e Entry, cause error) bool {
+	entry := log.WithField("transaction_id", e.Message.Headers["X-Request-Id"]).WithField("key", e.Key).WithError(cause)
+	if p.Rejected == nil {
+		entry.Errorf("Message rejected %d times, dropped from the outbox so that the following messages are sent", p.attempts)
+		return true
+	}
+	if err := p.Rejected.Append(e); err != nil {
+		entry.WithField("reject_error", err).Errorf("Couldn't move message rejected %d times out of the outbox, it is still re-sent", p.attempts)
+		return false
+	}
+	entry.Errorf("Message rejected %d times, moved out of the outbox so that the following messages are sent", p.attempts)
+	return true
+}
+
+// BacklogCheck returns the Check reporting the number of messages waiting in the outbox
+func (p *Producer) BacklogCheck() fthealth.Check {
+	return fthealth.Check{
+		BusinessImpact:   "Mapped content placeholders are delayed until the producer queue proxy is reachable",
+		Name:             "OutboxBacklog",
+		PanicGuide:       "https://dewey.ft.com/up-mcpm.html",
+		Severity:         2,
+		TechnicalSummary: "Messages that could not be produced are spooled to the outbox and re-sent in order when the producer queue proxy is reachable",
+		Checker: func() (string, error) {
+			backlog := p.Backlog()
+			if backlog > 0 {
+				return fmt.Sprintf("%d messages waiting in the outbox", backlog), fmt.Errorf("%d messages waiting in the outbox", backlog)
+			}
+			return "Outbox is empty", nil
+		},
+	}
+}
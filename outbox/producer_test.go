@@ -0,0 +1,145 @@
+package outbox
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestProducer(t *testing.T, mockedProducer *model.MockProducer) (*Producer, *FileStore, func()) {
+	dir := tempOutboxDir(t)
+	store, err := NewFileStore(dir)
+	assert.NoError(t, err)
+	return NewProducer(mockedProducer, store, 0), store, func() {
+		store.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func anyMessage() interface{} {
+	return mock.MatchedBy(func(msg producer.Message) bool { return true })
+}
+
+func TestProducerSendMessage_SentDirectly(t *testing.T) {
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("SendMessage", "uuid", anyMessage()).Return(nil)
+	p, store, cleanup := newTestProducer(t, mockedProducer)
+	defer cleanup()
+
+	err := p.SendMessage("uuid", producer.Message{Body: "first"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, store.Len())
+	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 1)
+}
+
+func TestProducerSendMessage_SpooledOnFailureAndWhileBacklogged(t *testing.T) {
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("SendMessage", "uuid", anyMessage()).Return(errors.New("Write queue unavailable"))
+	p, store, cleanup := newTestProducer(t, mockedProducer)
+	defer cleanup()
+
+	assert.NoError(t, p.SendMessage("uuid", producer.Message{Body: "first"}))
+	assert.NoError(t, p.SendMessage("uuid", producer.Message{Body: "second"}))
+
+	assert.Equal(t, 2, p.Backlog())
+	e, _ := store.Peek()
+	assert.Equal(t, "first", e.Message.Body)
+	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 1)
+}
+
+func TestProducerFlush_ResendsInOrderWhenReachable(t *testing.T) {
+	var sentBodies []string
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("ConnectivityCheck").Return("OK", nil)
+	mockedProducer.On("SendMessage", "uuid", anyMessage()).Return(nil).Run(func(args mock.Arguments) {
+		sentBodies = append(sentBodies, args.Get(1).(producer.Message).Body)
+	})
+	p, store, cleanup := newTestProducer(t, mockedProducer)
+	defer cleanup()
+	store.Append(Entry{Key: "uuid", Message: producer.Message{Body: "first"}})
+	store.Append(Entry{Key: "uuid", Message: producer.Message{Body: "second"}})
+
+	p.Flush()
+
+	assert.Equal(t, []string{"first", "second"}, sentBodies)
+	assert.Equal(t, 0, p.Backlog())
+}
+
+func TestProducerFlush_SkippedWhenUnreachable(t *testing.T) {
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("ConnectivityCheck").Return("", errors.New("Write queue unavailable"))
+	p, store, cleanup := newTestProducer(t, mockedProducer)
+	defer cleanup()
+	store.Append(Entry{Key: "uuid", Message: producer.Message{Body: "first"}})
+
+	p.Flush()
+
+	assert.Equal(t, 1, p.Backlog())
+	mockedProducer.AssertNotCalled(t, "SendMessage", "uuid", anyMessage())
+}
+
+func TestProducerFlush_StopsAtFirstFailure(t *testing.T) {
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("ConnectivityCheck").Return("OK", nil)
+	mockedProducer.On("SendMessage", "uuid", anyMessage()).Return(nil).Once()
+	mockedProducer.On("SendMessage", "uuid", anyMessage()).Return(errors.New("Write queue unavailable"))
+	p, store, cleanup := newTestProducer(t, mockedProducer)
+	defer cleanup()
+	store.Append(Entry{Key: "uuid", Message: producer.Message{Body: "first"}})
+	store.Append(Entry{Key: "uuid", Message: producer.Message{Body: "second"}})
+	store.Append(Entry{Key: "uuid", Message: producer.Message{Body: "third"}})
+
+	p.Flush()
+
+	assert.Equal(t, 2, p.Backlog())
+	e, _ := store.Peek()
+	assert.Equal(t, "second", e.Message.Body)
+}
+
+func TestProducerFlush_RejectsMessageAfterMaxAttempts(t *testing.T) {
+	mockedProducer := new(model.MockProducer)
+	mockedProducer.On("ConnectivityCheck").Return("OK", nil)
+	mockedProducer.On("SendMessage", "too-large", anyMessage()).Return(errors.New("Message too large"))
+	mockedProducer.On("SendMessage", "uuid", anyMessage()).Return(nil)
+	p, store, cleanup := newTestProducer(t, mockedProducer)
+	defer cleanup()
+	rejectedDir := tempOutboxDir(t)
+	defer os.RemoveAll(rejectedDir)
+	rejected, err := NewFileStore(rejectedDir)
+	assert.NoError(t, err)
+	defer rejected.Close()
+	p.MaxAttempts = 3
+	p.Rejected = rejected
+	store.Append(Entry{Key: "too-large", Message: producer.Message{Body: "first"}})
+	store.Append(Entry{Key: "uuid", Message: producer.Message{Body: "second"}})
+
+	p.Flush()
+	p.Flush()
+	assert.Equal(t, 2, p.Backlog(), "the message should be re-sent until MaxAttempts")
+
+	p.Flush()
+	assert.Equal(t, 0, p.Backlog(), "the following messages should be sent once the message is rejected")
+	assert.Equal(t, 1, rejected.Len())
+	e, _ := rejected.Peek()
+	assert.Equal(t, "first", e.Message.Body)
+	mockedProducer.AssertNumberOfCalls(t, "SendMessage", 4)
+}
+
+func TestProducerBacklogCheck(t *testing.T) {
+	p, store, cleanup := newTestProducer(t, new(model.MockProducer))
+	defer cleanup()
+
+	_, err := p.BacklogCheck().Checker()
+	assert.NoError(t, err)
+
+	store.Append(Entry{Key: "uuid", Message: producer.Message{Body: "first"}})
+	msg, err := p.BacklogCheck().Checker()
+	assert.Error(t, err)
+	assert.Equal(t, "1 messages waiting in the outbox", msg)
+}
@@ -0,0 +1,201 @@
+package outbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Financial-Times/message-queue-go-producer/producer"
+)
+
+const (
+	logFileName    = "outbox.log"
+	offsetFileName = "outbox.offset"
+)
+
+// Entry is a message waiting in the outbox together with the key it has to be produced with
+type Entry struct {
+	Key     string           `json:"key"`
+	Message producer.Message `json:"message"`
+}
+
+// FileStore is an append-only, file-backed queue of entries.
+// Entries are appended as JSON lines to outbox.log, and outbox.offset holds how many of them were already sent.
+// The log is truncated whenever every entry has been sent.
+type FileStore struct {
+	mu      sync.Mutex
+	dir     string
+	log     *os.File
+	pending []Entry
+	offset  int
+}
+
+// NewFileStore opens the outbox in dir, creating it if needed, and loads the entries not yet sent
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("couldn't create outbox directory %v: %v", dir, err)
+	}
+	s := &FileStore{dir: dir}
+
+	offset, err := s.readOffset()
+	if err != nil {
+		return nil, err
+	}
+	entries, validLength, err := readEntries(filepath.Join(dir, logFileName))
+	if err != nil {
+		return nil, err
+	}
+	if offset > len(entries) {
+		offset = len(entries)
+	}
+
+	logFile, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open outbox log: %v", err)
+	}
+	// drops a partially written entry left by a crash
+	if err := logFile.Truncate(validLength); err != nil {
+		logFile.Close()
+		return nil, fmt.Errorf("couldn't truncate outbox log: %v", err)
+	}
+	if _, err := logFile.Seek(validLength, 0); err != nil {
+		logFile.Close()
+		return nil, fmt.Errorf("couldn't seek outbox log: %v", err)
+	}
+
+	s.log = logFile
+	s.offset = offset
+	s.pending = entries[offset:]
+	return s, nil
+}
+
+// Append durably adds the entry at the end of the outbox
+func (s *FileStore) Append(e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("couldn't marshal outbox entry: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.log.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("couldn't write outbox entry: %v", err)
+	}
+	if err := s.log.Sync(); err != nil {
+		return fmt.Errorf("couldn't sync outbox log: %v", err)
+	}
+	s.pending = append(s.pending, e)
+	return nil
+}
+
+// Peek returns the oldest entry not yet sent
+func (s *FileStore) Peek() (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) == 0 {
+		return Entry{}, false
+	}
+	return s.pending[0], true
+}
+
+// Ack marks the oldest entry as sent
+func (s *FileStore) Ack() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) == 0 {
+		return nil
+	}
+	s.pending = s.pending[1:]
+	s.offset++
+	if len(s.pending) == 0 {
+		return s.compact()
+	}
+	return s.writeOffset(s.offset)
+}
+
+// Len returns the number of entries not yet sent
+func (s *FileStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.log.Close()
+}
+
+func (s *FileStore) compact() error {
+	// the offset is reset first: if the truncation does not happen, the already sent entries are sent again
+	// rather than new entries being skipped
+	if err := s.writeOffset(0); err != nil {
+		return err
+	}
+	s.offset = 0
+	if err := s.log.Truncate(0); err != nil {
+		return fmt.Errorf("couldn't truncate outbox log: %v", err)
+	}
+	if _, err := s.log.Seek(0, 0); err != nil {
+		return fmt.Errorf("couldn't seek outbox log: %v", err)
+	}
+	return nil
+}
+
+func (s *FileStore) readOffset() (int, error) {
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, offsetFileName))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("couldn't read outbox offset: %v", err)
+	}
+	offset, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid outbox offset: %v", err)
+	}
+	return offset, nil
+}
+
+func (s *FileStore) writeOffset(offset int) error {
+	tmp := filepath.Join(s.dir, offsetFileName+".tmp")
+	if err := ioutil.WriteFile(tmp, []byte(strconv.Itoa(offset)), 0644); err != nil {
+		return fmt.Errorf("couldn't write outbox offset: %v", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, offsetFileName)); err != nil {
+		return fmt.Errorf("couldn't replace outbox offset: %v", err)
+	}
+	return nil
+}
+
+// readEntries parses the newline terminated entries of the log, and returns the length they span
+func readEntries(path string) ([]Entry, int64, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("couldn't read outbox log: %v", err)
+	}
+
+	var entries []Entry
+	var validLength int64
+	for {
+		end := bytes.IndexByte(data[validLength:], '\n')
+		if end < 0 {
+			break
+		}
+		var e Entry
+		if err := json.Unmarshal(data[validLength:validLength+int64(end)], &e); err != nil {
+			break
+		}
+		entries = append(entries, e)
+		validLength += int64(end) + 1
+	}
+	return entries, validLength, nil
+}
@@ -0,0 +1,107 @@
+package outbox
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/stretchr/testify/assert"
+)
+
+func tempOutboxDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "outbox")
+	assert.NoError(t, err)
+	return dir
+}
+
+func entry(body string) Entry {
+	return Entry{Key: "512c1f3d-e48c-4618-863c-94bc9d913b9b", Message: producer.Message{Headers: map[string]string{"X-Request-Id": "tid_test123"}, Body: body}}
+}
+
+func TestFileStore_AppendPeekAck(t *testing.T) {
+	dir := tempOutboxDir(t)
+	defer os.RemoveAll(dir)
+	s, err := NewFileStore(dir)
+	assert.NoError(t, err)
+	defer s.Close()
+
+	assert.NoError(t, s.Append(entry("first")))
+	assert.NoError(t, s.Append(entry("second")))
+	assert.Equal(t, 2, s.Len())
+
+	e, found := s.Peek()
+	assert.True(t, found)
+	assert.Equal(t, entry("first"), e)
+
+	assert.NoError(t, s.Ack())
+	e, _ = s.Peek()
+	assert.Equal(t, "second", e.Message.Body)
+	assert.Equal(t, 1, s.Len())
+}
+
+func TestFileStore_PendingEntriesSurviveReopening(t *testing.T) {
+	dir := tempOutboxDir(t)
+	defer os.RemoveAll(dir)
+	s, err := NewFileStore(dir)
+	assert.NoError(t, err)
+	s.Append(entry("first"))
+	s.Append(entry("second"))
+	s.Append(entry("third"))
+	s.Ack()
+	s.Close()
+
+	reopened, err := NewFileStore(dir)
+	assert.NoError(t, err)
+	defer reopened.Close()
+
+	assert.Equal(t, 2, reopened.Len())
+	e, _ := reopened.Peek()
+	assert.Equal(t, "second", e.Message.Body)
+}
+
+func TestFileStore_LogTruncatedWhenEmpty(t *testing.T) {
+	dir := tempOutboxDir(t)
+	defer os.RemoveAll(dir)
+	s, err := NewFileStore(dir)
+	assert.NoError(t, err)
+	defer s.Close()
+
+	s.Append(entry("first"))
+	s.Ack()
+	s.Append(entry("second"))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, logFileName))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "first")
+	e, _ := s.Peek()
+	assert.Equal(t, "second", e.Message.Body)
+}
+
+func TestFileStore_PartiallyWrittenEntryDropped(t *testing.T) {
+	dir := tempOutboxDir(t)
+	defer os.RemoveAll(dir)
+	s, err := NewFileStore(dir)
+	assert.NoError(t, err)
+	s.Append(entry("first"))
+	s.Close()
+
+	logFile, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	logFile.WriteString(`{"key":"512c1f3d","message":{"Hea`)
+	logFile.Close()
+
+	reopened, err := NewFileStore(dir)
+	assert.NoError(t, err)
+	defer reopened.Close()
+	assert.Equal(t, 1, reopened.Len())
+
+	reopened.Append(entry("second"))
+	reopened.Close()
+
+	again, err := NewFileStore(dir)
+	assert.NoError(t, err)
+	defer again.Close()
+	assert.Equal(t, 2, again.Len())
+}
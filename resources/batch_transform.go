@@ -0,0 +1,210 @@
+package resources
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	tidUtils "github.com/Financial-Times/transactionid-utils-go"
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	defaultBatchWorkers = 8
+	ndjsonContentType   = "application/x-ndjson"
+	maxBatchItemSize    = 10 * 1024 * 1024
+)
+
+// batchResult is the outcome of mapping one placeholder of a batch, streamed back as soon as it is known
+type batchResult struct {
+	Index   int                      `json:"index"`
+	UUID    string                   `json:"uuid"`
+	Status  int                      `json:"status"`
+	Events  []model.PublicationEvent `json:"events,omitempty"`
+	Deleted bool                     `json:"deleted,omitempty"`
+	Problem *problem                 `json:"problem,omitempty"`
+}
+
+type batchItem struct {
+	index int
+	body  []byte
+}
+
+// ServeBatchMapEndpoint maps a JSON array or an NDJSON stream of native placeholders concurrently.
+// The response is an NDJSON stream with one result per placeholder, in completion order,
+// identified by the native UUID and the index of the placeholder in the request.
+func (h *MapEndpointHandler) ServeBatchMapEndpoint(w http.ResponseWriter, r *http.Request) {
+	tid := tidUtils.GetTransactionIDFromRequest(r)
+	log.WithField("transaction_id", tid).WithField("request_uri", r.RequestURI).Info("Received batch transformation request")
+	lmd := time.Now().Format(model.UPPDateFormat)
+
+	items := make(chan batchItem)
+	results := make(chan batchResult)
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(items)
+		if err := readBatch(r.Body, items); err != nil {
+			results <- batchResult{Index: err.index, Status: http.StatusBadRequest, Problem: &problem{
+				Type:          problemTypeUnreadableRequest,
+				Title:         "Could not read the batch from request",
+				Status:        http.StatusBadRequest,
+				Detail:        err.Error(),
+				Instance:      r.RequestURI,
+				Stage:         model.MappingStageNativeParse,
+				TransactionID: tid,
+			}}
+		}
+	}()
+
+	workers := h.BatchWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range items {
+				ctx, cancel := h.mappingContext(r)
+				result := toBatchResult(item, h.transform(ctx, item.body, tid, lmd, r.RequestURI))
+				cancel()
+				results <- result
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// an HTTP/1.1 server closes the request body once the response is flushed, unless reading and writing are interleaved
+	if err := http.NewResponseController(w).EnableFullDuplex(); err != nil {
+		log.WithField("transaction_id", tid).WithError(err).Debug("Couldn't enable full duplex, the batch is only streamed back if the server supports it")
+	}
+	w.Header().Add("Content-Type", ndjsonContentType)
+	w.Header().Add("X-Request-ID", tid)
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	count, failed := 0, 0
+	for result := range results {
+		count++
+		if result.Problem != nil {
+			failed++
+		}
+		// keep draining the results if the client went away, so that no worker is left blocked
+		if err := encoder.Encode(result); err == nil && flusher != nil {
+			flusher.Flush()
+		}
+	}
+	log.WithField("transaction_id", tid).WithField("request_uri", r.RequestURI).WithField("count", count).WithField("failed", failed).Info("Batch transformation finished")
+}
+
+func toBatchResult(item batchItem, t transformation) batchResult {
+	result := batchResult{Index: item.index, UUID: t.uuid, Status: http.StatusOK, Events: t.events}
+	switch {
+	case t.problem != nil:
+		result.Status = t.problem.Status
+		result.Problem = t.problem
+		if result.UUID == "" {
+			result.UUID = nativeUUID(item.body)
+		}
+	case t.deleted:
+		result.Status = http.StatusNotFound
+		result.Deleted = true
+	}
+	return result
+}
+
+// nativeUUID returns the uuid of a native placeholder which couldn't be mapped, if it has one
+func nativeUUID(body []byte) string {
+	var native struct {
+		UUID string `json:"uuid"`
+	}
+	json.Unmarshal(body, &native)
+	return native.UUID
+}
+
+type batchReadError struct {
+	index int
+	err   error
+}
+
+func (e *batchReadError) Error() string {
+	return fmt.Sprintf("couldn't read placeholder %d of the batch: %v", e.index, e.err)
+}
+
+// readBatch sends the placeholders of a JSON array, or of an NDJSON stream, as they are read.
+// A malformed NDJSON line is passed on to be reported as invalid, whereas a malformed array can't be read any further.
+func readBatch(body io.Reader, items chan<- batchItem) *batchReadError {
+	reader := bufio.NewReader(body)
+	first, err := firstNonSpace(reader)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return &batchReadError{index: 0, err: err}
+	}
+	if first == '[' {
+		return readJSONArray(reader, items)
+	}
+	return readNDJSON(reader, items)
+}
+
+func readJSONArray(reader io.Reader, items chan<- batchItem) *batchReadError {
+	decoder := json.NewDecoder(reader)
+	if _, err := decoder.Token(); err != nil {
+		return &batchReadError{index: 0, err: err}
+	}
+	index := 0
+	for decoder.More() {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return &batchReadError{index: index, err: err}
+		}
+		items <- batchItem{index: index, body: raw}
+		index++
+	}
+	if _, err := decoder.Token(); err != nil {
+		return &batchReadError{index: index, err: err}
+	}
+	return nil
+}
+
+func readNDJSON(reader io.Reader, items chan<- batchItem) *batchReadError {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), maxBatchItemSize)
+	index := 0
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		items <- batchItem{index: index, body: append([]byte(nil), line...)}
+		index++
+	}
+	if err := scanner.Err(); err != nil {
+		return &batchReadError{index: index, err: err}
+	}
+	return nil
+}
+
+func firstNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			return b, reader.UnreadByte()
+		}
+	}
+}
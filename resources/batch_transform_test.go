@@ -0,0 +1,147 @@
+package resources
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/message"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const batchMapperURL = "http://methode-content-placeholder-mapper/map/batch"
+
+func TestBatchMapEndpoint_JSONArray(t *testing.T) {
+	body := `[{"uuid": "512c1f3d-e48c-4618-863c-94bc9d913b9b"}, {"uuid": "cdac1f3d-e48c-4618-863c-94bc9d913b9b"}, {"uuid": "deleted"}]`
+
+	w := serveBatchMapEndpoint(body)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	results := readBatchResults(t, w)
+	assert.Len(t, results, 3)
+
+	ok := results["512c1f3d-e48c-4618-863c-94bc9d913b9b"]
+	assert.Equal(t, http.StatusOK, ok.Status)
+	assert.Equal(t, 0, ok.Index)
+	assert.Len(t, ok.Events, 2)
+	assert.Nil(t, ok.Problem)
+
+	failed := results["cdac1f3d-e48c-4618-863c-94bc9d913b9b"]
+	assert.Equal(t, http.StatusUnprocessableEntity, failed.Status)
+	assert.Equal(t, 1, failed.Index)
+	assert.Equal(t, model.MappingStageValidation, failed.Problem.Stage)
+	assert.NotNil(t, failed.Problem.Validation)
+
+	deleted := results["deleted"]
+	assert.Equal(t, http.StatusNotFound, deleted.Status)
+	assert.True(t, deleted.Deleted)
+}
+
+func TestBatchMapEndpoint_NDJSONWithMalformedLine(t *testing.T) {
+	body := "{\"uuid\": \"512c1f3d-e48c-4618-863c-94bc9d913b9b\"}\n{\"uuid\": \n\n{\"uuid\": \"deleted\"}\n"
+
+	w := serveBatchMapEndpoint(body)
+
+	results := readBatchResults(t, w)
+	assert.Len(t, results, 3)
+	assert.Equal(t, http.StatusOK, results["512c1f3d-e48c-4618-863c-94bc9d913b9b"].Status)
+	assert.Equal(t, http.StatusUnprocessableEntity, results[""].Status)
+	assert.Equal(t, 1, results[""].Index)
+	assert.Equal(t, model.MappingStageNativeParse, results[""].Problem.Stage)
+	assert.Equal(t, 2, results["deleted"].Index)
+}
+
+func TestBatchMapEndpoint_TruncatedArray(t *testing.T) {
+	body := `[{"uuid": "512c1f3d-e48c-4618-863c-94bc9d913b9b"}, {"uuid": `
+
+	w := serveBatchMapEndpoint(body)
+
+	results := readBatchResults(t, w)
+	assert.Len(t, results, 2)
+	assert.Equal(t, http.StatusOK, results["512c1f3d-e48c-4618-863c-94bc9d913b9b"].Status)
+	assert.Equal(t, http.StatusBadRequest, results[""].Status)
+	assert.Equal(t, 1, results[""].Index)
+}
+
+func TestBatchMapEndpoint_Empty(t *testing.T) {
+	w := serveBatchMapEndpoint("  ")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, readBatchResults(t, w))
+}
+
+func TestBatchMapEndpoint_StreamedOverHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(newBatchMapEndpointHandler().ServeBatchMapEndpoint))
+	defer server.Close()
+	// far more than the read buffer of the server, so the body is still being read once results are flushed
+	var body strings.Builder
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&body, "{\"uuid\": \"deleted\", \"index\": %d}\n", i)
+	}
+
+	resp, err := http.Post(server.URL+"/map/batch", "application/x-ndjson", strings.NewReader(body.String()))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	indexes := make(map[int]bool)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var result batchResult
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &result))
+		assert.Equal(t, http.StatusNotFound, result.Status, "%+v", result.Problem)
+		indexes[result.Index] = true
+	}
+	assert.Len(t, indexes, 5000)
+}
+
+func serveBatchMapEndpoint(body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	newBatchMapEndpointHandler().ServeBatchMapEndpoint(w, httptest.NewRequest("POST", batchMapperURL, strings.NewReader(body)))
+	return w
+}
+
+func newBatchMapEndpointHandler() *MapEndpointHandler {
+	nativeMapper := new(model.MockNativeMapper)
+	nativeMapper.On("Map", mock.MatchedBy(func(b []byte) bool { return strings.Contains(string(b), "512c1f3d") })).Return(&model.MethodeContentPlaceholder{UUID: "512c1f3d-e48c-4618-863c-94bc9d913b9b"}, nil)
+	nativeMapper.On("Map", mock.MatchedBy(func(b []byte) bool { return strings.Contains(string(b), "cdac1f3d") })).Return(&model.MethodeContentPlaceholder{UUID: "cdac1f3d-e48c-4618-863c-94bc9d913b9b"}, nil)
+	nativeMapper.On("Map", mock.MatchedBy(func(b []byte) bool { return strings.Contains(string(b), "deleted") })).Return(&model.MethodeContentPlaceholder{UUID: "deleted", Attributes: model.Attributes{IsDeleted: true}}, nil)
+	nativeMapper.On("Map", mock.MatchedBy(func(b []byte) bool { return true })).Return(&model.MethodeContentPlaceholder{}, errors.New("error unmarshalling methode messageBody"))
+
+	report := model.ValidationReport{}
+	report.AddError("body.leadHeadline.text", model.CodeMissingHeadlineText, "Methode Content headline does not contain text")
+
+	aggregateMapper := new(model.MockCPHAggregateMapper)
+	aggregateMapper.On("MapContentPlaceholder", mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool {
+		return mpc.UUID == "512c1f3d-e48c-4618-863c-94bc9d913b9b"
+	}),
+		mock.MatchedBy(func(string) bool { return true }),
+		mock.MatchedBy(func(string) bool { return true })).Return([]model.UppContent{&model.UppContentPlaceholder{}, &model.UppComplementaryContent{}}, nil)
+	aggregateMapper.On("MapContentPlaceholder", mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool {
+		return mpc.UUID == "cdac1f3d-e48c-4618-863c-94bc9d913b9b"
+	}),
+		mock.MatchedBy(func(string) bool { return true }),
+		mock.MatchedBy(func(string) bool { return true })).Return([]model.UppContent{}, model.WithStage(model.MappingStageValidation, report.Err()))
+
+	mapHandler := NewMapEndpointHandler(aggregateMapper, message.NewDefaultCPHMessageCreator(), nativeMapper)
+	mapHandler.BatchWorkers = 2
+	return mapHandler
+}
+
+func readBatchResults(t *testing.T, w *httptest.ResponseRecorder) map[string]batchResult {
+	results := make(map[string]batchResult)
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var result batchResult
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &result))
+		results[result.UUID] = result
+	}
+	return results
+}
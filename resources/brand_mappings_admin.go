@@ -0,0 +1,104 @@
+package resources
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/mapper"
+	tidUtils "github.com/Financial-Times/transactionid-utils-go"
+	log "github.com/Sirupsen/logrus"
+)
+
+const apiKeyHeader = "X-Api-Key"
+
+type BrandMappingsAdminHandler struct {
+	registry *mapper.BrandMappingRegistry
+	apiKey   string
+}
+
+type brandMappingsStatus struct {
+	mapper.BrandMappingVersion
+	Mappings map[string]string `json:"mappings"`
+}
+
+func NewBrandMappingsAdminHandler(registry *mapper.BrandMappingRegistry, apiKey string) *BrandMappingsAdminHandler {
+	return &BrandMappingsAdminHandler{registry: registry, apiKey: apiKey}
+}
+
+// ServeBrandMappings returns the active brand mappings with their version and load time
+func (h *BrandMappingsAdminHandler) ServeBrandMappings(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(w, r) {
+		return
+	}
+	h.writeStatus(w)
+}
+
+// UpdateBrandMappings validates the brand mappings in the request body and makes them active.
+// An If-Match header with the expected current version guards against concurrent updates.
+func (h *BrandMappingsAdminHandler) UpdateBrandMappings(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(w, r) {
+		return
+	}
+	tid := tidUtils.GetTransactionIDFromRequest(r)
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeAdminError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	version, err := h.registry.Update(body, "admin", entityTagValue(r.Header.Get("If-Match")))
+	var invalidErr *mapper.InvalidBrandMappingsError
+	var mismatchErr *mapper.BrandMappingsVersionMismatchError
+	switch {
+	case errors.As(err, &invalidErr):
+		log.WithField("transaction_id", tid).WithError(err).Warn("Rejected brand mappings update")
+		writeAdminError(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.As(err, &mismatchErr):
+		log.WithField("transaction_id", tid).WithError(err).Warn("Rejected brand mappings update")
+		writeAdminError(w, err.Error(), http.StatusPreconditionFailed)
+		return
+	case err != nil:
+		log.WithField("transaction_id", tid).WithError(err).Error("Couldn't update brand mappings")
+		writeAdminError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.WithField("transaction_id", tid).WithField("version", version.Version).Info("Brand mappings updated")
+	h.writeStatus(w)
+}
+
+// entityTagValue returns the version in an If-Match header, without the quotes of the entity tag,
+// or an empty string for any version
+func entityTagValue(header string) string {
+	tag := strings.TrimPrefix(strings.TrimSpace(header), "W/")
+	if tag == "*" {
+		return ""
+	}
+	return strings.Trim(tag, `"`)
+}
+
+func (h *BrandMappingsAdminHandler) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if h.apiKey != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get(apiKeyHeader)), []byte(h.apiKey)) == 1 {
+		return true
+	}
+	writeAdminError(w, "Missing or invalid "+apiKeyHeader, http.StatusUnauthorized)
+	return false
+}
+
+func (h *BrandMappingsAdminHandler) writeStatus(w http.ResponseWriter) {
+	mappings, version := h.registry.Current()
+	w.Header().Add("Content-Type", "application/json")
+	w.Header().Add("ETag", `"`+version.Version+`"`)
+	json.NewEncoder(w).Encode(&brandMappingsStatus{BrandMappingVersion: version, Mappings: mappings})
+}
+
+func writeAdminError(w http.ResponseWriter, message string, status int) {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	data, _ := json.Marshal(&msg{Message: message})
+	w.Write(data)
+}
@@ -0,0 +1,119 @@
+package resources
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/mapper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBrandMappingsAdmin_Get(t *testing.T) {
+	h, cleanup := newBrandMappingsAdminHandler(t)
+	defer cleanup()
+
+	w := serveBrandMappingsAdmin(h, "GET", "secret", "", "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var status brandMappingsStatus
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&status))
+	assert.Equal(t, map[string]string{"blogs.ft.com": "FT-LABS-WP-1-2"}, status.Mappings)
+	assert.NotEmpty(t, status.Version)
+	assert.Equal(t, `"`+status.Version+`"`, w.Header().Get("ETag"))
+	assert.False(t, status.LoadedAt.IsZero())
+}
+
+func TestBrandMappingsAdmin_Unauthorized(t *testing.T) {
+	h, cleanup := newBrandMappingsAdminHandler(t)
+	defer cleanup()
+
+	assert.Equal(t, http.StatusUnauthorized, serveBrandMappingsAdmin(h, "GET", "", "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, serveBrandMappingsAdmin(h, "PUT", "wrong", "", `{"ftalphaville.ft.com": "FT-LABS-WP-1-24"}`).Code)
+}
+
+func TestBrandMappingsAdmin_Put(t *testing.T) {
+	h, cleanup := newBrandMappingsAdminHandler(t)
+	defer cleanup()
+
+	w := serveBrandMappingsAdmin(h, "PUT", "secret", "", `{"ftalphaville.ft.com": "FT-LABS-WP-1-24"}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var status brandMappingsStatus
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&status))
+	assert.Equal(t, map[string]string{"ftalphaville.ft.com": "FT-LABS-WP-1-24"}, status.Mappings)
+	assert.Equal(t, "admin", status.Source)
+}
+
+func TestBrandMappingsAdmin_PutInvalid(t *testing.T) {
+	h, cleanup := newBrandMappingsAdminHandler(t)
+	defer cleanup()
+
+	w := serveBrandMappingsAdmin(h, "PUT", "secret", "", `{"blogs.ft.com": ""}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mappings, _ := h.registry.Current()
+	assert.Equal(t, map[string]string{"blogs.ft.com": "FT-LABS-WP-1-2"}, mappings)
+}
+
+func TestBrandMappingsAdmin_PutStaleVersion(t *testing.T) {
+	h, cleanup := newBrandMappingsAdminHandler(t)
+	defer cleanup()
+
+	w := serveBrandMappingsAdmin(h, "PUT", "secret", "0123456789ab", `{"ftalphaville.ft.com": "FT-LABS-WP-1-24"}`)
+
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+}
+
+func TestBrandMappingsAdmin_PutMatchingETag(t *testing.T) {
+	h, cleanup := newBrandMappingsAdminHandler(t)
+	defer cleanup()
+	etag := serveBrandMappingsAdmin(h, "GET", "secret", "", "").Header().Get("ETag")
+
+	w := serveBrandMappingsAdmin(h, "PUT", "secret", etag, `{"ftalphaville.ft.com": "FT-LABS-WP-1-24"}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	assert.Equal(t, http.StatusPreconditionFailed, serveBrandMappingsAdmin(h, "PUT", "secret", etag, `{"blogs.ft.com": "FT-LABS-WP-1-3"}`).Code)
+}
+
+func TestBrandMappingsAdmin_PutWriteFailure(t *testing.T) {
+	h, cleanup := newBrandMappingsAdminHandler(t)
+	cleanup()
+
+	w := serveBrandMappingsAdmin(h, "PUT", "secret", "", `{"ftalphaville.ft.com": "FT-LABS-WP-1-24"}`)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func newBrandMappingsAdminHandler(t *testing.T) (*BrandMappingsAdminHandler, func()) {
+	dir, err := ioutil.TempDir("", "brandMappings")
+	assert.NoError(t, err)
+	path := filepath.Join(dir, "brandMappings.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"blogs.ft.com": "FT-LABS-WP-1-2"}`), 0644))
+	registry, err := mapper.NewBrandMappingRegistry(path)
+	assert.NoError(t, err)
+	return NewBrandMappingsAdminHandler(registry, "secret"), func() { os.RemoveAll(dir) }
+}
+
+func serveBrandMappingsAdmin(h *BrandMappingsAdminHandler, method, apiKey, ifMatch, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/__admin/brand-mappings", strings.NewReader(body))
+	if apiKey != "" {
+		req.Header.Set("X-Api-Key", apiKey)
+	}
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	w := httptest.NewRecorder()
+	if method == "PUT" {
+		h.UpdateBrandMappings(w, req)
+	} else {
+		h.ServeBrandMappings(w, req)
+	}
+	return w
+}
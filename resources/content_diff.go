@@ -0,0 +1,153 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+)
+
+const diffStage = "diff"
+
+// Kinds of field changes
+const (
+	fieldAdded   = "added"
+	fieldRemoved = "removed"
+	fieldChanged = "changed"
+)
+
+type fieldChange struct {
+	Path   string      `json:"path"`
+	Change string      `json:"change"`
+	Stored interface{} `json:"stored"`
+	Mapped interface{} `json:"mapped"`
+}
+
+// contentDiff lists what publishing a mapped event would change in the document stored in document-store-api
+type contentDiff struct {
+	ContentURI string        `json:"contentUri"`
+	Collection string        `json:"collection"`
+	UUID       string        `json:"uuid"`
+	Stored     bool          `json:"stored"`
+	Changes    []fieldChange `json:"changes"`
+}
+
+// diffWithDocumentStore compares the payload of each event with the document currently stored for its content URI
+func (h *MapEndpointHandler) diffWithDocumentStore(ctx context.Context, events []model.PublicationEvent, tid string) ([]contentDiff, error) {
+	diffs := make([]contentDiff, 0, len(events))
+	for _, event := range events {
+		collection, uuid, err := storedDocumentOf(event.ContentURI)
+		if err != nil {
+			return nil, err
+		}
+		stored, found, err := h.DocStore.GetDocument(ctx, collection, uuid, tid)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't fetch the stored %v of uuid=%v: %w", collection, uuid, err)
+		}
+		mapped, err := toGenericJSON(event.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		diff := contentDiff{ContentURI: event.ContentURI, Collection: collection, UUID: uuid, Stored: found, Changes: []fieldChange{}}
+		var storedValue interface{}
+		if found {
+			storedValue = stored
+		}
+		diffValues("", storedValue, mapped, &diff.Changes)
+		diffs = append(diffs, diff)
+	}
+	return diffs, nil
+}
+
+// storedDocumentOf returns the document-store-api collection and the uuid of a content URI,
+// e.g. complementarycontent and the uuid for http://methode-content-placeholder-mapper-iw-uk-p.svc.ft.com/complementarycontent/<uuid>
+func storedDocumentOf(contentURI string) (string, string, error) {
+	parts := strings.Split(strings.TrimSuffix(contentURI, "/"), "/")
+	if len(parts) < 2 || parts[len(parts)-1] == "" || parts[len(parts)-2] == "" {
+		return "", "", fmt.Errorf("couldn't find the collection and uuid of contentUri=%v", contentURI)
+	}
+	return parts[len(parts)-2], parts[len(parts)-1], nil
+}
+
+func toGenericJSON(payload interface{}) (interface{}, error) {
+	if payload == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	err = json.Unmarshal(data, &generic)
+	return generic, err
+}
+
+func diffValues(path string, stored, mapped interface{}, changes *[]fieldChange) {
+	storedObject, storedIsObject := stored.(map[string]interface{})
+	mappedObject, mappedIsObject := mapped.(map[string]interface{})
+	if storedIsObject && mappedIsObject {
+		diffObjects(path, storedObject, mappedObject, changes)
+		return
+	}
+	storedArray, storedIsArray := stored.([]interface{})
+	mappedArray, mappedIsArray := mapped.([]interface{})
+	if storedIsArray && mappedIsArray {
+		diffArrays(path, storedArray, mappedArray, changes)
+		return
+	}
+
+	switch {
+	case stored == nil && mapped == nil:
+	case stored == nil:
+		*changes = append(*changes, fieldChange{Path: path, Change: fieldAdded, Mapped: mapped})
+	case mapped == nil:
+		*changes = append(*changes, fieldChange{Path: path, Change: fieldRemoved, Stored: stored})
+	case !reflect.DeepEqual(stored, mapped):
+		*changes = append(*changes, fieldChange{Path: path, Change: fieldChanged, Stored: stored, Mapped: mapped})
+	}
+}
+
+func diffObjects(path string, stored, mapped map[string]interface{}, changes *[]fieldChange) {
+	keys := make(map[string]bool)
+	for key := range stored {
+		keys[key] = true
+	}
+	for key := range mapped {
+		keys[key] = true
+	}
+	sortedKeys := make([]string, 0, len(keys))
+	for key := range keys {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Strings(sortedKeys)
+
+	for _, key := range sortedKeys {
+		fieldPath := key
+		if path != "" {
+			fieldPath = path + "." + key
+		}
+		diffValues(fieldPath, stored[key], mapped[key], changes)
+	}
+}
+
+func diffArrays(path string, stored, mapped []interface{}, changes *[]fieldChange) {
+	length := len(stored)
+	if len(mapped) > length {
+		length = len(mapped)
+	}
+	for i := 0; i < length; i++ {
+		var storedItem, mappedItem interface{}
+		if i < len(stored) {
+			storedItem = stored[i]
+		}
+		if i < len(mapped) {
+			mappedItem = mapped[i]
+		}
+		diffValues(fmt.Sprintf("%s[%d]", path, i), storedItem, mappedItem, changes)
+	}
+}
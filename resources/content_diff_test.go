@@ -0,0 +1,97 @@
+package resources
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/message"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDiffValues(t *testing.T) {
+	stored := map[string]interface{}{
+		"title":  "Old title",
+		"type":   "Content",
+		"brands": []interface{}{map[string]interface{}{"id": "http://api.ft.com/things/1"}, map[string]interface{}{"id": "http://api.ft.com/things/2"}},
+		"extra":  false,
+	}
+	mapped := map[string]interface{}{
+		"title":    "New title",
+		"type":     "Content",
+		"brands":   []interface{}{map[string]interface{}{"id": "http://api.ft.com/things/1"}},
+		"standout": map[string]interface{}{"editorsChoice": true},
+	}
+
+	var changes []fieldChange
+	diffValues("", stored, mapped, &changes)
+
+	assert.Equal(t, []fieldChange{
+		{Path: "brands[1]", Change: fieldRemoved, Stored: map[string]interface{}{"id": "http://api.ft.com/things/2"}},
+		{Path: "extra", Change: fieldRemoved, Stored: false},
+		{Path: "standout", Change: fieldAdded, Mapped: map[string]interface{}{"editorsChoice": true}},
+		{Path: "title", Change: fieldChanged, Stored: "Old title", Mapped: "New title"},
+	}, changes)
+}
+
+func TestMapEndpointDiff(t *testing.T) {
+	docStore := new(model.MockDocStoreClient)
+	docStore.On("GetDocument", "content", "512c1f3d-e48c-4618-863c-94bc9d913b9b", mock.Anything).Return(map[string]interface{}{"uuid": "512c1f3d-e48c-4618-863c-94bc9d913b9b", "title": "Old title"}, true, nil)
+	docStore.On("GetDocument", "complementarycontent", "512c1f3d-e48c-4618-863c-94bc9d913b9b", mock.Anything).Return(nil, false, nil)
+
+	w := serveMapEndpointDiff(docStore)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var diffs []contentDiff
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&diffs))
+	assert.Len(t, diffs, 2)
+
+	assert.Equal(t, "content", diffs[0].Collection)
+	assert.True(t, diffs[0].Stored)
+	assert.Contains(t, diffs[0].Changes, fieldChange{Path: "title", Change: fieldChanged, Stored: "Old title", Mapped: "New title"})
+	assert.NotContains(t, diffs[0].Changes, fieldChange{Path: "uuid", Change: fieldChanged})
+
+	assert.Equal(t, "complementarycontent", diffs[1].Collection)
+	assert.False(t, diffs[1].Stored)
+	assert.Len(t, diffs[1].Changes, 1)
+	assert.Equal(t, fieldAdded, diffs[1].Changes[0].Change)
+}
+
+func TestMapEndpointDiff_DocumentStoreUnavailable(t *testing.T) {
+	docStore := new(model.MockDocStoreClient)
+	docStore.On("GetDocument", mock.Anything, mock.Anything, mock.Anything).Return(nil, false, model.NewTransientError("received status code=503"))
+
+	w := serveMapEndpointDiff(docStore)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var p problem
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+	assert.Equal(t, diffStage, p.Stage)
+}
+
+func serveMapEndpointDiff(docStore *model.MockDocStoreClient) *httptest.ResponseRecorder {
+	nativeMapper := new(model.MockNativeMapper)
+	nativeMapper.On("Map", mock.Anything).Return(&model.MethodeContentPlaceholder{UUID: "512c1f3d-e48c-4618-863c-94bc9d913b9b"}, nil)
+
+	aggregateMapper := new(model.MockCPHAggregateMapper)
+	aggregateMapper.On("MapContentPlaceholder", mock.Anything, mock.Anything, mock.Anything).Return([]model.UppContent{
+		&model.UppContentPlaceholder{
+			UppCoreContent: model.UppCoreContent{UUID: "512c1f3d-e48c-4618-863c-94bc9d913b9b", ContentURI: "http://methode-content-placeholder-mapper-iw-uk-p.svc.ft.com/content/"},
+			Title:          "New title",
+		},
+		&model.UppComplementaryContent{
+			UppCoreContent: model.UppCoreContent{UUID: "512c1f3d-e48c-4618-863c-94bc9d913b9b", ContentURI: "http://methode-content-placeholder-mapper-iw-uk-p.svc.ft.com/complementarycontent/"},
+		},
+	}, nil)
+
+	mapHandler := NewMapEndpointHandler(aggregateMapper, message.NewDefaultCPHMessageCreator(), nativeMapper)
+	mapHandler.DocStore = docStore
+
+	w := httptest.NewRecorder()
+	mapHandler.ServeMapEndpoint(w, httptest.NewRequest("POST", mapperURL+"?diff=true", bytes.NewReader(nil)))
+	return w
+}
@@ -1,8 +1,8 @@
 package resources
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"io/ioutil"
 	"net/http"
 	"time"
@@ -15,6 +15,11 @@ import (
 )
 
 type MapEndpointHandler struct {
+	BatchWorkers int
+	DocStore     mapper.DocStoreClient
+	// RequestTimeout, when set, is the deadline of the mapping of a /map request, or of each placeholder of a /map/batch request
+	RequestTimeout time.Duration
+
 	aggregateMapper   mapper.CPHAggregateMapper
 	nativeMapper      mapper.MessageToContentPlaceholderMapper
 	cphMessageCreator message.MessageCreator
@@ -26,6 +31,7 @@ type msg struct {
 
 func NewMapEndpointHandler(aggregateMapper mapper.CPHAggregateMapper, messageCreator message.MessageCreator, nativeMapper mapper.MessageToContentPlaceholderMapper) *MapEndpointHandler {
 	return &MapEndpointHandler{
+		BatchWorkers:      defaultBatchWorkers,
 		aggregateMapper:   aggregateMapper,
 		cphMessageCreator: messageCreator,
 		nativeMapper:      nativeMapper,
@@ -39,41 +45,103 @@ func (h *MapEndpointHandler) ServeMapEndpoint(w http.ResponseWriter, r *http.Req
 
 	messageBody, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		writeError(w, err, tid, "Could not read messageBody from request.", r.RequestURI)
+		writeProblem(w, &problem{
+			Type:          problemTypeUnreadableRequest,
+			Title:         "Could not read messageBody from request",
+			Status:        http.StatusBadRequest,
+			Detail:        err.Error(),
+			Instance:      r.RequestURI,
+			Stage:         model.MappingStageNativeParse,
+			TransactionID: tid,
+		})
 		return
 	}
-	methodePlaceholder, err := h.nativeMapper.Map(messageBody)
-	if err != nil {
-		writeError(w, err, tid, "Could not map request body to intermediate model.", r.RequestURI)
+	ctx, cancel := h.mappingContext(r)
+	defer cancel()
+	t := h.transform(ctx, messageBody, tid, lmd, r.RequestURI)
+	if t.problem != nil {
+		writeProblem(w, t.problem)
+		return
+	}
+	if t.deleted {
+		writeMessageForDeletedContent(w, tid, t.uuid, r.RequestURI)
 		return
 	}
 
-	if methodePlaceholder.Attributes.IsDeleted {
-		writeMessageForDeletedContent(w, tid, methodePlaceholder.UUID, r.RequestURI)
+	if r.URL.Query().Get("diff") == "true" {
+		h.writeDiff(ctx, w, t, tid, r.RequestURI)
 		return
 	}
 
-	transformedContents, err := h.aggregateMapper.MapContentPlaceholder(methodePlaceholder, tid, lmd)
+	w.Header().Add("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.Encode(t.events)
+	log.WithField("transaction_id", tid).WithField("uuid", t.uuid).WithField("request_uri", r.RequestURI).Info("Transformation successful")
+}
+
+// writeDiff returns what publishing the mapped placeholder would change in document-store-api, without publishing it
+func (h *MapEndpointHandler) writeDiff(ctx context.Context, w http.ResponseWriter, t transformation, tid, requestURI string) {
+	if h.DocStore == nil {
+		writeProblem(w, &problem{
+			Type:          problemTypeDependencyUnavailable,
+			Title:         "Diff mode unavailable",
+			Status:        http.StatusServiceUnavailable,
+			Detail:        "no document-store-api client is configured",
+			Instance:      requestURI,
+			Stage:         diffStage,
+			TransactionID: tid,
+			UUID:          t.uuid,
+		})
+		return
+	}
+	diffs, err := h.diffWithDocumentStore(ctx, t.events, tid)
 	if err != nil {
-		writeError(w, err, tid, "Error mapping model from queue message.", r.RequestURI)
+		writeProblem(w, newMappingProblem(err, diffStage, tid, t.uuid, requestURI))
 		return
 	}
 
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diffs)
+	log.WithField("transaction_id", tid).WithField("uuid", t.uuid).WithField("request_uri", requestURI).Info("Diff with document-store-api successful")
+}
+
+// transformation is the outcome of mapping a native placeholder: its publication events, or the problem which prevented the mapping
+type transformation struct {
+	uuid    string
+	events  []model.PublicationEvent
+	deleted bool
+	problem *problem
+}
+
+// mappingContext returns the context of a mapping, done when the caller goes away or the RequestTimeout elapses
+func (h *MapEndpointHandler) mappingContext(r *http.Request) (context.Context, context.CancelFunc) {
+	if h.RequestTimeout <= 0 {
+		return context.WithCancel(r.Context())
+	}
+	return context.WithTimeout(r.Context(), h.RequestTimeout)
+}
+
+func (h *MapEndpointHandler) transform(ctx context.Context, messageBody []byte, tid, lmd, instance string) transformation {
+	methodePlaceholder, err := h.nativeMapper.Map(messageBody)
+	if err != nil {
+		return transformation{problem: newMappingProblem(err, model.MappingStageNativeParse, tid, "", instance)}
+	}
+
+	if methodePlaceholder.Attributes.IsDeleted {
+		return transformation{uuid: methodePlaceholder.UUID, deleted: true}
+	}
+
+	transformedContents, err := h.aggregateMapper.MapContentPlaceholder(ctx, methodePlaceholder, tid, lmd)
+	if err != nil {
+		return transformation{uuid: methodePlaceholder.UUID, problem: newMappingProblem(err, model.MappingStageContentMapping, tid, methodePlaceholder.UUID, instance)}
+	}
+
 	var pubEvents []model.PublicationEvent
 	for _, transformedContent := range transformedContents {
 		pubEvent := h.cphMessageCreator.ToPublicationEvent(transformedContent.GetUppCoreContent(), transformedContent)
 		pubEvents = append(pubEvents, *pubEvent)
 	}
-
-	w.Header().Add("Content-Type", "application/json")
-	encoder := json.NewEncoder(w)
-	encoder.Encode(pubEvents)
-	log.WithField("transaction_id", tid).WithField("uuid", methodePlaceholder.UUID).WithField("request_uri", r.RequestURI).Info("Transformation successful")
-}
-
-func writeError(w http.ResponseWriter, err error, transactionID, uuid, requestURI string) {
-	log.WithField("transaction_id", transactionID).WithField("uuid", uuid).WithField("request_uri", requestURI).WithError(err).Error(fmt.Sprintf("Returned HTTP status: %v", http.StatusUnprocessableEntity))
-	http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	return transformation{uuid: methodePlaceholder.UUID, events: pubEvents}
 }
 
 func writeMessageForDeletedContent(w http.ResponseWriter, transactionID, uuid, requestURI string) {
@@ -2,12 +2,14 @@ package resources
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/Financial-Times/message-queue-gonsumer/consumer"
 	"github.com/Financial-Times/methode-content-placeholder-mapper/message"
@@ -120,6 +122,132 @@ func TestMapEndpointFailedTransformation_Returns422(t *testing.T) {
 	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, "It should return status 422")
 }
 
+func TestMapEndpointInvalidPlaceholder_Returns422WithValidationReport(t *testing.T) {
+	aggregateMapper := new(model.MockCPHAggregateMapper)
+	nativeMapper := new(model.MockNativeMapper)
+	messageCreator := message.NewDefaultCPHMessageCreator()
+
+	report := model.ValidationReport{}
+	report.AddError("body.leadHeadline.text", model.CodeMissingHeadlineText, "Methode Content headline does not contain text")
+	report.AddError("attributes.lastPublicationDate", model.CodeInvalidPublicationDate, "Methode Content publication date is not in the 20060102150405 format")
+
+	nativeMapper.On("Map", mock.MatchedBy(func([]byte) bool { return true })).Return(&model.MethodeContentPlaceholder{}, nil)
+	aggregateMapper.On("MapContentPlaceholder", mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true }),
+		mock.MatchedBy(func(string) bool { return true }),
+		mock.MatchedBy(func(string) bool { return true })).Return([]model.UppContent{}, report.Err())
+
+	mapHandler := NewMapEndpointHandler(aggregateMapper, messageCreator, nativeMapper)
+
+	req := httptest.NewRequest("POST", mapperURL, bytes.NewReader([]byte(nil)))
+	w := httptest.NewRecorder()
+	mapHandler.ServeMapEndpoint(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, "It should return status 422")
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	var p problem
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+	assert.Equal(t, "urn:ft:mcpm:problem:invalid-placeholder", p.Type)
+	assert.Equal(t, model.MappingStageContentMapping, p.Stage)
+	assert.Equal(t, &report, p.Validation)
+}
+
+func TestMapEndpointFailedNativeTransformation_ReturnsProblem(t *testing.T) {
+	nativeMapper := new(model.MockNativeMapper)
+	nativeMapper.On("Map", mock.MatchedBy(func([]byte) bool { return true })).Return(&model.MethodeContentPlaceholder{}, fmt.Errorf("Error decoding or unmarshalling methode body."))
+
+	mapHandler := NewMapEndpointHandler(new(model.MockCPHAggregateMapper), message.NewDefaultCPHMessageCreator(), nativeMapper)
+
+	req := httptest.NewRequest("POST", mapperURL, bytes.NewReader([]byte(nil)))
+	req.Header.Set("X-Request-Id", expectedTransactionID)
+	w := httptest.NewRecorder()
+	mapHandler.ServeMapEndpoint(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var p problem
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+	assert.Equal(t, problem{
+		Type:          "urn:ft:mcpm:problem:invalid-placeholder",
+		Title:         "Invalid content placeholder",
+		Status:        http.StatusUnprocessableEntity,
+		Detail:        "Error decoding or unmarshalling methode body.",
+		Instance:      mapperURL,
+		Stage:         model.MappingStageNativeParse,
+		TransactionID: expectedTransactionID,
+	}, p)
+}
+
+func TestMapEndpointDocumentStoreUnavailable_Returns503(t *testing.T) {
+	w := serveMapEndpointWithMappingError(model.WithStage(model.MappingStageIdentifierResolution, fmt.Errorf("couldn't resolve blog uuid: %w", model.NewTransientError("received status code=503"))))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var p problem
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+	assert.Equal(t, "urn:ft:mcpm:problem:dependency-unavailable", p.Type)
+	assert.Equal(t, model.MappingStageIdentifierResolution, p.Stage)
+	assert.Nil(t, p.Validation)
+}
+
+func TestMapEndpointDocumentStoreFailure_Returns502(t *testing.T) {
+	w := serveMapEndpointWithMappingError(model.WithStage(model.MappingStageContentMapping, model.NewDependencyError("received status code=400")))
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	var p problem
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+	assert.Equal(t, "urn:ft:mcpm:problem:dependency-failure", p.Type)
+	assert.Equal(t, model.MappingStageContentMapping, p.Stage)
+}
+
+func TestMapEndpointBrandNotFound_Returns422(t *testing.T) {
+	brandErr := model.WithStage(model.MappingStageBrandLookup, fmt.Errorf("couldn't find authority in mapping table"))
+	w := serveMapEndpointWithMappingError(model.WithStage(model.MappingStageIdentifierResolution, fmt.Errorf("couldn't resolve blog uuid: %w", brandErr)))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var p problem
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+	assert.Equal(t, model.MappingStageBrandLookup, p.Stage)
+}
+
+// deadlineAwaitingMapper fails once the context of the mapping is done
+type deadlineAwaitingMapper struct{}
+
+func (deadlineAwaitingMapper) MapContentPlaceholder(ctx context.Context, mpc *model.MethodeContentPlaceholder, tid, lmd string) ([]model.UppContent, error) {
+	<-ctx.Done()
+	return nil, model.WithStage(model.MappingStageIdentifierResolution, fmt.Errorf("couldn't resolve blog uuid: %w", ctx.Err()))
+}
+
+func TestMapEndpointRequestTimeout_Returns504(t *testing.T) {
+	nativeMapper := new(model.MockNativeMapper)
+	nativeMapper.On("Map", mock.MatchedBy(func([]byte) bool { return true })).Return(&model.MethodeContentPlaceholder{UUID: "512c1f3d-e48c-4618-863c-94bc9d913b9b"}, nil)
+	mapHandler := NewMapEndpointHandler(deadlineAwaitingMapper{}, message.NewDefaultCPHMessageCreator(), nativeMapper)
+	mapHandler.RequestTimeout = 10 * time.Millisecond
+
+	req := httptest.NewRequest("POST", mapperURL, bytes.NewReader([]byte(nil)))
+	w := httptest.NewRecorder()
+	mapHandler.ServeMapEndpoint(w, req)
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	var p problem
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+	assert.Equal(t, "urn:ft:mcpm:problem:timeout", p.Type)
+	assert.Equal(t, model.MappingStageIdentifierResolution, p.Stage)
+}
+
+func serveMapEndpointWithMappingError(err error) *httptest.ResponseRecorder {
+	aggregateMapper := new(model.MockCPHAggregateMapper)
+	nativeMapper := new(model.MockNativeMapper)
+	nativeMapper.On("Map", mock.MatchedBy(func([]byte) bool { return true })).Return(&model.MethodeContentPlaceholder{UUID: "512c1f3d-e48c-4618-863c-94bc9d913b9b"}, nil)
+	aggregateMapper.On("MapContentPlaceholder", mock.MatchedBy(func(mpc *model.MethodeContentPlaceholder) bool { return true }),
+		mock.MatchedBy(func(string) bool { return true }),
+		mock.MatchedBy(func(string) bool { return true })).Return([]model.UppContent{}, err)
+
+	mapHandler := NewMapEndpointHandler(aggregateMapper, message.NewDefaultCPHMessageCreator(), nativeMapper)
+
+	req := httptest.NewRequest("POST", mapperURL, bytes.NewReader([]byte(nil)))
+	w := httptest.NewRecorder()
+	mapHandler.ServeMapEndpoint(w, req)
+	return w
+}
+
 func buildIgMethodePlaceholderUpdateMsg() consumer.Message {
 	return buildMethodeMsg("../mapper/test_resources/methode_cph_update.json")
 }
@@ -0,0 +1,22 @@
+package resources
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/mapper"
+)
+
+type DocStoreCacheStatsHandler struct {
+	cache *mapper.CachingDocStoreClient
+}
+
+func NewDocStoreCacheStatsHandler(cache *mapper.CachingDocStoreClient) *DocStoreCacheStatsHandler {
+	return &DocStoreCacheStatsHandler{cache: cache}
+}
+
+// ServeDocStoreCacheStats returns the hits, misses and size of the document-store-api cache
+func (h *DocStoreCacheStatsHandler) ServeDocStoreCacheStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.cache.Stats())
+}
@@ -4,22 +4,26 @@ import (
 	"net/http"
 
 	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
-	"github.com/Financial-Times/message-queue-go-producer/producer"
 	"github.com/Financial-Times/message-queue-gonsumer/consumer"
 	"github.com/Financial-Times/methode-content-placeholder-mapper/mapper"
 	"github.com/Financial-Times/service-status-go/gtg"
 )
 
+// ConnectivityChecker is a dependency whose connection can be checked, like a producer
+type ConnectivityChecker interface {
+	ConnectivityCheck() (string, error)
+}
+
 // MapperHealthcheck represents the health check for the methode content placeholder mapper
 type MapperHealthcheck struct {
 	Client   *http.Client
 	consumer consumer.MessageConsumer
-	producer producer.MessageProducer
+	producer ConnectivityChecker
 	docStore mapper.DocStoreClient
 }
 
 // NewMapperHealthcheck returns a new instance of the MapperHealthcheck
-func NewMapperHealthcheck(c consumer.MessageConsumer, p producer.MessageProducer, d mapper.DocStoreClient) *MapperHealthcheck {
+func NewMapperHealthcheck(c consumer.MessageConsumer, p ConnectivityChecker, d mapper.DocStoreClient) *MapperHealthcheck {
 	return &MapperHealthcheck{
 		consumer: c,
 		producer: p,
@@ -85,7 +89,33 @@ func (hc *MapperHealthcheck) DocumentStoreConnectivityCheck() fthealth.Check {
 		Name:             "DocumentStoreApiReachable",
 		PanicGuide:       "https://dewey.ft.com/up-mcpm.html",
 		Severity:         2,
-		TechnicalSummary: "document-store-api is not reachable/healthy",
+		TechnicalSummary: "document-store-api is not reachable/healthy, or the circuit breaker around it is open",
 		Checker:          hc.docStore.ConnectivityCheck,
 	}
 }
+
+// AddressConnectivityChecker is a dependency reached at several addresses whose connections can be checked one by one
+type AddressConnectivityChecker interface {
+	Addresses() []string
+	CheckAddress(address string) (string, error)
+}
+
+// DocumentStoreAddressChecks returns a Check of every document-store-api address.
+// A failing address is ejected from the load balancing until it passes again.
+func (hc *MapperHealthcheck) DocumentStoreAddressChecks(d AddressConnectivityChecker) []fthealth.Check {
+	var checks []fthealth.Check
+	for _, address := range d.Addresses() {
+		address := address
+		checks = append(checks, fthealth.Check{
+			BusinessImpact:   "No impact while another document-store-api address is healthy, calls are sent to the healthy addresses",
+			Name:             "DocumentStoreApiReachable-" + address,
+			PanicGuide:       "https://dewey.ft.com/up-mcpm.html",
+			Severity:         3,
+			TechnicalSummary: "document-store-api at " + address + " is not reachable/healthy, calls are not sent to it",
+			Checker: func() (string, error) {
+				return d.CheckAddress(address)
+			},
+		})
+	}
+	return checks
+}
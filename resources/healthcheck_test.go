@@ -1,15 +1,18 @@
 package resources
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
 	"github.com/Financial-Times/message-queue-go-producer/producer"
 	"github.com/Financial-Times/message-queue-gonsumer/consumer"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/mapper"
 	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
 	"github.com/stretchr/testify/assert"
 )
@@ -351,6 +354,25 @@ func TestGTG(t *testing.T) {
 	assert.True(t, status.GoodToGo)
 }
 
+func TestGTGDocStoreCircuitBreakerOpen(t *testing.T) {
+	kafka := setupMockKafka(t, 200)
+	defer kafka.Close()
+
+	dockStoreMockClient := new(model.MockDocStoreClient)
+	dockStoreMockClient.On("ConnectivityCheck").Return("OK", nil)
+	dockStoreMockClient.On("ContentExists", "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123").Return(false, model.NewTransientError("connection refused"))
+	breaker := mapper.NewCircuitBreakerDocStoreClient(dockStoreMockClient, mapper.DocStoreBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute})
+	breaker.ContentExists(context.Background(), "512c1f3d-e48c-4618-863c-94bc9d913b9b", "tid_test123")
+
+	hc := NewMapperHealthcheck(getMockedConsumer([]string{kafka.URL}), getMockedProducer(kafka.URL), breaker)
+
+	status := hc.GTG()
+
+	assert.False(t, status.GoodToGo)
+	assert.Contains(t, status.Message, "circuit breaker open")
+	dockStoreMockClient.AssertNotCalled(t, "ConnectivityCheck")
+}
+
 func TestGTGConsumerFailing(t *testing.T) {
 	kafka1 := setupMockKafka(t, 503)
 	defer kafka1.Close()
@@ -399,6 +421,30 @@ func TestGTGDocStoreFailing(t *testing.T) {
 	assert.False(t, status.GoodToGo)
 }
 
+func TestDocumentStoreAddressChecks(t *testing.T) {
+	healthyDocStore := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/__gtg", req.URL.Path)
+	}))
+	defer healthyDocStore.Close()
+	failingDocStore := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failingDocStore.Close()
+	docStore := mapper.NewHttpDocStoreClient(http.DefaultClient, healthyDocStore.URL, failingDocStore.URL)
+
+	hc := NewMapperHealthcheck(getMockedConsumer([]string{"http://a-fake-url"}), getMockedProducer("http://a-fake-url"), docStore)
+	checks := hc.DocumentStoreAddressChecks(docStore)
+
+	assert.Len(t, checks, 2)
+	assert.Equal(t, "DocumentStoreApiReachable-"+healthyDocStore.URL, checks[0].Name)
+	_, err := checks[0].Checker()
+	assert.NoError(t, err)
+	assert.Equal(t, "DocumentStoreApiReachable-"+failingDocStore.URL, checks[1].Name)
+	_, err = checks[1].Checker()
+	assert.Error(t, err)
+	assert.Equal(t, []bool{true, false}, []bool{docStore.AddressStatuses()[0].Healthy, docStore.AddressStatuses()[1].Healthy}, "The failing address should be ejected")
+}
+
 func getMockedConsumer(addr []string) consumer.MessageConsumer {
 	return consumer.NewConsumer(
 		consumer.QueueConfig{
@@ -0,0 +1,81 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	log "github.com/Sirupsen/logrus"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem types returned by the /map endpoint
+const (
+	problemTypeUnreadableRequest     = "urn:ft:mcpm:problem:unreadable-request"
+	problemTypeInvalidPlaceholder    = "urn:ft:mcpm:problem:invalid-placeholder"
+	problemTypeDependencyFailure     = "urn:ft:mcpm:problem:dependency-failure"
+	problemTypeDependencyUnavailable = "urn:ft:mcpm:problem:dependency-unavailable"
+	problemTypeTimeout               = "urn:ft:mcpm:problem:timeout"
+)
+
+// problem is an RFC 7807 problem details object, extended with the mapping stage that failed
+type problem struct {
+	Type          string                  `json:"type"`
+	Title         string                  `json:"title"`
+	Status        int                     `json:"status"`
+	Detail        string                  `json:"detail"`
+	Instance      string                  `json:"instance,omitempty"`
+	Stage         string                  `json:"stage"`
+	TransactionID string                  `json:"transactionId"`
+	UUID          string                  `json:"uuid,omitempty"`
+	Validation    *model.ValidationReport `json:"validation,omitempty"`
+}
+
+// newMappingProblem describes a mapping failure. The stage recorded in err wins over the given default stage.
+// Mappings past their deadline or abandoned are reported as 504, dependency outages as 503, unusable dependency responses as 502,
+// and problems of the placeholder itself as 422.
+func newMappingProblem(err error, defaultStage, tid, uuid, instance string) *problem {
+	p := &problem{
+		Type:          problemTypeInvalidPlaceholder,
+		Title:         "Invalid content placeholder",
+		Status:        http.StatusUnprocessableEntity,
+		Detail:        err.Error(),
+		Instance:      instance,
+		Stage:         defaultStage,
+		TransactionID: tid,
+		UUID:          uuid,
+	}
+	if stage := model.StageOf(err); stage != "" {
+		p.Stage = stage
+	}
+	if validationErr, ok := model.AsValidationError(err); ok {
+		p.Validation = &validationErr.Report
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled):
+		p.Type = problemTypeTimeout
+		p.Title = "Mapping timed out"
+		p.Status = http.StatusGatewayTimeout
+	case model.IsTransient(err):
+		p.Type = problemTypeDependencyUnavailable
+		p.Title = "Dependency unavailable"
+		p.Status = http.StatusServiceUnavailable
+	case model.IsDependencyFailure(err):
+		p.Type = problemTypeDependencyFailure
+		p.Title = "Dependency failure"
+		p.Status = http.StatusBadGateway
+	}
+	return p
+}
+
+func writeProblem(w http.ResponseWriter, p *problem) {
+	log.WithField("transaction_id", p.TransactionID).WithField("uuid", p.UUID).WithField("request_uri", p.Instance).WithField("stage", p.Stage).Errorf("Returned HTTP status: %v - %v", p.Status, p.Detail)
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Request-ID", p.TransactionID)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
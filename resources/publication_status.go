@@ -0,0 +1,32 @@
+package resources
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/handler"
+	"github.com/gorilla/mux"
+)
+
+type PublicationStatusHandler struct {
+	publications handler.PublicationLog
+}
+
+func NewPublicationStatusHandler(publications handler.PublicationLog) *PublicationStatusHandler {
+	return &PublicationStatusHandler{publications: publications}
+}
+
+// ServePublicationStatus returns the outcome of the latest publication of the given UUID
+func (h *PublicationStatusHandler) ServePublicationStatus(w http.ResponseWriter, r *http.Request) {
+	uuid := mux.Vars(r)["uuid"]
+	w.Header().Add("Content-Type", "application/json")
+
+	outcome, found := h.publications.Get(uuid)
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		data, _ := json.Marshal(&msg{Message: "No publication recorded for " + uuid})
+		w.Write(data)
+		return
+	}
+	json.NewEncoder(w).Encode(outcome)
+}
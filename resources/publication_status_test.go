@@ -0,0 +1,46 @@
+package resources
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/handler"
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublicationStatus_Ok(t *testing.T) {
+	publications := handler.NewInMemoryPublicationLog(10)
+	publications.Record(model.PublicationOutcome{
+		UUID:          "512c1f3d-e48c-4618-863c-94bc9d913b9b",
+		TransactionID: "tid_test123",
+		Status:        model.PublicationIncomplete,
+		Published:     []string{"http://methode-content-placeholder-mapper-iw-uk-p.svc.ft.com/content/512c1f3d-e48c-4618-863c-94bc9d913b9b"},
+		Unpublished:   []string{"http://methode-content-placeholder-mapper-iw-uk-p.svc.ft.com/complementarycontent/512c1f3d-e48c-4618-863c-94bc9d913b9b"},
+	})
+
+	w := servePublicationStatus(publications, "512c1f3d-e48c-4618-863c-94bc9d913b9b")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var outcome model.PublicationOutcome
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&outcome))
+	assert.Equal(t, model.PublicationIncomplete, outcome.Status)
+	assert.Equal(t, "tid_test123", outcome.TransactionID)
+}
+
+func TestPublicationStatus_NotFound(t *testing.T) {
+	w := servePublicationStatus(handler.NewInMemoryPublicationLog(10), "512c1f3d-e48c-4618-863c-94bc9d913b9b")
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func servePublicationStatus(publications handler.PublicationLog, uuid string) *httptest.ResponseRecorder {
+	r := mux.NewRouter()
+	r.HandleFunc("/__publications/{uuid}", NewPublicationStatusHandler(publications).ServePublicationStatus)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/__publications/"+uuid, nil))
+	return w
+}
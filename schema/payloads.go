@@ -0,0 +1,64 @@
+package schema
+
+import (
+	"path/filepath"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+)
+
+// Files of the bundled schemas of the payloads of the publication events
+const (
+	ContentPlaceholderSchemaFile   = "content-placeholder.json"
+	ComplementaryContentSchemaFile = "complementary-content.json"
+)
+
+// PayloadValidator checks the payloads of the publication events against the schemas of the content UPP writers accept
+type PayloadValidator struct {
+	contentPlaceholder   *Schema
+	complementaryContent *Schema
+}
+
+// ReadPayloadSchemas loads the payload schemas from a directory
+func ReadPayloadSchemas(dir string) (*PayloadValidator, error) {
+	contentPlaceholder, err := ReadFile(filepath.Join(dir, ContentPlaceholderSchemaFile))
+	if err != nil {
+		return nil, err
+	}
+	complementaryContent, err := ReadFile(filepath.Join(dir, ComplementaryContentSchemaFile))
+	if err != nil {
+		return nil, err
+	}
+	return &PayloadValidator{contentPlaceholder: contentPlaceholder, complementaryContent: complementaryContent}, nil
+}
+
+// Validate reports the schema violations of the payload of a mapped content as errors.
+// Deleted content is published without payload, so it is never reported.
+func (v *PayloadValidator) Validate(content model.UppContent) (model.ValidationReport, error) {
+	var report model.ValidationReport
+	if content.GetUppCoreContent().IsMarkedDeleted {
+		return report, nil
+	}
+	var s *Schema
+	var prefix string
+	switch content.(type) {
+	case *model.UppContentPlaceholder:
+		s, prefix = v.contentPlaceholder, "contentPlaceholder"
+	case *model.UppComplementaryContent:
+		s, prefix = v.complementaryContent, "complementaryContent"
+	default:
+		return report, nil
+	}
+
+	violations, err := s.ValidateValue(content)
+	if err != nil {
+		return report, err
+	}
+	for _, violation := range violations {
+		field := prefix
+		if violation.Path != "$" {
+			field += "." + violation.Path
+		}
+		report.AddError(field, model.CodeSchemaViolation, violation.Message)
+	}
+	return report, nil
+}
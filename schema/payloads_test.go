@@ -0,0 +1,149 @@
+package schema
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Financial-Times/methode-content-placeholder-mapper/model"
+	"github.com/stretchr/testify/assert"
+)
+
+const bundledSchemasDir = "../schemas"
+
+func TestBundledSchemas_GoldenFixtures(t *testing.T) {
+	validator, err := ReadPayloadSchemas(bundledSchemasDir)
+	assert.NoError(t, err)
+
+	fixtures, err := filepath.Glob("../mapper/test_resources/upp_cph_*.json")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, fixtures)
+	for _, fixture := range fixtures {
+		data, err := ioutil.ReadFile(fixture)
+		assert.NoError(t, err)
+		var event struct {
+			ContentURI string      `json:"contentUri"`
+			Payload    interface{} `json:"payload"`
+		}
+		assert.NoError(t, json.Unmarshal(data, &event), fixture)
+		if event.Payload == nil {
+			continue
+		}
+
+		s := validator.contentPlaceholder
+		if strings.Contains(event.ContentURI, "/complementarycontent/") {
+			s = validator.complementaryContent
+		}
+		assert.Empty(t, s.Validate(event.Payload), fixture)
+	}
+}
+
+func TestPayloadValidator_MappedContent(t *testing.T) {
+	validator, err := ReadPayloadSchemas(bundledSchemasDir)
+	assert.NoError(t, err)
+
+	placeholder := &model.UppContentPlaceholder{
+		UppCoreContent: model.UppCoreContent{
+			UUID:             "512c1f3d-e48c-4618-863c-94bc9d913b9b",
+			PublishReference: "tid_test123",
+			LastModified:     "2017-05-15T15:54:32.166Z",
+		},
+		PublishedDate:    "2017-05-15T15:54:32.000Z",
+		Title:            "Interactive: The Virgin empire",
+		Identifiers:      []model.Identifier{{Authority: "http://api.ft.com/system/FTCOM-METHODE", IdentifierValue: "512c1f3d-e48c-4618-863c-94bc9d913b9b"}},
+		Brands:           model.BuildBrands(),
+		WebURL:           "https://www.ft.com/content/512c1f3d-e48c-4618-863c-94bc9d913b9b",
+		CanonicalWebUrl:  "https://www.ft.com/content/512c1f3d-e48c-4618-863c-94bc9d913b9b",
+		Type:             "Content",
+		CanBeSyndicated:  "verify",
+		CanBeDistributed: "verify",
+	}
+	report, err := validator.Validate(placeholder)
+	assert.NoError(t, err)
+	assert.Empty(t, report.Issues)
+
+	complementaryContent := &model.UppComplementaryContent{
+		UppCoreContent: model.UppCoreContent{
+			UUID:             "512c1f3d-e48c-4618-863c-94bc9d913b9b",
+			PublishReference: "tid_test123",
+			LastModified:     "2017-05-15T15:54:32.166Z",
+		},
+		Brands: model.BuildBrands(),
+		Type:   "Content",
+	}
+	report, err = validator.Validate(complementaryContent)
+	assert.NoError(t, err)
+	assert.Empty(t, report.Issues)
+}
+
+func TestPayloadValidator_ReportsViolationsAsErrors(t *testing.T) {
+	validator, err := ReadPayloadSchemas(bundledSchemasDir)
+	assert.NoError(t, err)
+
+	complementaryContent := &model.UppComplementaryContent{
+		UppCoreContent: model.UppCoreContent{
+			UUID:             "512c1f3d-e48c-4618-863c-94bc9d913b9b",
+			PublishReference: "tid_test123",
+			LastModified:     "2017-05-15T15:54:32.166Z",
+		},
+		AlternativeImages: &model.AlternativeImages{PromotionalImage: &model.PromotionalImage{Id: "8f7b3e6a-327b-11e3-91d2-00144feab7de"}},
+		Type:              "Article",
+	}
+	report, err := validator.Validate(complementaryContent)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.ValidationIssue{
+		{Field: "complementaryContent.alternativeImages.promotionalImage.id", Code: model.CodeSchemaViolation, Severity: model.SeverityError, Message: "should be a uri"},
+		{Field: "complementaryContent.brands", Code: model.CodeSchemaViolation, Severity: model.SeverityError, Message: "should be array, not null"},
+		{Field: "complementaryContent.type", Code: model.CodeSchemaViolation, Severity: model.SeverityError, Message: "should be one of [Content]"},
+	}, report.Issues)
+}
+
+func TestPayloadValidator_UPPDates(t *testing.T) {
+	validator, err := ReadPayloadSchemas(bundledSchemasDir)
+	assert.NoError(t, err)
+
+	for lastModified, valid := range map[string]bool{
+		"2017-05-15T15:54:32.166Z":      true,
+		"2017-05-15T15:54:32.166+0100":  true,
+		"2017-05-15T15:54:32.166+01:00": false,
+		"2017-05-15T15:54:32Z":          false,
+	} {
+		report, err := validator.Validate(&model.UppComplementaryContent{
+			UppCoreContent: model.UppCoreContent{UUID: "512c1f3d-e48c-4618-863c-94bc9d913b9b", PublishReference: "tid_test123", LastModified: lastModified},
+			Brands:         model.BuildBrands(),
+			Type:           "Content",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, valid, len(report.Issues) == 0, lastModified)
+	}
+}
+
+func TestBundledSchemas_NullAlternativesRejected(t *testing.T) {
+	s, err := ReadFile(filepath.Join(bundledSchemasDir, ComplementaryContentSchemaFile))
+	assert.NoError(t, err)
+
+	violations := s.Validate(map[string]interface{}{
+		"uuid":              "512c1f3d-e48c-4618-863c-94bc9d913b9b",
+		"publishReference":  "tid_test123",
+		"lastModified":      "2017-05-15T15:54:32.166Z",
+		"alternativeTitles": nil,
+		"brands":            []interface{}{},
+		"type":              "Content",
+	})
+
+	assert.Len(t, violations, 1)
+	assert.Equal(t, "alternativeTitles", violations[0].Path)
+}
+
+func TestPayloadValidator_DeletedContentNotValidated(t *testing.T) {
+	validator, err := ReadPayloadSchemas(bundledSchemasDir)
+	assert.NoError(t, err)
+
+	report, err := validator.Validate(&model.UppContentPlaceholder{UppCoreContent: model.UppCoreContent{UUID: "512c1f3d-e48c-4618-863c-94bc9d913b9b", IsMarkedDeleted: true}})
+
+	assert.NoError(t, err)
+	assert.Empty(t, report.Issues)
+}
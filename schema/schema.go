@@ -0,0 +1,287 @@
+// Package schema validates JSON documents against the subset of JSON Schema (draft-07) used by the bundled UPP schemas:
+// type, properties, required, additionalProperties, items, enum, pattern, minLength, minItems and format
+// (date-time, uri and uuid). A schema using any other validation keyword is rejected when it is compiled.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// annotations are the keywords which don't affect validation
+var annotations = map[string]bool{"$schema": true, "$id": true, "title": true, "description": true, "$comment": true, "examples": true}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Violation is a part of a document which doesn't conform to its schema
+type Violation struct {
+	// Path locates the value in the document, e.g. alternativeTitles.promotionalTitle or identifiers[0], $ for the document itself
+	Path    string
+	Message string
+}
+
+// Schema is a compiled JSON schema
+type Schema struct {
+	types                []string
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *bool
+	items                *Schema
+	enum                 []interface{}
+	pattern              *regexp.Regexp
+	minLength            *int
+	minItems             *int
+	format               string
+}
+
+type schemaDocument struct {
+	Type                 interface{}                `json:"type"`
+	Properties           map[string]json.RawMessage `json:"properties"`
+	Required             []string                   `json:"required"`
+	AdditionalProperties *bool                      `json:"additionalProperties"`
+	Items                json.RawMessage            `json:"items"`
+	Enum                 []interface{}              `json:"enum"`
+	Pattern              string                     `json:"pattern"`
+	MinLength            *int                       `json:"minLength"`
+	MinItems             *int                       `json:"minItems"`
+	Format               string                     `json:"format"`
+}
+
+var knownKeywords = map[string]bool{"type": true, "properties": true, "required": true, "additionalProperties": true,
+	"items": true, "enum": true, "pattern": true, "minLength": true, "minItems": true, "format": true}
+
+// Compile parses a JSON schema
+func Compile(data []byte) (*Schema, error) {
+	return compile(data, "$")
+}
+
+// ReadFile compiles the JSON schema in a file
+func ReadFile(path string) (*Schema, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Compile(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema %v: %v", path, err)
+	}
+	return s, nil
+}
+
+func compile(data []byte, path string) (*Schema, error) {
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keywords); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	for keyword := range keywords {
+		if !knownKeywords[keyword] && !annotations[keyword] {
+			return nil, fmt.Errorf("%v: unsupported keyword %q", path, keyword)
+		}
+	}
+	var doc schemaDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+
+	s := &Schema{
+		required:             doc.Required,
+		additionalProperties: doc.AdditionalProperties,
+		enum:                 doc.Enum,
+		minLength:            doc.MinLength,
+		minItems:             doc.MinItems,
+		format:               doc.Format,
+	}
+	switch t := doc.Type.(type) {
+	case nil:
+	case string:
+		s.types = []string{t}
+	case []interface{}:
+		for _, name := range t {
+			if n, ok := name.(string); ok {
+				s.types = append(s.types, n)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%v: type should be a string or an array of strings", path)
+	}
+	if doc.Pattern != "" {
+		pattern, err := regexp.Compile(doc.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", path, err)
+		}
+		s.pattern = pattern
+	}
+	switch doc.Format {
+	case "", "date-time", "uri", "uuid":
+	default:
+		return nil, fmt.Errorf("%v: unsupported format %q", path, doc.Format)
+	}
+	if len(doc.Properties) > 0 {
+		s.properties = make(map[string]*Schema, len(doc.Properties))
+		for name, property := range doc.Properties {
+			compiled, err := compile(property, joinPath(path, name))
+			if err != nil {
+				return nil, err
+			}
+			s.properties[name] = compiled
+		}
+	}
+	if len(doc.Items) > 0 {
+		items, err := compile(doc.Items, path+"[]")
+		if err != nil {
+			return nil, err
+		}
+		s.items = items
+	}
+	return s, nil
+}
+
+// Validate returns the violations of the schema by a document decoded by encoding/json, in document order
+func (s *Schema) Validate(document interface{}) []Violation {
+	var violations []Violation
+	s.validate(document, "$", &violations)
+	return violations
+}
+
+// ValidateValue validates any value marshalled to JSON, such as a struct
+func (s *Schema) ValidateValue(value interface{}) ([]Violation, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var document interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	return s.Validate(document), nil
+}
+
+func (s *Schema) validate(value interface{}, path string, violations *[]Violation) {
+	report := func(format string, args ...interface{}) {
+		*violations = append(*violations, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.types) > 0 && !hasType(value, s.types) {
+		report("should be %v, not %v", strings.Join(s.types, " or "), typeOf(value))
+		return
+	}
+	if len(s.enum) > 0 && !inEnum(value, s.enum) {
+		report("should be one of %v", s.enum)
+	}
+
+	switch v := value.(type) {
+	case string:
+		if s.minLength != nil && len([]rune(v)) < *s.minLength {
+			report("should be at least %d characters long", *s.minLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			report("should match %v", s.pattern)
+		}
+		if s.format != "" && !hasFormat(v, s.format) {
+			report("should be a %v", s.format)
+		}
+	case []interface{}:
+		if s.minItems != nil && len(v) < *s.minItems {
+			report("should have at least %d items", *s.minItems)
+		}
+		if s.items != nil {
+			for i, item := range v {
+				s.items.validate(item, fmt.Sprintf("%v[%d]", path, i), violations)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.required {
+			if _, found := v[name]; !found {
+				*violations = append(*violations, Violation{Path: joinPath(path, name), Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, found := s.properties[name]
+			if !found {
+				if s.additionalProperties != nil && !*s.additionalProperties {
+					*violations = append(*violations, Violation{Path: joinPath(path, name), Message: "is not allowed"})
+				}
+				continue
+			}
+			property.validate(v[name], joinPath(path, name), violations)
+		}
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "$" {
+		return name
+	}
+	return path + "." + name
+}
+
+func typeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func hasType(value interface{}, types []string) bool {
+	actual := typeOf(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func inEnum(value interface{}, enum []interface{}) bool {
+	switch value.(type) {
+	case []interface{}, map[string]interface{}:
+		// only scalar enums are supported
+		return false
+	}
+	for _, allowed := range enum {
+		if allowed == value {
+			return true
+		}
+	}
+	return false
+}
+
+func hasFormat(value, format string) bool {
+	switch format {
+	case "date-time":
+		// RFC 3339 only, UPP dates with no colon in their zone offset are described with a pattern instead
+		_, err := time.Parse(time.RFC3339Nano, value)
+		return err == nil
+	case "uri":
+		u, err := url.Parse(value)
+		return err == nil && u.IsAbs()
+	case "uuid":
+		return uuidPattern.MatchString(value)
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func validate(t *testing.T, schemaJSON, documentJSON string) []Violation {
+	s, err := Compile([]byte(schemaJSON))
+	assert.NoError(t, err)
+	var document interface{}
+	assert.NoError(t, json.Unmarshal([]byte(documentJSON), &document))
+	return s.Validate(document)
+}
+
+func TestValidate_Conforming(t *testing.T) {
+	violations := validate(t,
+		`{"type":"object","required":["uuid"],"properties":{"uuid":{"type":"string","format":"uuid"},"tags":{"type":"array","items":{"type":"string","enum":["a","b"]}}}}`,
+		`{"uuid":"512c1f3d-e48c-4618-863c-94bc9d913b9b","tags":["a","b"],"other":1}`)
+
+	assert.Empty(t, violations)
+}
+
+func TestValidate_ReportsEveryViolationWithItsPath(t *testing.T) {
+	violations := validate(t, `{
+		"type": "object",
+		"required": ["uuid", "title"],
+		"additionalProperties": false,
+		"properties": {
+			"uuid": {"type": "string", "format": "uuid"},
+			"lastModified": {"type": "string", "format": "date-time"},
+			"alternativeTitles": {"type": "object"},
+			"identifiers": {"type": "array", "minItems": 1, "items": {"type": "object", "properties": {"authority": {"type": "string", "format": "uri"}}}},
+			"type": {"type": "string", "enum": ["Content"]},
+			"title": {"type": "string", "minLength": 1, "pattern": "^[A-Z]"}
+		}
+	}`, `{
+		"uuid": "not-a-uuid",
+		"lastModified": "yesterday",
+		"alternativeTitles": null,
+		"identifiers": [{"authority": "FTCOM-METHODE"}],
+		"type": "Article",
+		"extra": true
+	}`)
+
+	assert.Equal(t, []Violation{
+		{Path: "title", Message: "is required"},
+		{Path: "alternativeTitles", Message: "should be object, not null"},
+		{Path: "extra", Message: "is not allowed"},
+		{Path: "identifiers[0].authority", Message: "should be a uri"},
+		{Path: "lastModified", Message: "should be a date-time"},
+		{Path: "type", Message: "should be one of [Content]"},
+		{Path: "uuid", Message: "should be a uuid"},
+	}, violations)
+}
+
+func TestValidate_DateTimes(t *testing.T) {
+	s := `{"type":"string","format":"date-time"}`
+	assert.Empty(t, validate(t, s, `"2017-05-15T15:54:32.166Z"`))
+	assert.Empty(t, validate(t, s, `"2017-05-15T15:54:32.166+01:00"`))
+	assert.Len(t, validate(t, s, `"2017-05-15T15:54:32.166+0100"`), 1)
+	assert.Len(t, validate(t, s, `"2017-05-15"`), 1)
+}
+
+func TestValidate_Types(t *testing.T) {
+	assert.Empty(t, validate(t, `{"type":["string","null"]}`, `null`))
+	assert.Empty(t, validate(t, `{"type":"number"}`, `1`))
+	assert.Equal(t, []Violation{{Path: "$", Message: "should be integer, not number"}}, validate(t, `{"type":"integer"}`, `1.5`))
+}
+
+func TestCompile_RejectsUnsupportedKeywords(t *testing.T) {
+	_, err := Compile([]byte(`{"type":"object","properties":{"title":{"type":"string","maxLength":10}}}`))
+	assert.EqualError(t, err, `title: unsupported keyword "maxLength"`)
+
+	_, err = Compile([]byte(`{"type":"string","format":"email"}`))
+	assert.Error(t, err)
+
+	_, err = Compile([]byte(`{"type":"string","pattern":"("}`))
+	assert.Error(t, err)
+}